@@ -2,12 +2,18 @@ package api
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 
 	"typonamer/config"
 	"typonamer/log"
 	"typonamer/lookup/customize"
+	"typonamer/lookup/dnslib"
+	"typonamer/lookup/lookuper"
+	"typonamer/lookup/lookuperror"
+	"typonamer/lookup/rdaplib"
+	"typonamer/lookup/whoislib"
 	"typonamer/register"
 	"typonamer/scheduler"
 	"typonamer/utils"
@@ -37,16 +43,12 @@ func WebSettingList(c *fiber.Ctx) error {
 		registerApis = append(registerApis, api.ApiName)
 	}
 
-	whoisApis := make([]string, 0)
-	for _, api := range cfg.WhoisApis {
-		whoisApis = append(whoisApis, api.ApiName)
-	}
-
 	return c.JSON(fiber.Map{
 		"webCheckDomainLimit": cfg.WebCheckDomainLimit,
 		"typoDefaultCcTlds":   cfg.TypoDefaultCcTlds,
 		"registerApis":        registerApis,
-		"whoisApis":           whoisApis,
+		"whoisApis":           lookuper.CustomQueryTypes(),
+		"queryTypes":          lookuper.QueryTypes(),
 	})
 }
 
@@ -59,7 +61,11 @@ func SettingUpdate(c *fiber.Ctx) error {
 	}
 
 	err := config.UpdateConfig(*newConfig)
-	if err != nil {
+	if errors.Is(err, config.ErrorInvalidConfig) {
+		// The config has a setting which can not be used, it is not saved
+		log.Error("Invalid config: ", err)
+		return c.Status(400).SendString(err.Error())
+	} else if err != nil {
 		// Error updating the config
 		log.Error("Update config error: ", err)
 		return c.Status(500).SendString(err.Error())
@@ -68,6 +74,9 @@ func SettingUpdate(c *fiber.Ctx) error {
 	// Update the custom whois API limiter
 	customize.SetupLimiter()
 
+	// Update the custom whois API lookup backends
+	customize.SetupBackends()
+
 	// Update the register API limiter
 	register.SetupLimiter()
 
@@ -135,13 +144,14 @@ func BulkCheckDomainUpload(c *fiber.Ctx) error {
 }
 
 func BulkCheckResultDownload(c *fiber.Ctx) error {
-	// Get the taken, free and error domains
+	// Get the taken, free, reserved and error domains
 	takenDomains := scheduler.GetBulkCheckTakenDomains()
 	freeDomains := scheduler.GetBulkCheckFreeDomains()
+	reservedDomains := scheduler.GetBulkCheckReservedDomains()
 	errorDomains := scheduler.GetBulkCheckErrorDomains()
 
-	// Combine the taken, free and error domains
-	domainJsonResults := slice.Concat(takenDomains, freeDomains, errorDomains)
+	// Combine the taken, free, reserved and error domains
+	domainJsonResults := slice.Concat(takenDomains, freeDomains, reservedDomains, errorDomains)
 
 	domainResults := utils.GetOrderedQueryResult(domainJsonResults)
 	csvData, err := utils.ConvertQueryResultToCSV(domainResults)
@@ -164,3 +174,179 @@ func BulkCheckResultDownload(c *fiber.Ctx) error {
 	// Send the csv data to the client
 	return c.SendStream(bytes.NewReader(csvData))
 }
+
+func WhoisRulesList(c *fiber.Ctx) error {
+	log.Info("Getting whois rules success")
+	return c.JSON(fiber.Map{
+		"rules":        whoislib.GetWhoisRules().Rules,
+		"builtinRules": whoislib.BuiltinWhoisRules(),
+	})
+}
+
+func WhoisRulesUpdate(c *fiber.Ctx) error {
+	newRules := new(whoislib.WhoisRules)
+	if err := c.BodyParser(newRules); err != nil {
+		// Error parsing the whois rules
+		log.Error("Parse whois rules error: ", err)
+		return c.Status(400).SendString(err.Error())
+	}
+
+	return updateWhoisRules(c, *newRules)
+}
+
+func WhoisRulesUpload(c *fiber.Ctx) error {
+	uploadFile, err := c.FormFile("file")
+	if err != nil {
+		log.Error("Get file error: ", err)
+		return c.Status(500).SendString(err.Error())
+	}
+
+	log.Info("Whois rules upload: ", uploadFile.Filename)
+
+	f, err := uploadFile.Open()
+	if err != nil {
+		log.Error("Open file error: ", err)
+		return c.Status(500).SendString(err.Error())
+	}
+	defer f.Close()
+
+	// Read the file content into a buffer
+	buffer := bytes.NewBuffer(nil)
+	io.Copy(buffer, f)
+
+	// The rules file can be either YAML or JSON
+	newRules, err := whoislib.ParseWhoisRules(buffer.Bytes())
+	if err != nil {
+		log.Error("Parse whois rules error: ", err)
+		return c.Status(400).SendString(err.Error())
+	}
+
+	return updateWhoisRules(c, newRules)
+}
+
+func WhoisRulesReload(c *fiber.Ctx) error {
+	err := whoislib.LoadWhoisRules()
+	if err != nil {
+		// Error reloading the whois rules file, the rules in use are kept
+		log.Error("Reload whois rules error: ", err)
+		if errors.Is(err, whoislib.ErrorInvalidWhoisRules) {
+			return c.Status(400).SendString(err.Error())
+		}
+		return c.Status(500).SendString(err.Error())
+	}
+
+	log.Info("Reload whois rules success")
+
+	return c.JSON(whoislib.GetWhoisRules())
+}
+
+func WhoisServersList(c *fiber.Ctx) error {
+	log.Info("Getting whois server health success")
+	return c.JSON(whoislib.WhoisServerStatuses())
+}
+
+func RdapBootstrapList(c *fiber.Ctx) error {
+	log.Info("Getting RDAP bootstrap success")
+	return c.JSON(rdaplib.GetRdapBootstrapInfo())
+}
+
+func RdapBootstrapUpload(c *fiber.Ctx) error {
+	uploadFile, err := c.FormFile("file")
+	if err != nil {
+		log.Error("Get file error: ", err)
+		return c.Status(500).SendString(err.Error())
+	}
+
+	log.Info("RDAP bootstrap upload: ", uploadFile.Filename)
+
+	f, err := uploadFile.Open()
+	if err != nil {
+		log.Error("Open file error: ", err)
+		return c.Status(500).SendString(err.Error())
+	}
+	defer f.Close()
+
+	// Read the file content into a buffer
+	buffer := bytes.NewBuffer(nil)
+	io.Copy(buffer, f)
+
+	err = rdaplib.UpdateRdapBootstrap(buffer.Bytes())
+	if err != nil {
+		// Error updating the RDAP bootstrap file, the bootstrap in use is kept
+		log.Error("Update RDAP bootstrap error: ", err)
+		if errors.Is(err, rdaplib.ErrorInvalidRdapBootstrap) {
+			return c.Status(400).SendString(err.Error())
+		}
+		return c.Status(500).SendString(err.Error())
+	}
+
+	log.Info("Update RDAP bootstrap success")
+
+	return c.JSON(rdaplib.GetRdapBootstrapInfo())
+}
+
+func RdapBootstrapRefresh(c *fiber.Ctx) error {
+	bootstrapInfo, err := rdaplib.RefreshRdapBootstrap(c.Context())
+	if err != nil {
+		// Error downloading the RDAP bootstrap file, the bootstrap in use is kept
+		log.Error("Refresh RDAP bootstrap error: ", err)
+		if errors.Is(err, rdaplib.ErrorInvalidRdapBootstrap) {
+			return c.Status(400).SendString(err.Error())
+		}
+		return c.Status(500).SendString(err.Error())
+	}
+
+	log.Info("Refresh RDAP bootstrap success")
+
+	return c.JSON(bootstrapInfo)
+}
+
+func TldNsCacheList(c *fiber.Ctx) error {
+	log.Info("Getting TLD NS cache success")
+	return c.JSON(dnslib.GetTldNsCacheInfo())
+}
+
+func TldNsCacheClear(c *fiber.Ctx) error {
+	tld := c.Query("tld")
+	err := dnslib.ClearTldNsCache(c.Context(), tld)
+	if err != nil {
+		// The cache in memory is cleared, but the one saved in Redis may be left
+		log.Error("Clear TLD NS cache error: ", err)
+		return c.Status(500).SendString(err.Error())
+	}
+
+	log.Info("Clear TLD NS cache success")
+	return c.SendStatus(200)
+}
+
+func DnsResolverTest(c *fiber.Ctx) error {
+	domain := c.Query("domain")
+	results, err := dnslib.TestDnsResolvers(c.Context(), domain)
+	if err != nil {
+		log.Error("Test DNS resolvers error: ", err)
+		if errors.Is(err, lookuperror.ErrorInvalidDomainName) {
+			return c.Status(400).SendString(err.Error())
+		}
+		return c.Status(500).SendString(err.Error())
+	}
+
+	log.Info("Test DNS resolvers success")
+	return c.JSON(results)
+}
+
+// updateWhoisRules validates, saves and applies the new whois rules, and responds with the rules in use.
+func updateWhoisRules(c *fiber.Ctx, newRules whoislib.WhoisRules) error {
+	err := whoislib.UpdateWhoisRules(newRules)
+	if err != nil {
+		// Error updating the whois rules, the rules in use are kept
+		log.Error("Update whois rules error: ", err)
+		if errors.Is(err, whoislib.ErrorInvalidWhoisRules) {
+			return c.Status(400).SendString(err.Error())
+		}
+		return c.Status(500).SendString(err.Error())
+	}
+
+	log.Info("Update whois rules success")
+
+	return c.JSON(whoislib.GetWhoisRules())
+}
@@ -18,5 +18,16 @@ func ApiRoute(router fiber.Router) {
 	router.Delete("/admin/log", LoginRequired(), ResetLog)                                 // 清空日志
 	router.Post("/admin/bulkcheckupload", LoginRequired(), BulkCheckDomainUpload)          // 批量域名上传
 	router.Get("/admin/bulkcheckresultdownload", LoginRequired(), BulkCheckResultDownload) // 批量域名查询结果下载
+	router.Get("/admin/whoisrules", LoginRequired(), WhoisRulesList)                       // Whois解析规则获取接口
+	router.Put("/admin/whoisrules", LoginRequired(), WhoisRulesUpdate)                     // Whois解析规则更新接口
+	router.Post("/admin/whoisrulesupload", LoginRequired(), WhoisRulesUpload)              // Whois解析规则文件上传
+	router.Post("/admin/whoisrulesreload", LoginRequired(), WhoisRulesReload)              // Whois解析规则文件重新加载
+	router.Get("/admin/whoisservers", LoginRequired(), WhoisServersList)                   // Whois服务器健康状态获取接口
+	router.Get("/admin/rdapbootstrap", LoginRequired(), RdapBootstrapList)                 // RDAP引导文件获取接口
+	router.Post("/admin/rdapbootstrapupload", LoginRequired(), RdapBootstrapUpload)        // RDAP引导文件上传
+	router.Post("/admin/rdapbootstraprefresh", LoginRequired(), RdapBootstrapRefresh)      // RDAP引导文件重新下载
+	router.Get("/admin/tldnscache", LoginRequired(), TldNsCacheList)                       // TLD名称服务器缓存获取接口
+	router.Delete("/admin/tldnscache", LoginRequired(), TldNsCacheClear)                   // 清空TLD名称服务器缓存
+	router.Get("/admin/dnsresolvertest", LoginRequired(), DnsResolverTest)                 // DNS解析服务器测试接口
 
 }
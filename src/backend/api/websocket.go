@@ -29,8 +29,9 @@ type MessageObject struct {
 }
 
 type WebCheck struct {
-	QueryType string   `json:"queryType"`
-	Domains   []string `json:"domains"`
+	QueryType   string   `json:"queryType"`
+	Domains     []string `json:"domains"`
+	BypassCache bool     `json:"bypassCache"`
 }
 
 type Register struct {
@@ -39,14 +40,16 @@ type Register struct {
 }
 
 type BulkCheckStart struct {
-	QueryType string `json:"queryType"`
+	QueryType   string `json:"queryType"`
+	BypassCache bool   `json:"bypassCache"`
 }
 
 type TypoCheck struct {
-	Domain    string   `json:"domain"`
-	TypoType  []string `json:"typoType"`
-	CcTlds    []string `json:"ccTlds"`
-	QueryType string   `json:"queryType"`
+	Domain      string   `json:"domain"`
+	TypoType    []string `json:"typoType"`
+	CcTlds      []string `json:"ccTlds"`
+	QueryType   string   `json:"queryType"`
+	BypassCache bool     `json:"bypassCache"`
 }
 
 // -----------------------------------------------
@@ -203,6 +206,10 @@ func onBulkCheckStart(ep *socketio.EventPayload) {
 
 		// Set the query type of the bulk check task.
 		err = scheduler.SetBulkCheckQueryType(startMessage.QueryType)
+		if err == nil {
+			// Set whether the bulk check task bypasses the lookup cache.
+			err = scheduler.SetBulkCheckBypassCache(startMessage.BypassCache)
+		}
 		if err == nil {
 			log.Debugf("Admin user UUID %s set bulk check query type to %s", ep.Kws.UUID, startMessage.QueryType)
 			// Start the bulk check task.
@@ -378,7 +385,7 @@ func onWebCheck(ep *socketio.EventPayload) {
 		clientInfo.WebCheckTask.SetDomains(uniqueDomains)
 
 		// Run the web check task.
-		go clientInfo.WebCheckTask.Run(checkMessage.QueryType)
+		go clientInfo.WebCheckTask.Run(checkMessage.QueryType, checkMessage.BypassCache)
 
 		log.Debugf("Start web check task for user %s", ep.Kws.UUID)
 	} else {
@@ -493,7 +500,7 @@ func onTypoCheck(ep *socketio.EventPayload) {
 				clientInfo.WebCheckTask.SetDomains(uniqueDomains)
 
 				// Run the web check task.
-				go clientInfo.WebCheckTask.Run(typoMessage.QueryType)
+				go clientInfo.WebCheckTask.Run(typoMessage.QueryType, typoMessage.BypassCache)
 
 				log.Debugf("Start typo web check task for user %s", ep.Kws.UUID)
 			} else {
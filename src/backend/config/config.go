@@ -2,9 +2,12 @@ package config
 
 import (
 	"bytes"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"text/template"
 
 	"typonamer/log"
@@ -16,6 +19,12 @@ import (
 var config Config
 var configFile string
 
+// loadErr is the error of reading the config file at startup, the zero config is in use when it is set.
+var loadErr error
+
+// ErrorInvalidConfig is returned when a setting of the configuration can not be used, such as an unreadable CA file.
+var ErrorInvalidConfig = errors.New("invalid config")
+
 type Config struct {
 	LogLevel string `json:"logLevel"` //日志等级
 
@@ -24,13 +33,46 @@ type Config struct {
 	AuthExpireDays int    `json:"authExpireDays"` //认证有效期
 	JwtSecretKey   string `json:"jwtSecretKey"`   //JWT密钥
 
-	WhoisTimeout int `json:"whoisTimeout"` //whois超时
-	DnsTimeout   int `json:"dnsTimeout"`   //DNS超时
+	WhoisTimeout      int  `json:"whoisTimeout"`      //whois超时
+	DnsTimeout        int  `json:"dnsTimeout"`        //DNS超时
+	DnsMaxDepth       int  `json:"dnsMaxDepth"`       //DNS迭代查询最多跟随的委派层数
+	DnsCacheMaxTtl    int  `json:"dnsCacheMaxTtl"`    //TLD名称服务器缓存的最长时间(秒)
+	DnsCollectSignals bool `json:"dnsCollectSignals"` //DNS查询时收集SOA、地址、MX和DNSSEC信息
+
+	DnsMode               string        `json:"dnsMode"`               //DNS查询方式: iterative(从根服务器迭代查询), recursive, dot, doh(使用递归解析服务器)
+	DnsResolvers          []DnsResolver `json:"dnsResolvers"`          //递归解析服务器列表
+	DnsParentZoneFallback bool          `json:"dnsParentZoneFallback"` //解析服务器返回SERVFAIL时从根服务器查询上级域的委派
+
+	IanaWhoisServer  string `json:"ianaWhoisServer"`  //IANA whois服务器
+	IanaDiscoveryTtl int    `json:"ianaDiscoveryTtl"` //IANA发现的whois服务器缓存时间(秒)
+
+	WhoisFollowReferral   bool `json:"whoisFollowReferral"`   //是否跟随whois转介服务器
+	WhoisReferralMaxDepth int  `json:"whoisReferralMaxDepth"` //whois转介最大深度
+
+	WhoisRateLimitCoolDown int `json:"whoisRateLimitCoolDown"` //whois/RDAP服务器限流后暂停查询时间(秒)，RDAP服务器返回Retry-After时以其为准
+
+	WhoisServerSelection   string            `json:"whoisServerSelection"`   //whois服务器选择策略
+	WhoisServerMaxFailures int               `json:"whoisServerMaxFailures"` //whois服务器连续失败多少次后暂时剔除
+	WhoisServerEjectTime   int               `json:"whoisServerEjectTime"`   //whois服务器剔除时间(秒)
+	TldWhoisServers        []TldWhoisServers `json:"tldWhoisServers"`        //TLD的whois服务器列表
+
+	RdapBootstrapUrl string          `json:"rdapBootstrapUrl"` //RDAP引导文件下载地址
+	TldRdapServers   []TldRdapServer `json:"tldRdapServers"`   //TLD的RDAP服务器地址
+
+	RdapTlsSkipVerify bool   `json:"rdapTlsSkipVerify"` //RDAP是否跳过TLS证书校验
+	RdapTlsCaFile     string `json:"rdapTlsCaFile"`     //RDAP自定义CA证书文件
 
 	RetryOnTimeout bool `json:"retryOnTimeout"` //是否重试
 	RetryInterval  int  `json:"retryInterval"`  //重试间隔
 	RetryMax       int  `json:"retryMax"`       //最大重试次数
 
+	LookupCacheTakenTtl int `json:"lookupCacheTakenTtl"` //已注册查询结果缓存时间(秒)
+	LookupCacheFreeTtl  int `json:"lookupCacheFreeTtl"`  //未注册查询结果缓存时间(秒)
+	LookupCacheErrorTtl int `json:"lookupCacheErrorTtl"` //查询错误结果缓存时间(秒)
+
+	FallbackOrder     []string           `json:"fallbackOrder"`     //查询来源回退顺序
+	TldFallbackOrders []TldFallbackOrder `json:"tldFallbackOrders"` //TLD查询来源回退顺序
+
 	GlobalProxyTlds []string `json:"globalProxyTlds"` //全局代理TLD
 
 	MixedProxyTlds []string `json:"mixedProxyTlds"` //混合查询代理TLD
@@ -59,6 +101,26 @@ type CcTld struct {
 	IsSelected bool   `json:"isSelected"` //是否选中
 }
 
+type TldFallbackOrder struct {
+	Tld   string   `json:"tld"`   //TLD
+	Order []string `json:"order"` //查询来源回退顺序
+}
+
+type TldWhoisServers struct {
+	Tld     string   `json:"tld"`     //TLD
+	Servers []string `json:"servers"` //whois服务器列表
+}
+
+type TldRdapServer struct {
+	Tld string `json:"tld"` //TLD
+	Url string `json:"url"` //RDAP服务器地址
+}
+
+type DnsResolver struct {
+	Address          string `json:"address"`          //解析服务器地址，DoH为URL
+	ConcurrencyLimit int    `json:"concurrencyLimit"` //并发限制
+}
+
 type RegisterApi struct {
 	ApiName          string   `json:"apiName"`          //接口名称
 	ApiUrl           string   `json:"apiUrl"`           //接口地址
@@ -108,14 +170,21 @@ func init() {
 
 	// Read the configuration file.
 	err = viper.ReadInConfig()
+	// The server exits on LoadError, the other programs importing the package, such as the tests, run with the zero config.
 	if err != nil {
 		log.Error("Error reading config file: ", err)
-		os.Exit(1)
+		loadErr = err
+		return
 	}
 
 	// Unmarshal the configuration into the config variable.
 	viper.Unmarshal(&config)
 
+	// An invalid setting does not stop the start, the feature using it fails until it is fixed.
+	if err := validateConfig(config); err != nil {
+		log.Error("Invalid config: ", err)
+	}
+
 	// Print the configuration to the debug log at startup.
 	log.Debugf("Read config: %+v", config)
 }
@@ -124,6 +193,26 @@ func GetConfig() Config {
 	return config
 }
 
+// LoadError returns the error of reading the config file at startup, nil if it was read.
+func LoadError() error {
+	return loadErr
+}
+
+// SetForTest puts the config in use until the end of the test, and puts the previous one back after it.
+// The config file is not written, so the tests do not change the config of other runs.
+func SetForTest(t interface{ Cleanup(func()) }, cfg Config) {
+	previous := config
+	config = cfg
+	t.Cleanup(func() {
+		config = previous
+	})
+}
+
+// GetConfigDir returns the directory of the configuration file, the other data files are saved in it as well.
+func GetConfigDir() string {
+	return filepath.Dir(configFile)
+}
+
 func UpdateConfig(newConfig Config) error {
 	// Update the log level if it has changed.
 	// The log level is special cased because it needs to be updated immediately.
@@ -136,11 +225,37 @@ func UpdateConfig(newConfig Config) error {
 		log.SetLevel(newLogLevel)
 	}
 
+	newConfig.IanaWhoisServer = strutil.Trim(newConfig.IanaWhoisServer)
+
 	// Trim the TLDs to remove any whitespace.
 	newConfig.GlobalProxyTlds = trimTlds(newConfig.GlobalProxyTlds)
 	newConfig.MixedProxyTlds = trimTlds(newConfig.MixedProxyTlds)
 	newConfig.MixedDnsTlds = trimTlds(newConfig.MixedDnsTlds)
 
+	newConfig.WhoisServerSelection = strutil.Trim(newConfig.WhoisServerSelection)
+	for i, whoisServers := range newConfig.TldWhoisServers {
+		newConfig.TldWhoisServers[i].Tld = strutil.Trim(whoisServers.Tld, ".")
+		newConfig.TldWhoisServers[i].Servers = trimWhoisServers(whoisServers.Servers)
+	}
+
+	newConfig.RdapBootstrapUrl = strutil.Trim(newConfig.RdapBootstrapUrl)
+	newConfig.RdapTlsCaFile = strutil.Trim(newConfig.RdapTlsCaFile)
+	for i, rdapServer := range newConfig.TldRdapServers {
+		newConfig.TldRdapServers[i].Tld = strutil.Trim(rdapServer.Tld, ".")
+		newConfig.TldRdapServers[i].Url = strutil.Trim(rdapServer.Url)
+	}
+
+	newConfig.DnsMode = strutil.Trim(newConfig.DnsMode)
+	for i, resolver := range newConfig.DnsResolvers {
+		newConfig.DnsResolvers[i].Address = strutil.Trim(resolver.Address)
+	}
+
+	newConfig.FallbackOrder = trimLookupSources(newConfig.FallbackOrder)
+	for i, fallbackOrder := range newConfig.TldFallbackOrders {
+		newConfig.TldFallbackOrders[i].Tld = strutil.Trim(fallbackOrder.Tld, ".")
+		newConfig.TldFallbackOrders[i].Order = trimLookupSources(fallbackOrder.Order)
+	}
+
 	for i, tld := range newConfig.TypoDefaultCcTlds {
 		newConfig.TypoDefaultCcTlds[i].Tld = strutil.Trim(tld.Tld, ".")
 	}
@@ -177,11 +292,16 @@ func UpdateConfig(newConfig Config) error {
 		}
 	}
 
+	err := validateConfig(newConfig)
+	if err != nil {
+		return err
+	}
+
 	// Write the new configuration to the file specified by the configFile variable.
 	// If the file does not exist, it will be created.
 	// If the file cannot be written, the program will exit with code 1.
 	// The configuration is also printed to the debug log at startup.
-	err := WriteConfig(newConfig)
+	err = WriteConfig(newConfig)
 	if err != nil {
 		log.Error("Error writing config file: ", err)
 		return err
@@ -194,6 +314,30 @@ func UpdateConfig(newConfig Config) error {
 	return nil
 }
 
+// validateConfig checks the settings which can not be used as they are, the error wraps ErrorInvalidConfig.
+func validateConfig(cfg Config) error {
+	if err := validateCaFile(cfg.RdapTlsCaFile); err != nil {
+		return fmt.Errorf("%w: rdapTlsCaFile: %s", ErrorInvalidConfig, err)
+	}
+	return nil
+}
+
+// validateCaFile checks the CA file can be read and has a PEM certificate, an empty file name is valid.
+func validateCaFile(caFile string) error {
+	if caFile == "" {
+		return nil
+	}
+
+	caCerts, err := os.ReadFile(caFile)
+	if err != nil {
+		return err
+	}
+	if !x509.NewCertPool().AppendCertsFromPEM(caCerts) {
+		return fmt.Errorf("no PEM certificate found in %s", caFile)
+	}
+	return nil
+}
+
 func WriteConfig(newConfig Config) error {
 	configTemplate := `
 # Setting the log level, available values are: Error, Warn, Info, Debug, Off
@@ -209,14 +353,97 @@ JwtSecretKey: {{ .JwtSecretKey }}
 ## Setting whois parameters
 WhoisTimeout: {{ .WhoisTimeout }}
 
-## Setting DNS parameters
+## Setting the IANA-style WHOIS server to discover the WHOIS servers of unknown TLDs, and the TTL in seconds of the discovered servers
+IanaWhoisServer: {{ .IanaWhoisServer }}
+IanaDiscoveryTtl: {{ .IanaDiscoveryTtl }}
+
+## Setting whether to follow the referral of thin registries to the registrar WHOIS server, and the maximum number of referrals followed
+WhoisFollowReferral: {{ .WhoisFollowReferral }}
+WhoisReferralMaxDepth: {{ .WhoisReferralMaxDepth }}
+
+## Setting the time in seconds to pause the queries to a WHOIS or RDAP server after it rate limited a query, RDAP servers giving a Retry-After are paused for that time instead
+WhoisRateLimitCoolDown: {{ .WhoisRateLimitCoolDown }}
+
+## Setting how to select among the WHOIS servers of a TLD, available values are: roundRobin, leastLatency,
+## and the number of consecutive failures after which a server is ejected for the time in seconds
+WhoisServerSelection: {{ .WhoisServerSelection }}
+WhoisServerMaxFailures: {{ .WhoisServerMaxFailures }}
+WhoisServerEjectTime: {{ .WhoisServerEjectTime }}
+
+## The WHOIS servers of specific TLDs, replacing the built-in servers, given as host, host:port or IP address
+TldWhoisServers:
+{{- range .TldWhoisServers }}
+    - Tld: {{.Tld}}
+      Servers:
+{{- range .Servers }}
+          - {{.}}
+{{- end}}
+{{- end}}
+
+## Setting where the RDAP bootstrap file of the domains (dns.json) is downloaded from
+RdapBootstrapUrl: {{ .RdapBootstrapUrl }}
+
+## The RDAP base URLs of specific TLDs, used instead of the bootstrap file, also for the TLDs absent from it
+TldRdapServers:
+{{- range .TldRdapServers }}
+    - Tld: {{.Tld}}
+      Url: {{.Url}}
+{{- end}}
+
+## Setting whether to skip the verification of the RDAP server certificates,
+## and the PEM file of the CA certificates trusted besides the system ones, empty to trust the system ones only
+RdapTlsSkipVerify: {{ .RdapTlsSkipVerify }}
+RdapTlsCaFile: {{ .RdapTlsCaFile }}
+
+## Setting DNS parameters, DnsMaxDepth is the maximum number of delegations followed from the root servers
+## DnsCacheMaxTtl caps the seconds the TLD name servers are cached, they are cached for the TTL of their records up to it
+## DnsCollectSignals queries the name servers of the taken domains for their SOA, A/AAAA, MX, DS and DNSKEY records
 DnsTimeout: {{ .DnsTimeout }}
+DnsMaxDepth: {{ .DnsMaxDepth }}
+DnsCacheMaxTtl: {{ .DnsCacheMaxTtl }}
+DnsCollectSignals: {{ .DnsCollectSignals }}
+
+## Setting how the DNS checks resolve the domains: iterative walks down from the root servers,
+## recursive, dot and doh ask the DnsResolvers over UDP/TCP, DNS-over-TLS and DNS-over-HTTPS
+## The Address of a resolver is host[:port] for recursive and dot, and a URL for doh,
+## the recursive mode uses the system resolvers if DnsResolvers is empty
+DnsMode: {{ .DnsMode }}
+DnsResolvers:
+{{- range .DnsResolvers }}
+    - Address: {{.Address}}
+      ConcurrencyLimit: {{.ConcurrencyLimit}}
+{{- end}}
+
+## Setting whether the delegation of a domain is looked up from the root servers when the resolvers answer SERVFAIL,
+## so a domain with lame name servers is found taken as in the iterative mode, it needs UDP and TCP port 53 to the name servers
+DnsParentZoneFallback: {{ .DnsParentZoneFallback }}
 
 ## Setting retry parameters
 RetryOnTimeout: {{ .RetryOnTimeout }}
 RetryInterval: {{ .RetryInterval }}
 RetryMax: {{ .RetryMax }}
 
+## Setting lookup result cache TTL in seconds, 0 means not caching the result
+LookupCacheTakenTtl: {{ .LookupCacheTakenTtl }}
+LookupCacheFreeTtl: {{ .LookupCacheFreeTtl }}
+LookupCacheErrorTtl: {{ .LookupCacheErrorTtl }}
+
+## The lookup sources tried in order when a source times out or fails, available values are: rdap, whois, dns
+FallbackOrder:
+{{- range .FallbackOrder }}
+    - {{.}}
+{{- end}}
+
+## The lookup source fallback order of specific TLDs, overriding FallbackOrder
+TldFallbackOrders:
+{{- range .TldFallbackOrders }}
+    - Tld: {{.Tld}}
+      Order:
+{{- range .Order }}
+          - {{.}}
+{{- end}}
+{{- end}}
+
 ## The TLDs forced to go through proxy
 GlobalProxyTlds:
 {{- range .GlobalProxyTlds }}
@@ -349,3 +576,29 @@ func trimTlds(tlds []string) []string {
 	}
 	return trimmedTlds
 }
+
+// trimLookupSources trims and lowercases the lookup source names, and removes the empty ones.
+func trimLookupSources(sources []string) []string {
+	var trimmedSources []string
+	for _, source := range sources {
+		sourceStr := strings.ToLower(strutil.Trim(source))
+		if sourceStr == "" {
+			continue
+		}
+		trimmedSources = append(trimmedSources, sourceStr)
+	}
+	return trimmedSources
+}
+
+// trimWhoisServers trims and lowercases the WHOIS server names, and removes the empty ones.
+func trimWhoisServers(servers []string) []string {
+	var trimmedServers []string
+	for _, server := range servers {
+		serverStr := strings.ToLower(strutil.Trim(strutil.Trim(server), "."))
+		if serverStr == "" {
+			continue
+		}
+		trimmedServers = append(trimmedServers, serverStr)
+	}
+	return trimmedServers
+}
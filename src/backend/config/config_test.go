@@ -0,0 +1,48 @@
+package config
+
+import (
+	"encoding/pem"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateConfigRdapTlsCaFile(t *testing.T) {
+	dir := t.TempDir()
+
+	// The certificate of a test server is a valid CA certificate
+	server := httptest.NewTLSServer(nil)
+	server.Close()
+	validCaFile := filepath.Join(dir, "valid.pem")
+	validCa := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(validCaFile, validCa, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	invalidCaFile := filepath.Join(dir, "invalid.pem")
+	if err := os.WriteFile(invalidCaFile, []byte("not a certificate"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		caFile  string
+		wantErr bool
+	}{
+		{"no CA file", "", false},
+		{"valid CA file", validCaFile, false},
+		{"missing CA file", filepath.Join(dir, "missing.pem"), true},
+		{"CA file without certificate", invalidCaFile, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateConfig(Config{RdapTlsCaFile: tt.caFile})
+			if tt.wantErr != errors.Is(err, ErrorInvalidConfig) {
+				t.Errorf("validateConfig() error = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
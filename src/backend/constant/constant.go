@@ -12,6 +12,9 @@ const (
 
 	// MixedQuery is the type for querying whois and dns information.
 	MixedQuery = "mixedQuery"
+
+	// VerifyQuery is the type for querying RDAP, whois and dns information of the same domain and reconciling the results.
+	VerifyQuery = "verifyQuery"
 )
 
 const (
@@ -33,11 +36,26 @@ const (
 	// Redis key for bulk check free result
 	BulkCheckFreeResultRedisKey = "bulkCheckFreeResult"
 
+	// Redis key for bulk check reserved, premium and blocked result
+	BulkCheckReservedResultRedisKey = "bulkCheckReservedResult"
+
 	// Redis key for bulk check error result
 	BulkCheckErrorResultRedisKey = "bulkCheckErrorResult"
 
 	// Redis key for bulk check status
 	BulkCheckStatusRedisKey = "bulkCheckStatus"
+
+	// Redis key for bulk check bypass cache flag
+	BulkCheckBypassCacheRedisKey = "bulkCheckBypassCache"
+
+	// Redis key prefix for lookup result cache
+	LookupCacheRedisKeyPrefix = "lookupCache"
+
+	// Redis key prefix for the WHOIS servers discovered from IANA
+	WhoisServerDiscoveryRedisKeyPrefix = "whoisServerDiscovery"
+
+	// Redis key prefix for the name servers of the TLDs given by the root servers
+	TldNsCacheRedisKeyPrefix = "tldNsCache"
 )
 
 const (
@@ -144,6 +162,15 @@ const (
 
 	// DomainRegisterStatusError is the status when a domain query error occurs.
 	DomainRegisterStatusError = "Error"
+
+	// DomainRegisterStatusReserved is the status when a domain is reserved by the registry and can not be registered.
+	DomainRegisterStatusReserved = "Reserved"
+
+	// DomainRegisterStatusPremium is the status when a domain is available at a premium price set by the registry.
+	DomainRegisterStatusPremium = "Premium"
+
+	// DomainRegisterStatusBlocked is the status when a domain is blocked by the registry, such as by a trademark block.
+	DomainRegisterStatusBlocked = "Blocked"
 )
 
 const (
@@ -161,6 +188,21 @@ const (
 
 	// DomainStatusUnknown is the status when the domain status is unknown.
 	DomainStatusUnknown = "Unknown"
+
+	// DomainStatusAddGracePeriod is the status when a domain is in the grace period after its registration.
+	DomainStatusAddGracePeriod = "AddGracePeriod"
+
+	// DomainStatusAutoRenewGracePeriod is the status when a domain is in the grace period after it was renewed automatically at expiry.
+	DomainStatusAutoRenewGracePeriod = "AutoRenewGracePeriod"
+
+	// DomainStatusRenewGracePeriod is the status when a domain is in the grace period after it was renewed explicitly.
+	DomainStatusRenewGracePeriod = "RenewGracePeriod"
+
+	// DomainStatusTransferGracePeriod is the status when a domain is in the grace period after it was transferred.
+	DomainStatusTransferGracePeriod = "TransferGracePeriod"
+
+	// DomainStatusPendingRestore is the status when a domain is restored from the redemption period and waits for the restore report.
+	DomainStatusPendingRestore = "PendingRestore"
 )
 
 const (
@@ -172,6 +214,29 @@ const (
 
 	// LookupTypeDNS is the type for a dns lookup.
 	LookupTypeDNS = "dns"
+
+	// LookupTypeVerify is the type for a lookup combining the results of multiple sources.
+	LookupTypeVerify = "verify"
+)
+
+const (
+	// DnsStateLame is the state when none of the name servers of a domain answers authoritatively for it.
+	DnsStateLame = "Lame"
+
+	// DnsStateParked is the state when a domain is delegated to the name servers of a parking service.
+	DnsStateParked = "Parked"
+
+	// DnsStateLiveWithMail is the state when a domain has addresses and mail exchangers.
+	DnsStateLiveWithMail = "LiveWithMail"
+
+	// DnsStateLive is the state when a domain has addresses but no mail exchanger.
+	DnsStateLive = "Live"
+
+	// DnsStateMailOnly is the state when a domain has mail exchangers but no address.
+	DnsStateMailOnly = "MailOnly"
+
+	// DnsStateNoRecords is the state when a domain is served by its name servers but has no address or mail exchanger.
+	DnsStateNoRecords = "NoRecords"
 )
 
 const (
@@ -7,6 +7,7 @@ import (
 	"net"
 	"os"
 	"strconv"
+	"sync"
 	"time"
 
 	"typonamer/log"
@@ -28,6 +29,13 @@ var (
 	redisDB   string = os.Getenv("REDIS_DB")
 )
 
+var (
+	// sharedRedis is the Redis client shared by the packages, it is created on the first call of GetSharedRedis.
+	sharedRedis     *redis.Client
+	sharedRedisErr  error
+	sharedRedisOnce sync.Once
+)
+
 func init() {
 	// If the REDIS_HOST environment variable is not set, set it to the default
 	// value which is "localhost".
@@ -91,3 +99,12 @@ func GetRedis() (*redis.Client, error) {
 		retryCount++
 	}
 }
+
+// GetSharedRedis returns the Redis client shared by the packages.
+// The client is created by GetRedis on the first call, the later calls return the same client or error.
+func GetSharedRedis() (*redis.Client, error) {
+	sharedRedisOnce.Do(func() {
+		sharedRedis, sharedRedisErr = GetRedis()
+	})
+	return sharedRedis, sharedRedisErr
+}
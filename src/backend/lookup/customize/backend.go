@@ -0,0 +1,75 @@
+package customize
+
+import (
+	"context"
+	"sync"
+
+	"typonamer/config"
+	"typonamer/constant"
+	"typonamer/log"
+	"typonamer/lookup/lookupbackend"
+	"typonamer/lookup/lookupinfo"
+
+	"github.com/duke-git/lancet/v2/slice"
+)
+
+// apiBackend is the lookup backend of a customized whois API.
+type apiBackend struct {
+	apiName string
+}
+
+// reservedNames are the names of the built-in backends and query types, which can not be used by the customized whois APIs.
+var reservedNames = []string{
+	constant.LookupTypeWhois,
+	constant.LookupTypeRDAP,
+	constant.LookupTypeDNS,
+	constant.WhoisQuery,
+	constant.WhoisQueryWithProxy,
+	constant.DnsQuery,
+	constant.MixedQuery,
+	constant.VerifyQuery,
+	constant.LookupTypeVerify,
+}
+
+var (
+	registeredApiNames []string
+	registerMux        sync.Mutex
+)
+
+// SetupBackends registers a lookup backend for every customized whois API in the config.
+// The backends of the APIs which are removed from the config are unregistered.
+func SetupBackends() {
+	registerMux.Lock()
+	defer registerMux.Unlock()
+
+	for _, apiName := range registeredApiNames {
+		lookupbackend.Unregister(apiName)
+	}
+
+	cfg := config.GetConfig()
+
+	registeredApiNames = make([]string, 0, len(cfg.WhoisApis))
+	for _, api := range cfg.WhoisApis {
+		if _, ok := lookupbackend.Get(api.ApiName); ok || slice.Contain(reservedNames, api.ApiName) {
+			log.Warnf("Whois api name %s conflicts with a registered lookup backend, skip it", api.ApiName)
+			continue
+		}
+		lookupbackend.Register(apiBackend{apiName: api.ApiName})
+		registeredApiNames = append(registeredApiNames, api.ApiName)
+	}
+}
+
+// Name returns the name of the customized whois API.
+func (b apiBackend) Name() string {
+	return b.apiName
+}
+
+// Supports always returns true, the customized whois API decides by itself.
+func (b apiBackend) Supports(tld string) bool {
+	return true
+}
+
+// Lookup queries the domain with the customized whois API.
+func (b apiBackend) Lookup(ctx context.Context, domain string) (lookupinfo.DomainInfo, error) {
+	return CustomizeLookup(ctx, domain, b.apiName)
+}
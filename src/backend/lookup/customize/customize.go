@@ -1,7 +1,8 @@
 package customize
 
 import (
-	"fmt"
+	"context"
+	"net/url"
 	"sync"
 	"time"
 
@@ -21,6 +22,7 @@ var limiterList = map[string]*golimit.GoLimit{}
 
 func init() {
 	SetupLimiter()
+	SetupBackends()
 }
 
 func SetupLimiter() {
@@ -31,7 +33,9 @@ func SetupLimiter() {
 	}
 }
 
-func CustomizeLookup(domain string, queryType string) (lookupinfo.DomainInfo, error) {
+// CustomizeLookup queries the domain with the customized whois API named by the query type.
+// The request is aborted as soon as the context is canceled.
+func CustomizeLookup(ctx context.Context, domain string, queryType string) (lookupinfo.DomainInfo, error) {
 	var domainInfo = lookupinfo.DomainInfo{
 		DomainName: domain,
 		LookupType: queryType,
@@ -49,13 +53,20 @@ func CustomizeLookup(domain string, queryType string) (lookupinfo.DomainInfo, er
 
 	if apiInfo.ApiName == "" {
 		log.Debugf("Invalid query type: %s, no whois api found", queryType)
-		return domainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorInvalidQueryType, queryType)
+		return domainInfo, lookuperror.Newf(lookuperror.ErrorInvalidQueryType, "", "%s", queryType)
 	}
 
 	apiUrl := strutil.TemplateReplace(apiInfo.ApiUrl, map[string]string{
 		"domain": domain,
 	})
 
+	apiServer := apiInfo.ApiName
+	if parsedUrl, err := url.Parse(apiUrl); err == nil && parsedUrl.Host != "" {
+		apiServer = parsedUrl.Host
+	}
+
+	domainInfo.Trace.Server = apiServer
+
 	var response string
 	var err error
 
@@ -67,12 +78,17 @@ func CustomizeLookup(domain string, queryType string) (lookupinfo.DomainInfo, er
 
 		limiter.Do(func() {
 			defer wg.Done()
-			response, err = getResponse(apiUrl)
+			response, err = getResponse(ctx, apiUrl)
 		})
 
 		wg.Wait()
 	} else {
-		response, err = getResponse(apiUrl)
+		response, err = getResponse(ctx, apiUrl)
+	}
+
+	if ctx.Err() != nil {
+		log.Debugf("Request whois api %s with domain %s canceled", apiInfo.ApiName, domain)
+		return domainInfo, lookuperror.New(lookuperror.ErrorLookupCanceled, apiServer, ctx.Err())
 	}
 
 	if err != nil {
@@ -82,7 +98,7 @@ func CustomizeLookup(domain string, queryType string) (lookupinfo.DomainInfo, er
 		} else {
 			domainInfo.RawResponse = err.Error()
 		}
-		return domainInfo, err
+		return domainInfo, lookuperror.New(lookuperror.ErrorCustomizeApiServerResponse, apiServer, err)
 	}
 
 	domainInfo.RawResponse = response
@@ -91,7 +107,7 @@ func CustomizeLookup(domain string, queryType string) (lookupinfo.DomainInfo, er
 
 	if strutil.ContainsAny(response, apiInfo.FreeText) && strutil.ContainsAny(response, apiInfo.TakenText) {
 		log.Errorf("Request whois api %s with domain %s, response both contains free and taken text", apiInfo.ApiName, domain)
-		return domainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorCustomizeApiWhoisResult, domain)
+		return domainInfo, lookuperror.Newf(lookuperror.ErrorCustomizeApiWhoisResult, apiServer, "%s", domain)
 	} else if strutil.ContainsAny(response, apiInfo.FreeText) {
 		log.Debugf("Request whois api %s with domain %s, response contains all free text", apiInfo.ApiName, domain)
 		domainInfo.CustomizedResult = constant.DomainRegisterStatusFree
@@ -100,13 +116,18 @@ func CustomizeLookup(domain string, queryType string) (lookupinfo.DomainInfo, er
 		domainInfo.CustomizedResult = constant.DomainRegisterStatusTaken
 	} else {
 		log.Errorf("Request whois api %s with domain %s, response not contains free or taken text", apiInfo.ApiName, domain)
-		return domainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorCustomizeApiWhoisResult, domain)
+		return domainInfo, lookuperror.Newf(lookuperror.ErrorCustomizeApiWhoisResult, apiServer, "%s", domain)
 	}
 
 	return domainInfo, nil
 }
 
-func getResponse(apiUrl string) (string, error) {
+func getResponse(ctx context.Context, apiUrl string) (string, error) {
+	// Skip the request if the lookup is canceled while waiting for the limiter
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+
 	cfg := config.GetConfig()
 
 	client := resty.New()
@@ -118,7 +139,7 @@ func getResponse(apiUrl string) (string, error) {
 			SetRetryMaxWaitTime(time.Duration(cfg.RetryInterval) * time.Duration(cfg.RetryMax))
 	}
 
-	response, err := client.R().Get(apiUrl)
+	response, err := client.R().SetContext(ctx).Get(apiUrl)
 	if err != nil {
 		return "", err
 	}
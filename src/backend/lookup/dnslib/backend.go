@@ -0,0 +1,31 @@
+package dnslib
+
+import (
+	"context"
+
+	"typonamer/constant"
+	"typonamer/lookup/lookupbackend"
+	"typonamer/lookup/lookupinfo"
+)
+
+// dnsBackend is the lookup backend that checks the NS delegation of the domain.
+type dnsBackend struct{}
+
+func init() {
+	lookupbackend.Register(dnsBackend{})
+}
+
+// Name returns the name of the DNS backend.
+func (dnsBackend) Name() string {
+	return constant.LookupTypeDNS
+}
+
+// Supports always returns true, the NS delegation can be checked for any TLD.
+func (dnsBackend) Supports(tld string) bool {
+	return true
+}
+
+// Lookup checks the NS records of the domain.
+func (dnsBackend) Lookup(ctx context.Context, domain string) (lookupinfo.DomainInfo, error) {
+	return NsCheck(ctx, domain)
+}
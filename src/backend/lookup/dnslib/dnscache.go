@@ -0,0 +1,508 @@
+package dnslib
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"typonamer/config"
+	"typonamer/constant"
+	"typonamer/database"
+	"typonamer/log"
+
+	"github.com/bytedance/sonic"
+	"github.com/duke-git/lancet/v2/strutil"
+	"github.com/miekg/dns"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// rootHintsFileName is the name of the root hints file, a file of this name in the directory of the config file
+	// is used instead of the bundled one.
+	rootHintsFileName = "named.root"
+
+	// defaultDnsCacheMaxTtl is the longest time the name servers of a TLD are cached if none is configured,
+	// the TTL of the NS records in the root zone.
+	defaultDnsCacheMaxTtl = 2 * 24 * time.Hour
+
+	// dnsCacheMinTtl is the shortest time the name servers of a TLD are cached, so a tiny TTL does not send every lookup to the root servers.
+	dnsCacheMinTtl = time.Minute
+
+	// tldNsCacheRefreshInterval is the interval of the background refresh of the cache.
+	tldNsCacheRefreshInterval = time.Minute
+
+	// tldNsCacheRefreshAhead is how long before their expiry at most the name servers of the TLDs in use are refreshed.
+	tldNsCacheRefreshAhead = 10 * time.Minute
+
+	// rootServersPrimeInterval is the interval of asking the root servers for their current addresses.
+	rootServersPrimeInterval = 24 * time.Hour
+
+	// tldNsCacheRedisTimeout is the timeout of the Redis commands of the cache.
+	tldNsCacheRedisTimeout = 5 * time.Second
+)
+
+// ErrorInvalidRootHints is returned when the root hints file can not be parsed or has no root server with an address.
+var ErrorInvalidRootHints = errors.New("invalid root hints file")
+
+// bundledRootHints is the root hints file of IANA, used if there is none in the directory of the config file.
+//
+//go:embed named.root
+var bundledRootHints []byte
+
+// NsCache holds the name servers of the TLDs given by the root servers, until the TTL of their records expires.
+type NsCache struct {
+	TldNsMap map[string]tldNsCacheEntry
+	mux      sync.RWMutex
+}
+
+// tldNsCacheEntry is the name servers of a TLD in the cache, it is saved in Redis as JSON.
+type tldNsCacheEntry struct {
+	Servers  []NameServer `json:"servers"`
+	CachedAt time.Time    `json:"cachedAt"`
+	ExpireAt time.Time    `json:"expireAt"`
+	hits     int          // hits is the number of resolutions started from the entry, the unused entries are left to expire.
+}
+
+// TldNsCacheEntry is the name servers of a TLD in the cache.
+type TldNsCacheEntry struct {
+	Tld      string       `json:"tld"`      // Tld is the TLD.
+	Servers  []NameServer `json:"servers"`  // Servers is the name servers of the TLD with the addresses of their glue.
+	CachedAt string       `json:"cachedAt"` // CachedAt is when the name servers were given by the root servers.
+	ExpireAt string       `json:"expireAt"` // ExpireAt is when the name servers expire, the lowest TTL of the referral.
+	Hits     int          `json:"hits"`     // Hits is the number of resolutions started from the name servers since they were cached.
+}
+
+// TldNsCacheInfo is the root servers and the TLD name servers the DNS checks start from.
+type TldNsCacheInfo struct {
+	RootHintsFile string            `json:"rootHintsFile"` // RootHintsFile is the root hints file loaded, "bundled" for the bundled one.
+	RootPrimedAt  string            `json:"rootPrimedAt"`  // RootPrimedAt is when the root servers last answered with their addresses, empty if they never did.
+	RootServers   []NameServer      `json:"rootServers"`   // RootServers is the root servers with their addresses.
+	Entries       []TldNsCacheEntry `json:"entries"`       // Entries is the cached name servers of the TLDs, sorted by TLD.
+}
+
+var (
+	TldNsCache = NsCache{
+		TldNsMap: make(map[string]tldNsCacheEntry),
+	}
+
+	// rootServers are the root name servers with their IPv4 and IPv6 addresses, from the root hints and updated by priming.
+	rootServers    []NameServer
+	rootHintsFile  string
+	rootPrimedAt   time.Time
+	rootServersMux sync.RWMutex
+)
+
+func init() {
+	if err := loadRootHints(); err != nil {
+		log.Errorf("Failed to load the root hints file %s, using the bundled one: %s", filepath.Join(config.GetConfigDir(), rootHintsFileName), err)
+		servers, _ := parseRootHints(bundledRootHints)
+		setRootServers(servers, "bundled", time.Time{})
+	}
+}
+
+// HasTldNsCache returns true if the name servers of the TLD are cached and not expired.
+func HasTldNsCache(tld string) bool {
+	TldNsCache.mux.RLock()
+	defer TldNsCache.mux.RUnlock()
+	entry, ok := TldNsCache.TldNsMap[tld]
+	return ok && time.Now().Before(entry.ExpireAt)
+}
+
+// AddTldNsCache caches the name servers of the TLD for the TTL and saves them in Redis, replacing the ones cached before.
+func AddTldNsCache(tld string, nameServers []NameServer, ttl time.Duration) {
+	now := time.Now()
+	entry := tldNsCacheEntry{
+		Servers:  slices.Clone(nameServers),
+		CachedAt: now,
+		ExpireAt: now.Add(ttl),
+	}
+
+	TldNsCache.mux.Lock()
+	TldNsCache.TldNsMap[tld] = entry
+	TldNsCache.mux.Unlock()
+
+	// Redis is written in the background, so a slow or missing Redis does not hold up the resolution
+	go func() {
+		if err := saveTldNsCacheEntry(tld, entry); err != nil {
+			log.Warnf("Failed to save the NS records of '%s' to Redis: %s", tld, err)
+		}
+	}()
+}
+
+// GetTldNsCache returns a copy of the cached name servers of the TLD, so the resolvers can fill in their addresses.
+// It is empty if the name servers are not cached or expired.
+func GetTldNsCache(tld string) []NameServer {
+	TldNsCache.mux.Lock()
+	defer TldNsCache.mux.Unlock()
+	entry, ok := TldNsCache.TldNsMap[tld]
+	if !ok || !time.Now().Before(entry.ExpireAt) {
+		return []NameServer{}
+	}
+	entry.hits++
+	TldNsCache.TldNsMap[tld] = entry
+	return slices.Clone(entry.Servers)
+}
+
+// GetTldNsCacheInfo returns the root servers and the cached name servers of the TLDs which are not expired.
+func GetTldNsCacheInfo() TldNsCacheInfo {
+	info := TldNsCacheInfo{
+		Entries: []TldNsCacheEntry{},
+	}
+
+	rootServersMux.RLock()
+	info.RootHintsFile = rootHintsFile
+	info.RootServers = slices.Clone(rootServers)
+	if !rootPrimedAt.IsZero() {
+		info.RootPrimedAt = rootPrimedAt.Format(time.DateTime)
+	}
+	rootServersMux.RUnlock()
+
+	now := time.Now()
+	TldNsCache.mux.RLock()
+	for tld, entry := range TldNsCache.TldNsMap {
+		if !now.Before(entry.ExpireAt) {
+			continue
+		}
+		info.Entries = append(info.Entries, TldNsCacheEntry{
+			Tld:      tld,
+			Servers:  slices.Clone(entry.Servers),
+			CachedAt: entry.CachedAt.Format(time.DateTime),
+			ExpireAt: entry.ExpireAt.Format(time.DateTime),
+			Hits:     entry.hits,
+		})
+	}
+	TldNsCache.mux.RUnlock()
+
+	slices.SortFunc(info.Entries, func(a, b TldNsCacheEntry) int {
+		return strings.Compare(a.Tld, b.Tld)
+	})
+
+	return info
+}
+
+// ClearTldNsCache removes the cached name servers of the TLD from memory and Redis, or of all the TLDs if the TLD is empty.
+// The memory is cleared even if Redis fails, the next resolutions of the TLDs start from the root servers.
+func ClearTldNsCache(ctx context.Context, tld string) error {
+	tld = strings.ToLower(strutil.Trim(strutil.Trim(tld), "."))
+
+	TldNsCache.mux.Lock()
+	if tld == "" {
+		TldNsCache.TldNsMap = make(map[string]tldNsCacheEntry)
+	} else {
+		delete(TldNsCache.TldNsMap, tld)
+	}
+	TldNsCache.mux.Unlock()
+
+	rdb, err := database.GetSharedRedis()
+	if err != nil {
+		return err
+	}
+
+	if tld != "" {
+		log.Infof("Cleared NS records of '%s' from cache", tld)
+		return rdb.Del(ctx, tldNsCacheRedisKey(tld)).Err()
+	}
+
+	keys, err := scanTldNsCacheRedisKeys(ctx, rdb)
+	if err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		err = rdb.Del(ctx, keys...).Err()
+		if err != nil {
+			return err
+		}
+	}
+	log.Infof("Cleared NS records of all TLDs from cache")
+
+	return nil
+}
+
+// getRootServers returns a copy of the root servers, so the resolvers can reorder them.
+func getRootServers() []NameServer {
+	rootServersMux.RLock()
+	defer rootServersMux.RUnlock()
+	return slices.Clone(rootServers)
+}
+
+func setRootServers(servers []NameServer, hintsFile string, primedAt time.Time) {
+	rootServersMux.Lock()
+	defer rootServersMux.Unlock()
+	rootServers = servers
+	if hintsFile != "" {
+		rootHintsFile = hintsFile
+	}
+	rootPrimedAt = primedAt
+}
+
+// loadRootHints loads the root hints file in the directory of the config file, or the bundled one if there is none there.
+func loadRootHints() error {
+	hintsFile := filepath.Join(config.GetConfigDir(), rootHintsFileName)
+
+	data, err := os.ReadFile(hintsFile)
+	if errors.Is(err, os.ErrNotExist) {
+		data, hintsFile = bundledRootHints, "bundled"
+	} else if err != nil {
+		return err
+	}
+
+	servers, err := parseRootHints(data)
+	if err != nil {
+		return err
+	}
+
+	setRootServers(servers, hintsFile, time.Time{})
+	log.Infof("Loaded %d root servers from the %s root hints file", len(servers), hintsFile)
+
+	return nil
+}
+
+// parseRootHints parses the root hints file in the zone file format, such as the named.root file of IANA.
+// The root servers are kept in the order of the file, with their IPv4 addresses first.
+func parseRootHints(data []byte) ([]NameServer, error) {
+	var nsNames []string
+	ipv4Addrs := make(map[string][]string)
+	ipv6Addrs := make(map[string][]string)
+
+	parser := dns.NewZoneParser(bytes.NewReader(data), ".", rootHintsFileName)
+	for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
+		name := dns.CanonicalName(rr.Header().Name)
+		switch record := rr.(type) {
+		case *dns.NS:
+			if name == "." {
+				nsNames = append(nsNames, dns.CanonicalName(record.Ns))
+			}
+		case *dns.A:
+			ipv4Addrs[name] = append(ipv4Addrs[name], record.A.String())
+		case *dns.AAAA:
+			ipv6Addrs[name] = append(ipv6Addrs[name], record.AAAA.String())
+		}
+	}
+	if err := parser.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrorInvalidRootHints, err)
+	}
+
+	servers := make([]NameServer, 0, len(nsNames))
+	for _, nsName := range nsNames {
+		addrs := append(slices.Clone(ipv4Addrs[nsName]), ipv6Addrs[nsName]...)
+		if len(addrs) > 0 {
+			servers = append(servers, NameServer{Name: nsName, Addrs: addrs})
+		}
+	}
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("%w: no root server with an address", ErrorInvalidRootHints)
+	}
+
+	return servers, nil
+}
+
+// RunTldNsCacheRefresher loads the cache saved in Redis, then keeps the root servers and the cached name servers of the TLDs in use fresh
+// until the context is canceled.
+func RunTldNsCacheRefresher(ctx context.Context) {
+	loadTldNsCache(ctx)
+	primeRootServers(ctx)
+	lastPrimed := time.Now()
+
+	ticker := time.NewTicker(tldNsCacheRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if time.Since(lastPrimed) >= rootServersPrimeInterval {
+				primeRootServers(ctx)
+				lastPrimed = time.Now()
+			}
+			refreshTldNsCache(ctx)
+		}
+	}
+}
+
+// loadTldNsCache loads the name servers of the TLDs saved in Redis by the previous runs into memory.
+func loadTldNsCache(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, tldNsCacheRedisTimeout)
+	defer cancel()
+
+	rdb, err := database.GetSharedRedis()
+	if err != nil {
+		log.Warnf("TLD NS cache is not persisted: %s", err)
+		return
+	}
+
+	keys, err := scanTldNsCacheRedisKeys(ctx, rdb)
+	if err != nil || len(keys) == 0 {
+		if err != nil {
+			log.Warnf("Failed to load the TLD NS cache from Redis: %s", err)
+		}
+		return
+	}
+
+	values, err := rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		log.Warnf("Failed to load the TLD NS cache from Redis: %s", err)
+		return
+	}
+
+	loaded := restoreTldNsCache(keys, values)
+	log.Infof("Loaded NS records of %d TLDs from Redis", loaded)
+}
+
+// restoreTldNsCache puts the name servers saved in Redis under the keys into memory, and returns the number of TLDs restored.
+// The values which are missing, invalid or expired are skipped.
+func restoreTldNsCache(keys []string, values []any) int {
+	now := time.Now()
+	restored := 0
+
+	TldNsCache.mux.Lock()
+	defer TldNsCache.mux.Unlock()
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		entry := tldNsCacheEntry{}
+		if err := sonic.UnmarshalString(data, &entry); err != nil || len(entry.Servers) == 0 || !now.Before(entry.ExpireAt) {
+			continue
+		}
+
+		tld := strings.TrimPrefix(keys[i], tldNsCacheRedisKey(""))
+		// The name servers cached by this run are newer than the saved ones
+		if _, ok := TldNsCache.TldNsMap[tld]; !ok {
+			TldNsCache.TldNsMap[tld] = entry
+			restored++
+		}
+	}
+
+	return restored
+}
+
+// saveTldNsCacheEntry saves the name servers of the TLD in Redis until they expire.
+func saveTldNsCacheEntry(tld string, entry tldNsCacheEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), tldNsCacheRedisTimeout)
+	defer cancel()
+
+	rdb, err := database.GetSharedRedis()
+	if err != nil {
+		return err
+	}
+
+	data, err := sonic.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return rdb.Set(ctx, tldNsCacheRedisKey(tld), data, time.Until(entry.ExpireAt)).Err()
+}
+
+// primeRootServers asks the root servers for their names and addresses, so the root servers renumbered since
+// the root hints file was made are reached at their new addresses. The root servers in use are kept if priming fails.
+func primeRootServers(ctx context.Context) {
+	resolver := newIterativeResolver()
+
+	servers := getRootServers()
+	response, server, err := resolver.exchange(ctx, newDnsQuery(".", dns.TypeNS), servers, 0)
+	if err != nil {
+		log.Warnf("Failed to prime the root servers: %s", err)
+		return
+	}
+
+	var nsNames []string
+	for _, rr := range response.Answer {
+		if ns, ok := rr.(*dns.NS); ok && dns.CanonicalName(ns.Hdr.Name) == "." {
+			nsNames = append(nsNames, dns.CanonicalName(ns.Ns))
+		}
+	}
+
+	primed := slices.DeleteFunc(getReferralServers(response, ".", nsNames), func(server NameServer) bool {
+		return len(server.Addrs) == 0
+	})
+	if len(primed) == 0 {
+		log.Warnf("Root server %s answered the priming query without addresses, keeping the root servers in use", server)
+		return
+	}
+
+	setRootServers(primed, "", time.Now())
+	log.Infof("Primed %d root servers via %s", len(primed), server)
+}
+
+// refreshTldNsCache asks the root servers again for the name servers of the TLDs which are about to expire,
+// only the TLDs used since they were cached are refreshed.
+func refreshTldNsCache(ctx context.Context) {
+	var tlds []string
+	now := time.Now()
+
+	TldNsCache.mux.Lock()
+	for tld, entry := range TldNsCache.TldNsMap {
+		// The name servers with a short TTL are refreshed in the last tenth of it
+		refreshAhead := min(tldNsCacheRefreshAhead, entry.ExpireAt.Sub(entry.CachedAt)/10)
+		switch {
+		case !now.Before(entry.ExpireAt):
+			delete(TldNsCache.TldNsMap, tld)
+		case entry.hits > 0 && entry.ExpireAt.Before(now.Add(refreshAhead)):
+			tlds = append(tlds, tld)
+		}
+	}
+	TldNsCache.mux.Unlock()
+
+	for _, tld := range tlds {
+		resolver := newIterativeResolver()
+		// The walk caches the referral of the root servers, which replaces the expiring name servers
+		_, err := resolver.walk(ctx, dns.Fqdn(tld), dns.TypeNS, ".", getRootServers(), 1)
+		if err != nil {
+			log.Warnf("Failed to refresh NS records of '%s': %s", tld, err)
+			continue
+		}
+		log.Debugf("Refreshed NS records of '%s'", tld)
+	}
+}
+
+// getReferralTtl returns how long the referral of the TLD may be cached, the lowest TTL of its NS records and of the glue of its
+// name servers, capped by the configured maximum.
+func getReferralTtl(response *dns.Msg, zone string, servers []NameServer) time.Duration {
+	maxTtl := time.Duration(config.GetConfig().DnsCacheMaxTtl) * time.Second
+	if maxTtl <= 0 {
+		maxTtl = defaultDnsCacheMaxTtl
+	}
+
+	ttl := maxTtl
+	for _, rr := range response.Ns {
+		if ns, ok := rr.(*dns.NS); ok && dns.CanonicalName(ns.Hdr.Name) == zone {
+			ttl = min(ttl, time.Duration(ns.Hdr.Ttl)*time.Second)
+		}
+	}
+	for _, rr := range response.Extra {
+		name := dns.CanonicalName(rr.Header().Name)
+		switch rr.(type) {
+		case *dns.A, *dns.AAAA:
+			if slices.ContainsFunc(servers, func(server NameServer) bool { return server.Name == name }) {
+				ttl = min(ttl, time.Duration(rr.Header().Ttl)*time.Second)
+			}
+		}
+	}
+
+	return max(ttl, dnsCacheMinTtl)
+}
+
+// scanTldNsCacheRedisKeys returns the redis keys of all the cached name servers, scanning so a large Redis is not blocked.
+func scanTldNsCacheRedisKeys(ctx context.Context, rdb *redis.Client) ([]string, error) {
+	var keys []string
+	iter := rdb.Scan(ctx, 0, tldNsCacheRedisKey("*"), 0).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	return keys, iter.Err()
+}
+
+// tldNsCacheRedisKey returns the redis key of the cached name servers of the TLD.
+func tldNsCacheRedisKey(tld string) string {
+	return fmt.Sprintf("%s:%s", constant.TldNsCacheRedisKeyPrefix, tld)
+}
@@ -0,0 +1,223 @@
+package dnslib
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"typonamer/config"
+
+	"github.com/bytedance/sonic"
+	"github.com/miekg/dns"
+)
+
+func TestGetReferralTtl(t *testing.T) {
+	setTestDnsConfig(t, func(cfg *config.Config) {
+		cfg.DnsCacheMaxTtl = 86400
+	})
+	servers := []NameServer{{Name: "ns1.nic.test."}, {Name: "ns2.nic.test."}}
+
+	tests := []struct {
+		name  string
+		ns    []string
+		extra []string
+		want  time.Duration
+	}{
+		{
+			name:  "ns record lowest",
+			ns:    []string{"test. 3600 IN NS ns1.nic.test.", "test. 7200 IN NS ns2.nic.test."},
+			extra: []string{"ns1.nic.test. 7200 IN A 192.0.2.2", "ns2.nic.test. 7200 IN AAAA 2001:db8::2"},
+			want:  time.Hour,
+		},
+		{
+			name:  "glue lowest",
+			ns:    []string{"test. 7200 IN NS ns1.nic.test.", "test. 7200 IN NS ns2.nic.test."},
+			extra: []string{"ns1.nic.test. 7200 IN A 192.0.2.2", "ns2.nic.test. 1800 IN AAAA 2001:db8::2"},
+			want:  30 * time.Minute,
+		},
+		{
+			name:  "records of other names ignored",
+			ns:    []string{"test. 7200 IN NS ns1.nic.test.", "other. 60 IN NS ns1.nic.other."},
+			extra: []string{"ns1.nic.test. 7200 IN A 192.0.2.2", "ns1.nic.other. 60 IN A 192.0.2.4"},
+			want:  2 * time.Hour,
+		},
+		{
+			name:  "capped by the configured maximum",
+			ns:    []string{"test. 172800 IN NS ns1.nic.test."},
+			extra: []string{"ns1.nic.test. 172800 IN A 192.0.2.2"},
+			want:  24 * time.Hour,
+		},
+		{
+			name:  "raised to the minimum",
+			ns:    []string{"test. 5 IN NS ns1.nic.test."},
+			extra: []string{"ns1.nic.test. 5 IN A 192.0.2.2"},
+			want:  dnsCacheMinTtl,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := new(dns.Msg)
+			response.Ns = testRRs(tt.ns...)
+			response.Extra = testRRs(tt.extra...)
+
+			if got := getReferralTtl(response, "test.", servers); got != tt.want {
+				t.Errorf("getReferralTtl() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTldNsCacheExpiry(t *testing.T) {
+	resetTldNsCache()
+	t.Cleanup(resetTldNsCache)
+
+	now := time.Now()
+	servers := []NameServer{{Name: "ns1.nic.test.", Addrs: []string{"192.0.2.2"}}}
+	TldNsCache.mux.Lock()
+	TldNsCache.TldNsMap["fresh"] = tldNsCacheEntry{Servers: servers, CachedAt: now, ExpireAt: now.Add(time.Hour)}
+	TldNsCache.TldNsMap["expired"] = tldNsCacheEntry{Servers: servers, CachedAt: now.Add(-time.Hour), ExpireAt: now.Add(-time.Second)}
+	TldNsCache.mux.Unlock()
+
+	if !HasTldNsCache("fresh") || len(GetTldNsCache("fresh")) != 1 {
+		t.Error("name servers not expired yet are not in use")
+	}
+	if HasTldNsCache("expired") || len(GetTldNsCache("expired")) != 0 {
+		t.Error("expired name servers still in use")
+	}
+	if entries := GetTldNsCacheInfo().Entries; len(entries) != 1 || entries[0].Tld != "fresh" {
+		t.Errorf("cache info entries = %v, want only the fresh TLD", entries)
+	}
+
+	// The refresh drops the expired name servers, the fresh ones are not due for a refresh
+	refreshTldNsCache(context.Background())
+	TldNsCache.mux.RLock()
+	_, expiredKept := TldNsCache.TldNsMap["expired"]
+	_, freshKept := TldNsCache.TldNsMap["fresh"]
+	TldNsCache.mux.RUnlock()
+	if expiredKept || !freshKept {
+		t.Errorf("after the refresh, expired kept %t and fresh kept %t, want false and true", expiredKept, freshKept)
+	}
+}
+
+func TestRestoreTldNsCache(t *testing.T) {
+	resetTldNsCache()
+	t.Cleanup(resetTldNsCache)
+
+	now := time.Now().Truncate(time.Second)
+	saved := func(servers []NameServer, expireAt time.Time) string {
+		data, err := sonic.MarshalString(tldNsCacheEntry{Servers: servers, CachedAt: now.Add(-time.Minute), ExpireAt: expireAt})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	servers := []NameServer{{Name: "ns1.nic.test.", Addrs: []string{"192.0.2.2", "2001:db8::2"}}}
+
+	// The name servers cached by this run are kept over the saved ones
+	inMemory := []NameServer{{Name: "ns1.nic.memory.", Addrs: []string{"192.0.2.5"}}}
+	TldNsCache.mux.Lock()
+	TldNsCache.TldNsMap["memory"] = tldNsCacheEntry{Servers: inMemory, CachedAt: now, ExpireAt: now.Add(time.Hour)}
+	TldNsCache.mux.Unlock()
+
+	keys := []string{
+		tldNsCacheRedisKey("test"),
+		tldNsCacheRedisKey("expired"),
+		tldNsCacheRedisKey("invalid"),
+		tldNsCacheRedisKey("empty"),
+		tldNsCacheRedisKey("gone"),
+		tldNsCacheRedisKey("memory"),
+	}
+	values := []any{
+		saved(servers, now.Add(time.Hour)),
+		saved(servers, now.Add(-time.Second)),
+		"{not json",
+		saved(nil, now.Add(time.Hour)),
+		nil, // the key expired between the scan and the read
+		saved(servers, now.Add(time.Hour)),
+	}
+
+	if restored := restoreTldNsCache(keys, values); restored != 1 {
+		t.Errorf("restoreTldNsCache() = %d, want 1", restored)
+	}
+	if got := GetTldNsCache("test"); !slices.EqualFunc(got, servers, equalNameServer) {
+		t.Errorf("restored name servers of test = %v, want %v", got, servers)
+	}
+	for _, tld := range []string{"expired", "invalid", "empty", "gone"} {
+		if HasTldNsCache(tld) {
+			t.Errorf("name servers of %s restored", tld)
+		}
+	}
+	if got := GetTldNsCache("memory"); !slices.EqualFunc(got, inMemory, equalNameServer) {
+		t.Errorf("name servers of memory = %v, want the ones cached in memory %v", got, inMemory)
+	}
+
+	TldNsCache.mux.RLock()
+	expireAt := TldNsCache.TldNsMap["test"].ExpireAt
+	TldNsCache.mux.RUnlock()
+	if !expireAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("restored expiry = %s, want the saved %s", expireAt, now.Add(time.Hour))
+	}
+}
+
+// TestTldNsCacheRedisRoundTrip saves name servers in Redis and loads them back, it runs only with a Redis given by REDIS_HOST.
+func TestTldNsCacheRedisRoundTrip(t *testing.T) {
+	if os.Getenv("REDIS_HOST") == "" {
+		t.Skip("REDIS_HOST not set, skipping the test with Redis")
+	}
+	resetTldNsCache()
+	t.Cleanup(resetTldNsCache)
+
+	tld := "typonamer-test"
+	servers := []NameServer{{Name: "ns1.nic.typonamer-test.", Addrs: []string{"192.0.2.2"}}}
+	now := time.Now().Truncate(time.Second)
+	entry := tldNsCacheEntry{Servers: servers, CachedAt: now, ExpireAt: now.Add(time.Hour)}
+
+	if err := saveTldNsCacheEntry(tld, entry); err != nil {
+		t.Fatalf("saveTldNsCacheEntry() error = %s", err)
+	}
+	t.Cleanup(func() { ClearTldNsCache(context.Background(), tld) })
+
+	loadTldNsCache(context.Background())
+	if got := GetTldNsCache(tld); !slices.EqualFunc(got, servers, equalNameServer) {
+		t.Errorf("name servers loaded from Redis = %v, want %v", got, servers)
+	}
+}
+
+func TestParseRootHints(t *testing.T) {
+	servers, err := parseRootHints(bundledRootHints)
+	if err != nil {
+		t.Fatalf("parseRootHints() error = %s for the bundled root hints", err)
+	}
+	if len(servers) != 13 {
+		t.Errorf("parseRootHints() returned %d root servers, want 13", len(servers))
+	}
+	want := NameServer{Name: "a.root-servers.net.", Addrs: []string{"198.41.0.4", "2001:503:ba3e::2:30"}}
+	if !equalNameServer(servers[0], want) {
+		t.Errorf("first root server = %v, want %v with the IPv4 address first", servers[0], want)
+	}
+
+	invalid := []struct {
+		name  string
+		hints string
+	}{
+		{"syntax error", ". 3600000 NS\n"},
+		{"no address", ". 3600000 NS A.ROOT-SERVERS.NET.\n"},
+		{"empty", ""},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseRootHints([]byte(tt.hints)); !errors.Is(err, ErrorInvalidRootHints) {
+				t.Errorf("parseRootHints() error = %v, want %s", err, ErrorInvalidRootHints)
+			}
+		})
+	}
+}
+
+// equalNameServer reports whether the name servers have the same name and addresses.
+func equalNameServer(a, b NameServer) bool {
+	return a.Name == b.Name && slices.Equal(a.Addrs, b.Addrs)
+}
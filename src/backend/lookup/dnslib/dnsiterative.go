@@ -0,0 +1,389 @@
+package dnslib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"typonamer/config"
+	"typonamer/log"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// defaultDnsMaxDepth is the maximum number of delegations followed from the root servers if none is configured.
+	defaultDnsMaxDepth = 8
+
+	// dnsUdpSize is the EDNS0 UDP payload size advertised, the size recommended by the DNS flag day 2020 to avoid fragmentation.
+	dnsUdpSize = 1232
+
+	// dnsMaxQueries caps the queries of one resolution, including the ones resolving the name servers without glue,
+	// so a loop of delegations between the zones can not run away.
+	dnsMaxQueries = 100
+
+	// dnsMaxNesting is how many name servers without glue may be resolved inside each other.
+	dnsMaxNesting = 3
+)
+
+// dnsHostPort returns the address and port a name server address is queried at, port 53 of the address.
+// It is a variable so the tests can send the queries to their name servers on 127.0.0.1.
+var dnsHostPort = func(addr string) string {
+	return net.JoinHostPort(addr, "53")
+}
+
+var (
+	errDnsNoServer       = errors.New("no name server answered")
+	errDnsTooManyQueries = errors.New("too many dns queries")
+	errDnsTooDeep        = errors.New("too many delegations")
+)
+
+// dnsRcodeError is the error of a name server failing or refusing the query.
+type dnsRcodeError struct {
+	rcode  int
+	server string
+	addr   string
+}
+
+func (e *dnsRcodeError) Error() string {
+	return fmt.Sprintf("%s from nameserver %s (%s)", dns.RcodeToString[e.rcode], e.server, e.addr)
+}
+
+// NameServer is a name server of a zone, with its addresses taken from the glue or resolved.
+type NameServer struct {
+	Name  string   `json:"name"`  // Name is the fully qualified host name of the name server.
+	Addrs []string `json:"addrs"` // Addrs is the IPv4 and IPv6 addresses of the name server, the IPv4 ones first.
+}
+
+// walkResult is where a walk down the delegations ended.
+type walkResult struct {
+	response *dns.Msg     // response is the last response, nil if no name server answered.
+	server   string       // server is the name of the name server which gave the last response.
+	zone     string       // zone is the deepest zone reached.
+	servers  []NameServer // servers is the name servers of the deepest zone reached.
+}
+
+// iterativeResolver resolves the names by walking down the delegations from the root servers, without asking for recursion.
+// It is used for one resolution only, as it counts the queries and keeps the trace of the resolution.
+type iterativeResolver struct {
+	udpClient *dns.Client
+	tcpClient *dns.Client
+	maxDepth  int
+	queries   int                 // queries is the number of queries sent so far.
+	addrs     map[string][]string // addrs holds the resolved addresses of the name servers without glue.
+	level     int                 // level is the level of the trace of the next query.
+	indent    string              // indent is the indent of the trace of the current level.
+	trace     strings.Builder
+}
+
+// newIterativeResolver returns a resolver with the timeout and the maximum depth of the configuration.
+func newIterativeResolver() *iterativeResolver {
+	cfg := config.GetConfig()
+
+	timeout := time.Duration(cfg.DnsTimeout) * time.Second
+	maxDepth := cfg.DnsMaxDepth
+	if maxDepth <= 0 {
+		maxDepth = defaultDnsMaxDepth
+	}
+
+	return &iterativeResolver{
+		udpClient: &dns.Client{Net: "udp", Timeout: timeout, UDPSize: dnsUdpSize},
+		tcpClient: &dns.Client{Net: "tcp", Timeout: timeout},
+		maxDepth:  maxDepth,
+		addrs:     make(map[string][]string),
+	}
+}
+
+// startServers returns the zone to start the resolution of the name from and its name servers,
+// the cached name servers of the TLD of the name if there are, or else the root servers.
+func (r *iterativeResolver) startServers(name string) (string, []NameServer) {
+	labels := dns.SplitDomainName(name)
+	if len(labels) > 1 {
+		tld := labels[len(labels)-1]
+		if servers := GetTldNsCache(tld); len(servers) > 0 {
+			return dns.Fqdn(tld), servers
+		}
+	}
+	return ".", getRootServers()
+}
+
+// walk queries the name servers of the zone for the name and follows the referrals to the zones closer to the name,
+// until a server answers, denies the name or has no closer zone.
+// A referral to the zone of the name itself is the answer of an NS query, as it is the delegation of the name.
+// Only the walk of the resolution itself is traced, not the ones resolving the name servers without glue.
+func (r *iterativeResolver) walk(ctx context.Context, name string, qtype uint16, zone string, servers []NameServer, nesting int) (walkResult, error) {
+	traced := nesting == 0
+	result := walkResult{zone: zone, servers: servers}
+
+	for depth := 0; depth < r.maxDepth; depth++ {
+		if traced {
+			r.indent = strings.Repeat("│  ", r.level)
+			r.trace.WriteString("│\n")
+			r.trace.WriteString(fmt.Sprintf("%s├─ Level %d: Query for %s\n", r.indent, r.level+1, strings.TrimSuffix(name, ".")))
+			r.level++
+		}
+
+		response, server, err := r.exchange(ctx, newDnsQuery(name, qtype), servers, nesting)
+		result.server = server
+		if err != nil {
+			if traced {
+				r.trace.WriteString(fmt.Sprintf("%s└─ No nameservers found for %s (%s)\n", r.indent, strings.TrimSuffix(name, "."), err))
+			}
+			return result, err
+		}
+		result.response = response
+
+		owner, nsNames := getNsRecordsOfResponse(response)
+		if traced {
+			if len(nsNames) > 0 {
+				r.trace.WriteString(fmt.Sprintf("%s├─ Got answer for: %s\n", r.indent, strings.TrimSuffix(owner, ".")))
+				r.trace.WriteString(fmt.Sprintf("%s├─ Found nameservers: \n", r.indent))
+				for _, nsName := range nsNames {
+					r.trace.WriteString(fmt.Sprintf("%s│  ├─ %s\n", r.indent, nsName))
+				}
+				r.trace.WriteString(fmt.Sprintf("%s└─ Via: %s\n", r.indent, server))
+			} else {
+				r.trace.WriteString(fmt.Sprintf("%s└─ No nameservers found for %s (%s)\n", r.indent, strings.TrimSuffix(name, "."), describeDnsResponse(response)))
+			}
+		}
+
+		if response.Rcode != dns.RcodeSuccess || len(response.Answer) > 0 {
+			return result, nil
+		}
+
+		referral, referralNames := getReferral(response, name, zone)
+		if referral == "" {
+			return result, nil
+		}
+		referralServers := getReferralServers(response, zone, referralNames)
+
+		// The TLD name servers given by the root servers are cached, so the next resolutions start from them instead of the root servers.
+		// Only a complete referral with glue is cached, and it replaces the name servers cached before.
+		if zone == "." && dns.CountLabel(referral) == 1 && !response.Truncated && len(referralServers[0].Addrs) > 0 {
+			tld := strings.TrimSuffix(referral, ".")
+			ttl := getReferralTtl(response, referral, referralServers)
+			log.Infof("Adding NS records for '%s' to cache for %s: %v", tld, ttl, referralNames)
+			AddTldNsCache(tld, referralServers, ttl)
+		}
+
+		if qtype == dns.TypeNS && referral == name {
+			return result, nil
+		}
+
+		servers = referralServers
+		zone = referral
+		result.zone = zone
+		result.servers = servers
+	}
+
+	log.Warnf("Resolving %s stopped after %d delegations", name, r.maxDepth)
+	if traced {
+		r.trace.WriteString(fmt.Sprintf("%s└─ Stopped after %d delegations\n", r.indent, r.maxDepth))
+	}
+	return result, errDnsTooDeep
+}
+
+// exchange sends the query to the name servers in turn, until one of them answers.
+// The name servers without an address are resolved when their turn comes, so they cost nothing if a server before them answers.
+// The servers failing or refusing the query are taken as lame, and the next one is tried.
+// If no server answers, the error and the name of the last server tried are returned.
+func (r *iterativeResolver) exchange(ctx context.Context, msg *dns.Msg, servers []NameServer, nesting int) (*dns.Msg, string, error) {
+	var lastServer string
+	lastErr := errDnsNoServer
+	for i := range servers {
+		server := &servers[i]
+		if len(server.Addrs) == 0 {
+			server.Addrs = r.resolveNameServer(ctx, server.Name, nesting+1)
+		}
+
+		for _, addr := range server.Addrs {
+			if ctx.Err() != nil {
+				return nil, server.Name, ctx.Err()
+			}
+			if r.queries >= dnsMaxQueries {
+				return nil, server.Name, errDnsTooManyQueries
+			}
+
+			lastServer = server.Name
+			response, err := r.exchangeAddr(ctx, msg, addr, nesting)
+			if err != nil {
+				log.Debugf("Failed to query DNS for %s using nameserver %s (%s): %s", msg.Question[0].Name, server.Name, addr, err)
+				lastErr = fmt.Errorf("nameserver %s (%s): %w", server.Name, addr, err)
+				continue
+			}
+
+			log.Debugf("DNS query for %s using nameserver %s (%s) Rcode is %d", msg.Question[0].Name, server.Name, addr, response.Rcode)
+			if response.Rcode == dns.RcodeServerFailure || response.Rcode == dns.RcodeRefused {
+				lastErr = &dnsRcodeError{rcode: response.Rcode, server: server.Name, addr: addr}
+				continue
+			}
+			return response, server.Name, nil
+		}
+	}
+	return nil, lastServer, lastErr
+}
+
+// exchangeAddr sends the query to the address over UDP, and again over TCP if the response is truncated.
+func (r *iterativeResolver) exchangeAddr(ctx context.Context, msg *dns.Msg, addr string, nesting int) (*dns.Msg, error) {
+	hostPort := dnsHostPort(addr)
+
+	r.queries++
+	response, _, err := r.udpClient.ExchangeContext(ctx, msg, hostPort)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.Truncated {
+		log.Debugf("DNS response for %s from %s is truncated, retrying over TCP", msg.Question[0].Name, addr)
+		if nesting == 0 {
+			r.trace.WriteString(fmt.Sprintf("%s├─ Truncated response from %s, retrying over TCP\n", r.indent, addr))
+		}
+
+		r.queries++
+		response, _, err = r.tcpClient.ExchangeContext(ctx, msg, hostPort)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return response, nil
+}
+
+// resolveNameServer returns the addresses of the name server which came without glue, resolving them from the root servers.
+// The IPv6 addresses are only resolved if the name server has no IPv4 address.
+func (r *iterativeResolver) resolveNameServer(ctx context.Context, name string, nesting int) []string {
+	if addrs, ok := r.addrs[name]; ok {
+		return addrs
+	}
+	if nesting > dnsMaxNesting {
+		log.Debugf("Not resolving nameserver %s, too many nameservers without glue resolved inside each other", name)
+		return nil
+	}
+
+	var addrs []string
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		zone, servers := r.startServers(name)
+		result, err := r.walk(ctx, name, qtype, zone, servers, nesting)
+		if err != nil || result.response == nil {
+			log.Debugf("Failed to resolve %s record of nameserver %s: %v", dns.TypeToString[qtype], name, err)
+			continue
+		}
+
+		for _, rr := range result.response.Answer {
+			switch record := rr.(type) {
+			case *dns.A:
+				addrs = append(addrs, record.A.String())
+			case *dns.AAAA:
+				addrs = append(addrs, record.AAAA.String())
+			}
+		}
+		if len(addrs) > 0 {
+			break
+		}
+	}
+	r.addrs[name] = addrs
+
+	if nesting == 1 {
+		r.trace.WriteString(fmt.Sprintf("%s├─ Resolved %s without glue: %s\n", r.indent, name, strings.Join(addrs, ", ")))
+	}
+
+	return addrs
+}
+
+// newDnsQuery returns a query for the name without recursion, advertising EDNS0 so the large referrals fit in UDP.
+func newDnsQuery(name string, qtype uint16) *dns.Msg {
+	msg := new(dns.Msg)
+	msg.SetQuestion(name, qtype)
+	msg.RecursionDesired = false
+	msg.SetEdns0(dnsUdpSize, false)
+	return msg
+}
+
+// describeDnsResponse returns the response code of the response and whether it is authoritative, for the trace.
+func describeDnsResponse(response *dns.Msg) string {
+	if response.Authoritative {
+		return dns.RcodeToString[response.Rcode] + ", authoritative"
+	}
+	return dns.RcodeToString[response.Rcode] + ", not authoritative"
+}
+
+// getNsRecordsOfResponse returns the owner and the names of the NS records of the answer, or of the authority section if the answer has none.
+func getNsRecordsOfResponse(response *dns.Msg) (string, []string) {
+	records := response.Answer
+	if len(records) == 0 {
+		records = response.Ns
+	}
+
+	var owner string
+	var nsNames []string
+	for _, rr := range records {
+		if ns, ok := rr.(*dns.NS); ok {
+			owner = dns.CanonicalName(ns.Hdr.Name)
+			nsNames = append(nsNames, ns.Ns)
+		}
+	}
+	return owner, nsNames
+}
+
+// getReferral returns the zone the response refers the name to and the names of its name servers,
+// the zone is empty if the response is not a referral to a zone below the current one and above or at the name.
+func getReferral(response *dns.Msg, name string, zone string) (string, []string) {
+	var referral string
+	var nsNames []string
+	for _, rr := range response.Ns {
+		ns, ok := rr.(*dns.NS)
+		if !ok {
+			continue
+		}
+
+		owner := dns.CanonicalName(ns.Hdr.Name)
+		if referral == "" {
+			if !dns.IsSubDomain(owner, name) || !dns.IsSubDomain(zone, owner) || dns.CountLabel(owner) <= dns.CountLabel(zone) {
+				continue
+			}
+			referral = owner
+		}
+		if owner == referral {
+			nsNames = append(nsNames, dns.CanonicalName(ns.Ns))
+		}
+	}
+	return referral, nsNames
+}
+
+// getReferralServers returns the name servers of the referral, with the addresses of the glue records.
+// Only the glue of the name servers within the zone which gave the referral is trusted, the other name servers are resolved
+// when they are needed. The name servers with glue are put first.
+func getReferralServers(response *dns.Msg, zone string, nsNames []string) []NameServer {
+	glued := make([]NameServer, 0, len(nsNames))
+	unglued := make([]NameServer, 0)
+
+	for _, nsName := range nsNames {
+		server := NameServer{Name: nsName}
+		if dns.IsSubDomain(zone, nsName) {
+			var ipv6Addrs []string
+			for _, rr := range response.Extra {
+				if dns.CanonicalName(rr.Header().Name) != nsName {
+					continue
+				}
+				switch record := rr.(type) {
+				case *dns.A:
+					server.Addrs = append(server.Addrs, record.A.String())
+				case *dns.AAAA:
+					ipv6Addrs = append(ipv6Addrs, record.AAAA.String())
+				}
+			}
+			server.Addrs = append(server.Addrs, ipv6Addrs...)
+		}
+
+		if len(server.Addrs) > 0 {
+			glued = append(glued, server)
+		} else {
+			unglued = append(unglued, server)
+		}
+	}
+
+	return append(glued, unglued...)
+}
@@ -0,0 +1,207 @@
+package dnslib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"typonamer/config"
+	"typonamer/lookup/lookuperror"
+
+	"github.com/miekg/dns"
+)
+
+// testRootServers is the root server of the tests, at the address of the root test name server.
+var testRootServers = []NameServer{{Name: "a.root.test.", Addrs: []string{"192.0.2.1"}}}
+
+// startTestRootServer starts a root server referring the .test TLD to ns1.nic.test at 192.0.2.2 with glue.
+func startTestRootServer(t *testing.T) *testDnsServer {
+	t.Helper()
+
+	return startTestDnsServer(t, func(req *dns.Msg, network string) *dns.Msg {
+		if !isTestQueryIn(req, "test.") {
+			return testRcode(req, dns.RcodeNameError, true)
+		}
+		return testReferral(req, []string{"test. 172800 IN NS ns1.nic.test."}, "ns1.nic.test. 3600 IN A 192.0.2.2")
+	})
+}
+
+// answerTestTld answers the queries to the name servers of the .test TLD: example.test is delegated to ns1.example.test
+// with glue and to ns2.example.test without, the other names do not exist.
+func answerTestTld(req *dns.Msg, network string) *dns.Msg {
+	if isTestQueryIn(req, "example.test.") {
+		return testReferral(req, []string{"example.test. 3600 IN NS ns1.example.test.", "example.test. 3600 IN NS ns2.example.test."},
+			"ns1.example.test. 3600 IN A 192.0.2.3")
+	}
+	response := testRcode(req, dns.RcodeNameError, true)
+	response.Ns = testRRs("test. 900 IN SOA ns1.nic.test. hostmaster.nic.test. 1 1800 900 604800 900")
+	return response
+}
+
+func TestNsCheckGlueReferral(t *testing.T) {
+	setTestDnsConfig(t, func(cfg *config.Config) {})
+	root := startTestRootServer(t)
+	tld := startTestDnsServer(t, answerTestTld)
+	useTestDnsServers(t, map[string]*testDnsServer{"192.0.2.1": root, "192.0.2.2": tld}, testRootServers)
+
+	domainInfo, err := NsCheck(context.Background(), "example.test")
+	if err != nil {
+		t.Fatalf("NsCheck() error = %s", err)
+	}
+	if want := []string{"ns1.example.test", "ns2.example.test"}; !slices.Equal(domainInfo.NameServer, want) {
+		t.Errorf("NameServer = %v, want %v", domainInfo.NameServer, want)
+	}
+	if domainInfo.Trace.Server != "ns1.nic.test" {
+		t.Errorf("Trace.Server = %q, want ns1.nic.test", domainInfo.Trace.Server)
+	}
+
+	// The referral of the root servers with glue is cached
+	if servers := GetTldNsCache("test"); len(servers) != 1 || !slices.Equal(servers[0].Addrs, []string{"192.0.2.2"}) {
+		t.Errorf("cached name servers of test = %v, want ns1.nic.test at 192.0.2.2", servers)
+	}
+
+	// The next resolution starts from the cached name servers of the TLD
+	_, err = NsCheck(context.Background(), "free.test")
+	if !errors.Is(err, lookuperror.ErrorNsNotFound) {
+		t.Errorf("NsCheck() error = %v for a name which does not exist, want %s", err, lookuperror.ErrorNsNotFound)
+	}
+	if queries := root.Queries(); len(queries) != 1 {
+		t.Errorf("root server queried %d times, want 1 as the TLD is cached", len(queries))
+	}
+
+	// The queries advertise EDNS0 and do not ask for recursion
+	for _, query := range append(root.Queries(), tld.Queries()...) {
+		if query.udpSize != dnsUdpSize || query.recursionDesired {
+			t.Errorf("query for %s has EDNS0 UDP size %d and RD %t, want %d and false", query.name, query.udpSize, query.recursionDesired, dnsUdpSize)
+		}
+	}
+}
+
+func TestNsCheckGluelessReferral(t *testing.T) {
+	setTestDnsConfig(t, func(cfg *config.Config) {})
+
+	// The .test TLD is delegated to ns.dns.other without glue, the .other TLD to ns.nic.other with glue
+	root := startTestDnsServer(t, func(req *dns.Msg, network string) *dns.Msg {
+		switch {
+		case isTestQueryIn(req, "test."):
+			return testReferral(req, []string{"test. 172800 IN NS ns.dns.other."})
+		case isTestQueryIn(req, "other."):
+			return testReferral(req, []string{"other. 172800 IN NS ns.nic.other."}, "ns.nic.other. 3600 IN A 192.0.2.4")
+		}
+		return testRcode(req, dns.RcodeNameError, true)
+	})
+	other := startTestDnsServer(t, func(req *dns.Msg, network string) *dns.Msg {
+		if req.Question[0].Name == "ns.dns.other." && req.Question[0].Qtype == dns.TypeA {
+			return testAnswer(req, "ns.dns.other. 3600 IN A 192.0.2.2")
+		}
+		return testAnswer(req)
+	})
+	tld := startTestDnsServer(t, answerTestTld)
+	useTestDnsServers(t, map[string]*testDnsServer{"192.0.2.1": root, "192.0.2.2": tld, "192.0.2.4": other}, testRootServers)
+
+	domainInfo, err := NsCheck(context.Background(), "example.test")
+	if err != nil {
+		t.Fatalf("NsCheck() error = %s", err)
+	}
+	if want := []string{"ns1.example.test", "ns2.example.test"}; !slices.Equal(domainInfo.NameServer, want) {
+		t.Errorf("NameServer = %v, want %v", domainInfo.NameServer, want)
+	}
+	if !strings.Contains(domainInfo.RawResponse, "Resolved ns.dns.other. without glue: 192.0.2.2") {
+		t.Errorf("trace does not show the name server resolved without glue:\n%s", domainInfo.RawResponse)
+	}
+
+	// Only the referral with glue is cached
+	if HasTldNsCache("test") {
+		t.Error("referral of test without glue cached")
+	}
+	if !HasTldNsCache("other") {
+		t.Error("referral of other with glue not cached")
+	}
+}
+
+func TestNsCheckTruncatedResponseRetriedOverTcp(t *testing.T) {
+	setTestDnsConfig(t, func(cfg *config.Config) {})
+	root := startTestRootServer(t)
+	tld := startTestDnsServer(t, func(req *dns.Msg, network string) *dns.Msg {
+		if network == "udp" {
+			response := new(dns.Msg)
+			response.SetReply(req)
+			response.Truncated = true
+			return response
+		}
+		return answerTestTld(req, network)
+	})
+	useTestDnsServers(t, map[string]*testDnsServer{"192.0.2.1": root, "192.0.2.2": tld}, testRootServers)
+
+	domainInfo, err := NsCheck(context.Background(), "example.test")
+	if err != nil {
+		t.Fatalf("NsCheck() error = %s", err)
+	}
+	if len(domainInfo.NameServer) != 2 {
+		t.Errorf("NameServer = %v, want the name servers of the TCP response", domainInfo.NameServer)
+	}
+
+	networks := make([]string, 0)
+	for _, query := range tld.Queries() {
+		networks = append(networks, query.network)
+	}
+	if !slices.Equal(networks, []string{"udp", "tcp"}) {
+		t.Errorf("TLD server queried over %v, want [udp tcp]", networks)
+	}
+	if !strings.Contains(domainInfo.RawResponse, "Truncated response from 192.0.2.2, retrying over TCP") {
+		t.Errorf("trace does not show the retry over TCP:\n%s", domainInfo.RawResponse)
+	}
+}
+
+func TestNsCheckStopsAtMaxDepth(t *testing.T) {
+	setTestDnsConfig(t, func(cfg *config.Config) {
+		cfg.DnsMaxDepth = 2
+	})
+	root := startTestRootServer(t)
+	tld := startTestDnsServer(t, answerTestTld)
+	useTestDnsServers(t, map[string]*testDnsServer{"192.0.2.1": root, "192.0.2.2": tld}, testRootServers)
+
+	// The root and the TLD servers refer www.example.test further down, to the name servers of example.test
+	domainInfo, err := NsCheck(context.Background(), "www.example.test")
+	if lookuperror.KindOf(err) != lookuperror.KindServerFailure || !errors.Is(err, errDnsTooDeep) {
+		t.Errorf("NsCheck() error = %v, want a server failure of %s", err, errDnsTooDeep)
+	}
+	if !strings.Contains(domainInfo.RawResponse, "Stopped after 2 delegations") {
+		t.Errorf("trace does not show the resolution stopped:\n%s", domainInfo.RawResponse)
+	}
+}
+
+func TestWalkStopsAtMaxQueries(t *testing.T) {
+	setTestDnsConfig(t, func(cfg *config.Config) {})
+
+	// The .test TLD has more name servers than the queries allowed, and all of them fail
+	var nsRecords, glue []string
+	for i := 1; i <= dnsMaxQueries+20; i++ {
+		nsRecords = append(nsRecords, fmt.Sprintf("test. 172800 IN NS ns%d.nic.test.", i))
+		glue = append(glue, fmt.Sprintf("ns%d.nic.test. 3600 IN A 198.51.100.%d", i, i))
+	}
+	root := startTestDnsServer(t, func(req *dns.Msg, network string) *dns.Msg {
+		return testReferral(req, nsRecords, glue...)
+	})
+	failing := startTestDnsServer(t, func(req *dns.Msg, network string) *dns.Msg {
+		return testRcode(req, dns.RcodeServerFailure, false)
+	})
+	servers := map[string]*testDnsServer{"192.0.2.1": root}
+	for i := 1; i <= dnsMaxQueries+20; i++ {
+		servers[fmt.Sprintf("198.51.100.%d", i)] = failing
+	}
+	useTestDnsServers(t, servers, testRootServers)
+
+	resolver := newIterativeResolver()
+	_, err := resolver.walk(context.Background(), "example.test.", dns.TypeNS, ".", getRootServers(), 0)
+	if !errors.Is(err, errDnsTooManyQueries) {
+		t.Errorf("walk() error = %v, want %s", err, errDnsTooManyQueries)
+	}
+	// The referral is too large for UDP, so the root server is queried over UDP and TCP
+	if queries := len(failing.Queries()); queries != dnsMaxQueries-len(root.Queries()) {
+		t.Errorf("failing servers queried %d times, want %d", queries, dnsMaxQueries-len(root.Queries()))
+	}
+}
@@ -0,0 +1,584 @@
+package dnslib
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"typonamer/config"
+	"typonamer/log"
+	"typonamer/lookup/lookuperror"
+	"typonamer/lookup/lookupinfo"
+
+	"github.com/duke-git/lancet/v2/slice"
+	"github.com/duke-git/lancet/v2/strutil"
+	"github.com/miekg/dns"
+)
+
+const (
+	// dnsModeIterative walks down the delegations from the root servers, it is the default mode.
+	dnsModeIterative = "iterative"
+
+	// dnsModeRecursive asks the configured recursive resolvers over UDP, and over TCP if the response is truncated.
+	dnsModeRecursive = "recursive"
+
+	// dnsModeDot asks the configured recursive resolvers over DNS-over-TLS (RFC 7858).
+	dnsModeDot = "dot"
+
+	// dnsModeDoh asks the configured recursive resolvers over DNS-over-HTTPS (RFC 8484).
+	dnsModeDoh = "doh"
+
+	// defaultDnsResolverConcurrency is the number of queries in flight to a resolver if no concurrency limit is configured.
+	defaultDnsResolverConcurrency = 16
+
+	// systemResolvConf is the file of the system resolvers, used in the recursive mode if no resolver is configured.
+	systemResolvConf = "/etc/resolv.conf"
+
+	// dohContentType is the media type of the DNS messages of DNS-over-HTTPS.
+	dohContentType = "application/dns-message"
+
+	// dohMaxResponseSize is the maximum size of a DNS-over-HTTPS response, the maximum size of a DNS message.
+	dohMaxResponseSize = dns.MaxMsgSize
+)
+
+var errDnsNoResolver = errors.New("no dns resolver configured")
+
+// dnsResolverRootCAs is the CA certificates the certificates of the DoT and DoH resolvers are verified against, nil for the system ones.
+// It is a variable so the tests can trust the certificates of their resolvers.
+var dnsResolverRootCAs *x509.CertPool
+
+// dnsResolverSettings is the settings a resolver client is built with, the client is rebuilt when they are changed in the configuration.
+type dnsResolverSettings struct {
+	mode        string
+	address     string
+	concurrency int
+	timeout     time.Duration
+}
+
+// dnsResolverClient sends the queries to a recursive resolver, with at most its concurrency limit of queries in flight.
+type dnsResolverClient struct {
+	settings   dnsResolverSettings
+	name       string        // name is the address of the resolver shown in the trace and the errors.
+	slots      chan struct{} // slots holds a value for every query in flight.
+	udpClient  *dns.Client
+	tcpClient  *dns.Client
+	httpClient *http.Client
+}
+
+// DnsResolverTestResult is the result of a test query to a resolver.
+type DnsResolverTestResult struct {
+	Mode       string   `json:"mode"`       // Mode is the DNS mode the resolver is queried in.
+	Resolver   string   `json:"resolver"`   // Resolver is the address of the resolver, "root servers" in the iterative mode.
+	Rcode      string   `json:"rcode"`      // Rcode is the response code of the answer, empty if the resolver did not answer.
+	NameServer []string `json:"nameServer"` // NameServer is the NS records of the domain in the answer.
+	LatencyMs  int64    `json:"latencyMs"`  // LatencyMs is the duration of the query in milliseconds.
+	Error      string   `json:"error"`      // Error is the error of the query, empty if the resolver answered.
+}
+
+var (
+	// dnsResolverClients holds the clients of the resolvers by their mode and address, shared by all DNS checks.
+	dnsResolverClients    = make(map[string]*dnsResolverClient)
+	dnsResolverClientsMux sync.Mutex
+
+	// dnsResolverNext spreads the first queries of the checks over the resolvers.
+	dnsResolverNext atomic.Uint64
+)
+
+// getDnsMode returns the configured DNS mode, the iterative mode if it is not set or unknown.
+func getDnsMode() string {
+	mode := strings.ToLower(strutil.Trim(config.GetConfig().DnsMode))
+	switch mode {
+	case dnsModeRecursive, dnsModeDot, dnsModeDoh:
+		return mode
+	case "", dnsModeIterative:
+		return dnsModeIterative
+	default:
+		log.Warnf("Unknown DNS mode %s, using the %s mode", mode, dnsModeIterative)
+		return dnsModeIterative
+	}
+}
+
+// getDnsResolverClients returns the clients of the resolvers of the mode in the order of the configuration.
+// The recursive mode uses the system resolvers if no resolver is configured.
+func getDnsResolverClients(mode string) ([]*dnsResolverClient, error) {
+	cfg := config.GetConfig()
+	timeout := time.Duration(cfg.DnsTimeout) * time.Second
+
+	resolvers := cfg.DnsResolvers
+	if len(resolvers) == 0 && mode == dnsModeRecursive {
+		resolvers = getSystemResolvers()
+	}
+	if len(resolvers) == 0 {
+		return nil, errDnsNoResolver
+	}
+
+	dnsResolverClientsMux.Lock()
+	defer dnsResolverClientsMux.Unlock()
+
+	clients := make([]*dnsResolverClient, 0, len(resolvers))
+	for _, resolver := range resolvers {
+		settings := dnsResolverSettings{
+			mode:        mode,
+			address:     strutil.Trim(resolver.Address),
+			concurrency: resolver.ConcurrencyLimit,
+			timeout:     timeout,
+		}
+		if settings.address == "" {
+			continue
+		}
+		if settings.concurrency <= 0 {
+			settings.concurrency = defaultDnsResolverConcurrency
+		}
+
+		key := mode + "|" + settings.address
+		client, ok := dnsResolverClients[key]
+		if !ok || client.settings != settings {
+			var err error
+			client, err = newDnsResolverClient(settings)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				log.Infof("DNS resolver %s settings changed, rebuilding its client", settings.address)
+			}
+			dnsResolverClients[key] = client
+		}
+		clients = append(clients, client)
+	}
+
+	if len(clients) == 0 {
+		return nil, errDnsNoResolver
+	}
+	return clients, nil
+}
+
+// getSystemResolvers returns the resolvers of the system resolv.conf, none if it can not be read.
+func getSystemResolvers() []config.DnsResolver {
+	clientConfig, err := dns.ClientConfigFromFile(systemResolvConf)
+	if err != nil {
+		log.Warnf("Failed to read the system resolvers from %s: %s", systemResolvConf, err)
+		return nil
+	}
+
+	return slice.Map(clientConfig.Servers, func(_ int, server string) config.DnsResolver {
+		return config.DnsResolver{Address: net.JoinHostPort(server, clientConfig.Port)}
+	})
+}
+
+// newDnsResolverClient builds the client of the resolver for its mode.
+// The address is a host with an optional port for the recursive and DoT modes, and the URL of the resolver for the DoH mode.
+func newDnsResolverClient(settings dnsResolverSettings) (*dnsResolverClient, error) {
+	client := &dnsResolverClient{
+		settings: settings,
+		name:     settings.address,
+		slots:    make(chan struct{}, settings.concurrency),
+	}
+
+	switch settings.mode {
+	case dnsModeRecursive:
+		client.name = withDefaultPort(settings.address, "53")
+		client.udpClient = &dns.Client{Net: "udp", Timeout: settings.timeout, UDPSize: dnsUdpSize}
+		client.tcpClient = &dns.Client{Net: "tcp", Timeout: settings.timeout}
+	case dnsModeDot:
+		client.name = withDefaultPort(settings.address, "853")
+		host, _, _ := net.SplitHostPort(client.name)
+		// The certificate of the resolver is verified against its host, an IP address is checked against the IP addresses of the certificate
+		client.tcpClient = &dns.Client{
+			Net:       "tcp-tls",
+			Timeout:   settings.timeout,
+			TLSConfig: &tls.Config{ServerName: host, RootCAs: dnsResolverRootCAs, MinVersion: tls.VersionTLS12},
+		}
+	case dnsModeDoh:
+		dohUrl, err := url.Parse(settings.address)
+		if err != nil || dohUrl.Host == "" || (dohUrl.Scheme != "https" && dohUrl.Scheme != "http") {
+			return nil, fmt.Errorf("invalid DNS-over-HTTPS URL %s", settings.address)
+		}
+		client.httpClient = &http.Client{
+			Timeout: settings.timeout,
+			Transport: &http.Transport{
+				Proxy:               http.ProxyFromEnvironment,
+				TLSClientConfig:     &tls.Config{RootCAs: dnsResolverRootCAs, MinVersion: tls.VersionTLS12},
+				TLSHandshakeTimeout: settings.timeout,
+				MaxIdleConnsPerHost: settings.concurrency,
+				IdleConnTimeout:     90 * time.Second,
+				ForceAttemptHTTP2:   true,
+			},
+		}
+	}
+
+	return client, nil
+}
+
+// exchange sends the query to the resolver, waiting for a free slot of its concurrency limit.
+func (c *dnsResolverClient) exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	select {
+	case c.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-c.slots }()
+
+	switch c.settings.mode {
+	case dnsModeDot:
+		response, _, err := c.tcpClient.ExchangeContext(ctx, msg, c.name)
+		return response, err
+	case dnsModeDoh:
+		return c.exchangeHttps(ctx, msg)
+	default:
+		response, _, err := c.udpClient.ExchangeContext(ctx, msg, c.name)
+		if err != nil {
+			return nil, err
+		}
+		if response.Truncated {
+			log.Debugf("DNS response for %s from %s is truncated, retrying over TCP", msg.Question[0].Name, c.name)
+			response, _, err = c.tcpClient.ExchangeContext(ctx, msg, c.name)
+		}
+		return response, err
+	}
+}
+
+// exchangeHttps posts the query to the DNS-over-HTTPS resolver.
+// The ID of the query is 0 as recommended by RFC 8484, so the responses can be cached by the HTTP caches.
+func (c *dnsResolverClient) exchangeHttps(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	query := msg.Copy()
+	query.Id = 0
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.settings.address, bytes.NewReader(packed))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", dohContentType)
+	req.Header.Set("Accept", dohContentType)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DNS-over-HTTPS resolver returned HTTP %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, dohMaxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+
+	response := new(dns.Msg)
+	err = response.Unpack(body)
+	if err != nil {
+		return nil, err
+	}
+	response.Id = msg.Id
+
+	return response, nil
+}
+
+// recursiveResolver resolves the names with the recursive resolvers, asking the next resolver when one fails or refuses the query.
+// It is used for one resolution only, as it keeps the trace of the resolution.
+type recursiveResolver struct {
+	clients []*dnsResolverClient
+	trace   strings.Builder
+}
+
+// newRecursiveResolver returns a resolver with the configured resolvers of the mode, starting from the next one in turn.
+func newRecursiveResolver(mode string) (*recursiveResolver, error) {
+	clients, err := getDnsResolverClients(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	start := int(dnsResolverNext.Add(1) % uint64(len(clients)))
+	return &recursiveResolver{
+		clients: append(clients[start:], clients[:start]...),
+	}, nil
+}
+
+// exchange sends the query to the resolvers in turn, until one of them answers.
+// If no resolver answers, the error and the address of the last resolver tried are returned.
+func (r *recursiveResolver) exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, string, error) {
+	var lastResolver string
+	lastErr := errDnsNoServer
+	for _, client := range r.clients {
+		if ctx.Err() != nil {
+			return nil, client.name, ctx.Err()
+		}
+
+		lastResolver = client.name
+		response, err := client.exchange(ctx, msg)
+		if err != nil {
+			log.Debugf("Failed to query DNS for %s using resolver %s: %s", msg.Question[0].Name, client.name, err)
+			lastErr = fmt.Errorf("resolver %s: %w", client.name, err)
+			continue
+		}
+
+		log.Debugf("DNS query for %s using resolver %s Rcode is %d", msg.Question[0].Name, client.name, response.Rcode)
+		if response.Rcode == dns.RcodeServerFailure || response.Rcode == dns.RcodeRefused {
+			lastErr = &dnsRcodeError{rcode: response.Rcode, server: client.name, addr: client.settings.mode}
+			continue
+		}
+		return response, client.name, nil
+	}
+	return nil, lastResolver, lastErr
+}
+
+// query answers the queries of the DNS signals, a domain is lame if the resolvers fail its SOA query.
+func (r *recursiveResolver) query(ctx context.Context, name string, qtype uint16) *dns.Msg {
+	response, _, err := r.exchange(ctx, newRecursiveDnsQuery(name, qtype))
+	if err != nil {
+		return nil
+	}
+	if response.Rcode != dns.RcodeSuccess && response.Rcode != dns.RcodeNameError {
+		return nil
+	}
+	return response
+}
+
+// nsCheckRecursive resolves the NS records of the domain with the recursive resolvers of the mode.
+// The resolvers are trusted for the existence of the domain, so their NXDOMAIN and NODATA answers mean the domain is not delegated.
+func nsCheckRecursive(ctx context.Context, domainInfo lookupinfo.DomainInfo, name string, mode string) (lookupinfo.DomainInfo, error) {
+	domain := domainInfo.DomainName
+
+	resolver, err := newRecursiveResolver(mode)
+	if err != nil {
+		log.Errorf("Failed to set up the DNS resolvers of the %s mode: %s", mode, err)
+		return domainInfo, lookuperror.New(lookuperror.ErrorDnsServerFailed, "", err)
+	}
+
+	resolver.trace.WriteString("┌─ DNS Resolution Trace\n")
+	resolver.trace.WriteString(fmt.Sprintf("├─ Target: %s\n", domain))
+	resolver.trace.WriteString(fmt.Sprintf("├─ Resolvers (%s): \n", mode))
+	for _, client := range resolver.clients {
+		resolver.trace.WriteString(fmt.Sprintf("│  ├─ %s\n", client.name))
+	}
+	resolver.trace.WriteString("│\n")
+	resolver.trace.WriteString(fmt.Sprintf("├─ Query for %s\n", domain))
+
+	response, server, err := resolver.exchange(ctx, newRecursiveDnsQuery(name, dns.TypeNS))
+	domainInfo.Trace.Server = server
+	if ctx.Err() != nil {
+		log.Debugf("Resolving NS record for domain %s canceled", domain)
+		domainInfo.RawResponse = resolver.trace.String()
+		return domainInfo, lookuperror.New(lookuperror.ErrorLookupCanceled, server, ctx.Err())
+	}
+
+	// The resolvers fail the NS query of a domain whose name servers are lame, while the iterative mode takes its delegation
+	// from the parent zone, so the delegation is looked up there the same way if it is enabled
+	var rcodeErr *dnsRcodeError
+	if errors.As(err, &rcodeErr) && rcodeErr.rcode == dns.RcodeServerFailure && config.GetConfig().DnsParentZoneFallback {
+		resolver.trace.WriteString(fmt.Sprintf("├─ No nameservers found for %s (%s), looking up the delegation in the parent zone\n", domain, err))
+		return nsCheckParentZone(ctx, domainInfo, name, &resolver.trace, err)
+	}
+
+	nsRecords := make([]string, 0)
+	if err != nil {
+		log.Debugf("Failed to resolve NS record for domain %s: %s", domain, err)
+		resolver.trace.WriteString(fmt.Sprintf("└─ No nameservers found for %s (%s)\n", domain, err))
+	} else {
+		nsRecords = getNsRecordsOfName(response, name)
+		if len(nsRecords) > 0 {
+			resolver.trace.WriteString("├─ Found nameservers: \n")
+			for _, nsRecord := range nsRecords {
+				resolver.trace.WriteString(fmt.Sprintf("│  ├─ %s\n", nsRecord))
+			}
+			resolver.trace.WriteString(fmt.Sprintf("└─ Via: %s\n", server))
+		} else {
+			resolver.trace.WriteString(fmt.Sprintf("└─ No nameservers found for %s (%s)\n", domain, dns.RcodeToString[response.Rcode]))
+		}
+	}
+
+	if len(nsRecords) > 0 && config.GetConfig().DnsCollectSignals {
+		nsNames := slice.Map(nsRecords, func(_ int, nsRecord string) string {
+			return dns.Fqdn(nsRecord)
+		})
+		domainInfo.DnsSignals = collectDnsSignals(ctx, name, resolver, nsNames, &resolver.trace)
+		if ctx.Err() != nil {
+			log.Debugf("Collecting DNS signals for domain %s canceled", domain)
+			domainInfo.RawResponse = resolver.trace.String()
+			return domainInfo, lookuperror.New(lookuperror.ErrorLookupCanceled, server, ctx.Err())
+		}
+	}
+
+	resolver.trace.WriteString("│\n")
+	resolver.trace.WriteString("└─ Resolution Complete\n")
+
+	domainInfo.NameServer = nsRecords
+	domainInfo.RawResponse = resolver.trace.String()
+
+	if len(nsRecords) == 0 {
+		nsErr := classifyRecursiveNsFailure(domain, server, response, err)
+		log.Infof("No nameservers found for %s: %s", domain, nsErr)
+		return domainInfo, nsErr
+	}
+
+	log.Infof("Found NS record for %s are: %v", domain, nsRecords)
+	return domainInfo, nil
+}
+
+// nsCheckParentZone resolves the NS records of the domain from the root servers as the iterative mode does, after the resolvers
+// failed them. The DNS signals are collected from the name servers of the domain, so a lame delegation is reported as lame.
+// If the parent zone can not be reached either, the error of the resolvers is returned.
+func nsCheckParentZone(ctx context.Context, domainInfo lookupinfo.DomainInfo, name string, trace *strings.Builder, resolversErr error) (lookupinfo.DomainInfo, error) {
+	domain := domainInfo.DomainName
+
+	walker := newIterativeResolver()
+	zone, servers := walker.startServers(name)
+	result, err := walker.walk(ctx, name, dns.TypeNS, zone, servers, 0)
+	trace.WriteString(walker.trace.String())
+	if ctx.Err() != nil {
+		log.Debugf("Resolving NS record for domain %s canceled", domain)
+		domainInfo.RawResponse = trace.String()
+		return domainInfo, lookuperror.New(lookuperror.ErrorLookupCanceled, strutil.Trim(result.server, "."), ctx.Err())
+	}
+	if err != nil {
+		log.Debugf("Failed to resolve NS record for domain %s in the parent zone: %s", domain, err)
+		trace.WriteString("│\n")
+		trace.WriteString("└─ Resolution Complete\n")
+		domainInfo.RawResponse = trace.String()
+		return domainInfo, classifyRecursiveNsFailure(domain, domainInfo.Trace.Server, nil, resolversErr)
+	}
+
+	domainInfo.Trace.Server = strutil.Trim(result.server, ".")
+	nsRecords := getNsRecordsOfName(result.response, name)
+
+	if len(nsRecords) > 0 && config.GetConfig().DnsCollectSignals {
+		nsNames := slice.Map(nsRecords, func(_ int, nsRecord string) string {
+			return dns.Fqdn(nsRecord)
+		})
+		source := newAuthoritativeSignalSource(walker, result, nsNames)
+		domainInfo.DnsSignals = collectDnsSignals(ctx, name, source, nsNames, trace)
+		if ctx.Err() != nil {
+			log.Debugf("Collecting DNS signals for domain %s canceled", domain)
+			domainInfo.RawResponse = trace.String()
+			return domainInfo, lookuperror.New(lookuperror.ErrorLookupCanceled, domainInfo.Trace.Server, ctx.Err())
+		}
+	}
+
+	trace.WriteString("│\n")
+	trace.WriteString("└─ Resolution Complete\n")
+
+	domainInfo.NameServer = nsRecords
+	domainInfo.RawResponse = trace.String()
+
+	if len(nsRecords) == 0 {
+		nsErr := classifyNsFailure(domain, result, nil)
+		log.Infof("No nameservers found for %s: %s", domain, nsErr)
+		return domainInfo, nsErr
+	}
+
+	log.Infof("Found NS record for %s in the parent zone are: %v", domain, nsRecords)
+	return domainInfo, nil
+}
+
+// classifyRecursiveNsFailure returns the error of the recursive NS resolution of the domain which found no NS record.
+// The NXDOMAIN and NODATA answers mean the domain is not delegated, the timeouts and the failing or refusing resolvers are errors.
+func classifyRecursiveNsFailure(domain string, server string, response *dns.Msg, err error) error {
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return lookuperror.New(lookuperror.ErrorDnsTimeout, server, err)
+		}
+		return lookuperror.New(lookuperror.ErrorDnsServerFailed, server, err)
+	}
+
+	switch response.Rcode {
+	case dns.RcodeNameError, dns.RcodeSuccess:
+		return lookuperror.Newf(lookuperror.ErrorNsNotFound, server, "%s", domain)
+	default:
+		return lookuperror.Newf(lookuperror.ErrorDnsServerFailed, server, "%s answer for %s", dns.RcodeToString[response.Rcode], domain)
+	}
+}
+
+// TestDnsResolvers queries every resolver of the configured mode for the NS records of the domain, so the resolvers can be
+// checked from this host. In the iterative mode the domain is resolved from the root servers.
+func TestDnsResolvers(ctx context.Context, domain string) ([]DnsResolverTestResult, error) {
+	parts := strings.Split(strutil.Trim(domain, "."), ".")
+	if len(parts) < 2 {
+		return nil, lookuperror.Newf(lookuperror.ErrorInvalidDomainName, "", "%s", domain)
+	}
+	name := dns.CanonicalName(strutil.Trim(domain, "."))
+
+	mode := getDnsMode()
+	if mode == dnsModeIterative {
+		start := time.Now()
+		domainInfo, err := NsCheck(ctx, domain)
+		result := DnsResolverTestResult{
+			Mode:       mode,
+			Resolver:   "root servers",
+			NameServer: domainInfo.NameServer,
+			LatencyMs:  time.Since(start).Milliseconds(),
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		return []DnsResolverTestResult{result}, nil
+	}
+
+	clients, err := getDnsResolverClients(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]DnsResolverTestResult, len(clients))
+	var wg sync.WaitGroup
+	for i, client := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			result := DnsResolverTestResult{
+				Mode:       mode,
+				Resolver:   client.name,
+				NameServer: []string{},
+			}
+
+			start := time.Now()
+			response, err := client.exchange(ctx, newRecursiveDnsQuery(name, dns.TypeNS))
+			result.LatencyMs = time.Since(start).Milliseconds()
+			if err != nil {
+				result.Error = err.Error()
+			} else {
+				result.Rcode = dns.RcodeToString[response.Rcode]
+				result.NameServer = getNsRecordsOfName(response, name)
+			}
+
+			results[i] = result
+		}()
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+// newRecursiveDnsQuery returns a query for the name asking for recursion, advertising EDNS0 so the large answers fit in UDP.
+// The DNSSEC validation is disabled, so a domain with broken DNSSEC is answered as in the iterative mode, which does not validate.
+func newRecursiveDnsQuery(name string, qtype uint16) *dns.Msg {
+	msg := newDnsQuery(name, qtype)
+	msg.RecursionDesired = true
+	msg.CheckingDisabled = true
+	return msg
+}
+
+// withDefaultPort returns the address with the port, or with the default port if it has none.
+func withDefaultPort(address string, defaultPort string) string {
+	if _, _, err := net.SplitHostPort(address); err == nil {
+		return address
+	}
+	return net.JoinHostPort(strings.Trim(address, "[]"), defaultPort)
+}
@@ -0,0 +1,375 @@
+package dnslib
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"typonamer/config"
+	"typonamer/constant"
+	"typonamer/lookup/lookuperror"
+
+	"github.com/miekg/dns"
+)
+
+// answerTestResolver answers as a recursive resolver: example.test is delegated to ns1.example.test and ns2.example.test,
+// the other names do not exist.
+func answerTestResolver(req *dns.Msg, network string) *dns.Msg {
+	var response *dns.Msg
+	if req.Question[0].Name == "example.test." && req.Question[0].Qtype == dns.TypeNS {
+		response = testAnswer(req, "example.test. 3600 IN NS ns1.example.test.", "example.test. 3600 IN NS ns2.example.test.")
+	} else {
+		response = testRcode(req, dns.RcodeNameError, false)
+		response.Ns = testRRs("test. 900 IN SOA ns1.nic.test. hostmaster.nic.test. 1 1800 900 604800 900")
+	}
+	response.Authoritative = false
+	response.RecursionAvailable = true
+	return response
+}
+
+// trustTestResolverCertificate makes the DoT and DoH clients trust the certificate of the test resolvers, until the end of the test.
+func trustTestResolverCertificate(t *testing.T, certificate *x509.Certificate) {
+	t.Helper()
+
+	previous := dnsResolverRootCAs
+	dnsResolverRootCAs = x509.NewCertPool()
+	dnsResolverRootCAs.AddCert(certificate)
+	t.Cleanup(func() {
+		dnsResolverRootCAs = previous
+	})
+}
+
+// startTestDotServer starts a DNS-over-TLS resolver on a random port of 127.0.0.1, with the certificate of httptest valid for 127.0.0.1.
+func startTestDotServer(t *testing.T, answer func(req *dns.Msg, network string) *dns.Msg) *testDnsServer {
+	t.Helper()
+
+	certServer := httptest.NewTLSServer(http.NotFoundHandler())
+	certificates := certServer.TLS.Certificates
+	trustTestResolverCertificate(t, certServer.Certificate())
+	certServer.Close()
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: certificates})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &testDnsServer{hostPort: listener.Addr().String(), answer: answer}
+
+	dnsServer := &dns.Server{Listener: listener, Handler: server}
+	started := make(chan struct{})
+	dnsServer.NotifyStartedFunc = func() { close(started) }
+	go dnsServer.ActivateAndServe()
+	<-started
+	t.Cleanup(func() { dnsServer.Shutdown() })
+
+	return server
+}
+
+// startTestDohServer starts a DNS-over-HTTPS resolver on 127.0.0.1, and returns it with its URL.
+func startTestDohServer(t *testing.T, answer func(req *dns.Msg, network string) *dns.Msg) (*testDnsServer, string) {
+	t.Helper()
+
+	server := &testDnsServer{answer: answer}
+	httpServer := httptest.NewTLSServer(server)
+	t.Cleanup(httpServer.Close)
+	trustTestResolverCertificate(t, httpServer.Certificate())
+	server.hostPort = httpServer.Listener.Addr().String()
+
+	return server, httpServer.URL + "/dns-query"
+}
+
+// ServeHTTP answers the DNS-over-HTTPS queries posted to the test resolver.
+func (s *testDnsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	req := new(dns.Msg)
+	if err != nil || r.Method != http.MethodPost || r.Header.Get("Content-Type") != dohContentType || req.Unpack(body) != nil {
+		http.Error(w, "invalid DNS-over-HTTPS query", http.StatusBadRequest)
+		return
+	}
+
+	response := s.respond(req, "https")
+	if response == nil {
+		http.Error(w, "no answer", http.StatusBadGateway)
+		return
+	}
+	packed, err := response.Pack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", dohContentType)
+	w.Write(packed)
+}
+
+func TestNsCheckResolverModes(t *testing.T) {
+	tests := []struct {
+		mode    string
+		network string
+		start   func(t *testing.T) (*testDnsServer, string)
+	}{
+		{
+			mode:    dnsModeRecursive,
+			network: "udp",
+			start: func(t *testing.T) (*testDnsServer, string) {
+				server := startTestDnsServer(t, answerTestResolver)
+				return server, server.hostPort
+			},
+		},
+		{
+			mode:    dnsModeDot,
+			network: "tcp",
+			start: func(t *testing.T) (*testDnsServer, string) {
+				server := startTestDotServer(t, answerTestResolver)
+				return server, server.hostPort
+			},
+		},
+		{
+			mode:    dnsModeDoh,
+			network: "https",
+			start: func(t *testing.T) (*testDnsServer, string) {
+				return startTestDohServer(t, answerTestResolver)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			server, address := tt.start(t)
+			setTestDnsConfig(t, func(cfg *config.Config) {
+				cfg.DnsMode = tt.mode
+				cfg.DnsResolvers = []config.DnsResolver{{Address: address}}
+				cfg.DnsCollectSignals = false
+			})
+
+			domainInfo, err := NsCheck(context.Background(), "example.test")
+			if err != nil {
+				t.Fatalf("NsCheck() error = %s", err)
+			}
+			if want := []string{"ns1.example.test", "ns2.example.test"}; !slices.Equal(domainInfo.NameServer, want) {
+				t.Errorf("NameServer = %v, want %v", domainInfo.NameServer, want)
+			}
+
+			// The resolvers are trusted for the names which do not exist
+			if _, err := NsCheck(context.Background(), "free.test"); !errors.Is(err, lookuperror.ErrorNsNotFound) {
+				t.Errorf("NsCheck() error = %v for a name which does not exist, want %s", err, lookuperror.ErrorNsNotFound)
+			}
+
+			// The queries ask for recursion without DNSSEC validation
+			queries := server.Queries()
+			if len(queries) != 2 {
+				t.Fatalf("resolver queried %d times, want 2", len(queries))
+			}
+			for _, query := range queries {
+				if query.network != tt.network || !query.recursionDesired || !query.checkingDisabled {
+					t.Errorf("query for %s sent over %s with RD %t and CD %t, want %s with RD and CD", query.name, query.network,
+						query.recursionDesired, query.checkingDisabled, tt.network)
+				}
+			}
+		})
+	}
+}
+
+func TestNsCheckResolverFallback(t *testing.T) {
+	tests := []struct {
+		name  string
+		start func(t *testing.T) (*testDnsServer, string)
+	}{
+		{
+			name: "refused",
+			start: func(t *testing.T) (*testDnsServer, string) {
+				server := startTestDnsServer(t, func(req *dns.Msg, network string) *dns.Msg {
+					return testRcode(req, dns.RcodeRefused, false)
+				})
+				return server, server.hostPort
+			},
+		},
+		{
+			name: "server failure",
+			start: func(t *testing.T) (*testDnsServer, string) {
+				server := startTestDnsServer(t, func(req *dns.Msg, network string) *dns.Msg {
+					return testRcode(req, dns.RcodeServerFailure, false)
+				})
+				return server, server.hostPort
+			},
+		},
+		{
+			name: "unreachable",
+			start: func(t *testing.T) (*testDnsServer, string) {
+				// Nothing listens on port 1, the query fails at once
+				return nil, net.JoinHostPort("127.0.0.1", "1")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failing, failingAddress := tt.start(t)
+			good := startTestDnsServer(t, answerTestResolver)
+			setTestDnsConfig(t, func(cfg *config.Config) {
+				cfg.DnsMode = dnsModeRecursive
+				cfg.DnsResolvers = []config.DnsResolver{{Address: failingAddress}, {Address: good.hostPort}}
+				cfg.DnsCollectSignals = false
+			})
+
+			// The checks start from the resolvers in turn, the failing resolver is skipped when it comes first
+			for range 2 {
+				domainInfo, err := NsCheck(context.Background(), "example.test")
+				if err != nil {
+					t.Fatalf("NsCheck() error = %s", err)
+				}
+				if len(domainInfo.NameServer) != 2 || domainInfo.Trace.Server != good.hostPort {
+					t.Errorf("NameServer = %v via %s, want the 2 name servers via %s", domainInfo.NameServer, domainInfo.Trace.Server, good.hostPort)
+				}
+			}
+
+			if failing != nil && len(failing.Queries()) != 1 {
+				t.Errorf("failing resolver queried %d times, want 1", len(failing.Queries()))
+			}
+			if queries := len(good.Queries()); queries != 2 {
+				t.Errorf("resolver queried %d times, want 2", queries)
+			}
+		})
+	}
+}
+
+func TestDnsResolverClientSlots(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	server := startTestDnsServer(t, func(req *dns.Msg, network string) *dns.Msg {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			highest := maxInFlight.Load()
+			if current <= highest || maxInFlight.CompareAndSwap(highest, current) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		return answerTestResolver(req, network)
+	})
+
+	client, err := newDnsResolverClient(dnsResolverSettings{mode: dnsModeRecursive, address: server.hostPort, concurrency: 2, timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// No more queries than the concurrency limit are in flight
+	var wg sync.WaitGroup
+	errs := make(chan error, 6)
+	for range 6 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.exchange(context.Background(), newRecursiveDnsQuery("example.test.", dns.TypeNS))
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("exchange() error = %s", err)
+		}
+	}
+	if highest := maxInFlight.Load(); highest != 2 {
+		t.Errorf("%d queries in flight at most, want the concurrency limit 2", highest)
+	}
+	if len(client.slots) != 0 {
+		t.Errorf("%d slots still taken after the queries", len(client.slots))
+	}
+
+	// A query waiting for a slot gives up when its context is done, without being sent
+	client.slots <- struct{}{}
+	client.slots <- struct{}{}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.exchange(ctx, newRecursiveDnsQuery("example.test.", dns.TypeNS)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("exchange() error = %v waiting for a slot, want %s", err, context.DeadlineExceeded)
+	}
+	if queries := len(server.Queries()); queries != 6 {
+		t.Errorf("resolver queried %d times, want 6", queries)
+	}
+}
+
+func TestNsCheckResolverServfailParentZoneFallback(t *testing.T) {
+	root := startTestRootServer(t)
+	tld := startTestDnsServer(t, answerTestTld)
+	// The name servers of example.test are not reachable, so the resolver fails the queries of its names
+	resolver := startTestDnsServer(t, func(req *dns.Msg, network string) *dns.Msg {
+		return testRcode(req, dns.RcodeServerFailure, false)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		setTestDnsConfig(t, func(cfg *config.Config) {
+			cfg.DnsMode = dnsModeRecursive
+			cfg.DnsResolvers = []config.DnsResolver{{Address: resolver.hostPort}}
+		})
+		useTestDnsServers(t, map[string]*testDnsServer{"192.0.2.1": root, "192.0.2.2": tld}, testRootServers)
+
+		// The error of the resolvers is returned without querying the root servers
+		if _, err := NsCheck(context.Background(), "example.test"); !errors.Is(err, lookuperror.ErrorDnsServerFailed) {
+			t.Errorf("NsCheck() error = %v, want %s", err, lookuperror.ErrorDnsServerFailed)
+		}
+		if queries := len(root.Queries()) + len(tld.Queries()); queries != 0 {
+			t.Errorf("parent zone queried %d times, want none as the fallback is disabled", queries)
+		}
+	})
+
+	for _, mode := range []string{dnsModeIterative, dnsModeRecursive} {
+		t.Run(mode, func(t *testing.T) {
+			setTestDnsConfig(t, func(cfg *config.Config) {
+				cfg.DnsMode = mode
+				cfg.DnsResolvers = []config.DnsResolver{{Address: resolver.hostPort}}
+				cfg.DnsCollectSignals = true
+				cfg.DnsParentZoneFallback = true
+			})
+			useTestDnsServers(t, map[string]*testDnsServer{"192.0.2.1": root, "192.0.2.2": tld}, testRootServers)
+
+			// The domain is taken, with lame name servers
+			domainInfo, err := NsCheck(context.Background(), "example.test")
+			if err != nil {
+				t.Fatalf("NsCheck() error = %s", err)
+			}
+			if want := []string{"ns1.example.test", "ns2.example.test"}; !slices.Equal(domainInfo.NameServer, want) {
+				t.Errorf("NameServer = %v, want %v", domainInfo.NameServer, want)
+			}
+			if domainInfo.Trace.Server != "ns1.nic.test" {
+				t.Errorf("Trace.Server = %q, want ns1.nic.test", domainInfo.Trace.Server)
+			}
+			if domainInfo.DnsSignals.State != constant.DnsStateLame || !domainInfo.DnsSignals.Lame {
+				t.Errorf("DNS state = %s with lame %t, want %s", domainInfo.DnsSignals.State, domainInfo.DnsSignals.Lame, constant.DnsStateLame)
+			}
+
+			// The parent zone tells the names which do not exist
+			if _, err := NsCheck(context.Background(), "free.test"); !errors.Is(err, lookuperror.ErrorNsNotFound) {
+				t.Errorf("NsCheck() error = %v for a name which does not exist, want %s", err, lookuperror.ErrorNsNotFound)
+			}
+		})
+	}
+
+	t.Run("parent zone unreachable", func(t *testing.T) {
+		setTestDnsConfig(t, func(cfg *config.Config) {
+			cfg.DnsMode = dnsModeRecursive
+			cfg.DnsResolvers = []config.DnsResolver{{Address: resolver.hostPort}}
+			cfg.DnsParentZoneFallback = true
+		})
+		useTestDnsServers(t, map[string]*testDnsServer{}, testRootServers)
+
+		// The error of the resolvers is returned
+		domainInfo, err := NsCheck(context.Background(), "example.test")
+		if !errors.Is(err, lookuperror.ErrorDnsServerFailed) {
+			t.Errorf("NsCheck() error = %v, want %s", err, lookuperror.ErrorDnsServerFailed)
+		}
+		if domainInfo.Trace.Server != resolver.hostPort {
+			t.Errorf("Trace.Server = %q, want the resolver %s", domainInfo.Trace.Server, resolver.hostPort)
+		}
+	})
+}
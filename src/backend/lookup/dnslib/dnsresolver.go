@@ -1,11 +1,12 @@
 package dnslib
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
+	"slices"
 	"strings"
-	"sync"
-	"time"
 
 	"typonamer/config"
 	"typonamer/constant"
@@ -13,62 +14,18 @@ import (
 	"typonamer/lookup/lookuperror"
 	"typonamer/lookup/lookupinfo"
 
+	"github.com/duke-git/lancet/v2/slice"
 	"github.com/duke-git/lancet/v2/strutil"
 	"github.com/miekg/dns"
 )
 
-type NsCache struct {
-	TldNsMap map[string][]string
-	mux      sync.RWMutex
-}
-
-var (
-	rootServers = []string{
-		"a.root-servers.net.",
-		"b.root-servers.net.",
-		"c.root-servers.net.",
-		"d.root-servers.net.",
-		"e.root-servers.net.",
-		"f.root-servers.net.",
-		"g.root-servers.net.",
-		"h.root-servers.net.",
-		"i.root-servers.net.",
-		"j.root-servers.net.",
-		"k.root-servers.net.",
-		"l.root-servers.net.",
-		"m.root-servers.net.",
-	}
-
-	TldNsCache = NsCache{
-		TldNsMap: make(map[string][]string),
-	}
-)
-
-func HasTldNsCache(tld string) bool {
-	TldNsCache.mux.RLock()
-	defer TldNsCache.mux.RUnlock()
-	if _, ok := TldNsCache.TldNsMap[tld]; ok {
-		return true
-	}
-	return false
-}
-
-func AddTldNsCache(tld string, nameServers []string) {
-	TldNsCache.mux.Lock()
-	defer TldNsCache.mux.Unlock()
-	TldNsCache.TldNsMap[tld] = nameServers
-}
-
-func GetTldNsCache(tld string) []string {
-	TldNsCache.mux.RLock()
-	defer TldNsCache.mux.RUnlock()
-	if _, ok := TldNsCache.TldNsMap[tld]; ok {
-		return TldNsCache.TldNsMap[tld]
-	}
-	return []string{}
-}
-
-func NsCheck(domain string) (lookupinfo.DomainInfo, error) {
+// NsCheck resolves the NS records of the domain in the configured DNS mode. In the iterative mode, the default,
+// it walks down the delegations from the root servers, in the other modes it asks the configured recursive resolvers.
+// If enabled, the DNS signals of a delegated domain are collected from its name servers in the same resolution.
+// The name servers of the referrals are reached by their glue, or resolved the same way if they have none,
+// and the walk is kept in RawResponse as a trace.
+// The resolution is aborted as soon as the context is canceled.
+func NsCheck(ctx context.Context, domain string) (lookupinfo.DomainInfo, error) {
 	log.Debugf("Resolving NS record for domain %s", domain)
 
 	domainInfo := lookupinfo.DomainInfo{
@@ -76,146 +33,118 @@ func NsCheck(domain string) (lookupinfo.DomainInfo, error) {
 		DomainName: domain,
 	}
 
-	cfg := config.GetConfig()
-
-	nsRecords := make([]string, 0)
-
 	parts := strings.Split(strutil.Trim(domain, "."), ".")
 	if len(parts) < 2 {
-		return domainInfo, fmt.Errorf("invalid domain: %s", domain)
+		return domainInfo, lookuperror.Newf(lookuperror.ErrorInvalidDomainName, "", "%s", domain)
 	}
-	tld := parts[len(parts)-1]
-
-	// Create DNS client
-	dnsClient := new(dns.Client)
-	dnsClient.Net = "udp"
-	dnsClient.Timeout = time.Duration(cfg.DnsTimeout) * time.Second
-
-	// Create DNS NS query message
-	msg := new(dns.Msg)
-	msg.SetQuestion(dns.Fqdn(domain), dns.TypeNS)
-	msg.RecursionDesired = false
-
-	// Initialize the nameservers
-	level := 0
-	rawTrace := "┌─ DNS Resolution Trace\n"
-	rawTrace += fmt.Sprintf("├─ Target: %s\n", domain)
-	rawTrace += fmt.Sprintf("├─ Root Servers: \n")
-	for _, rootNs := range rootServers {
-		rawTrace += fmt.Sprintf("│  ├─ %s\n", rootNs)
+	name := dns.CanonicalName(strutil.Trim(domain, "."))
+
+	if mode := getDnsMode(); mode != dnsModeIterative {
+		return nsCheckRecursive(ctx, domainInfo, name, mode)
 	}
 
-	nextNameservers := rootServers
-	if HasTldNsCache(tld) {
-		log.Debugf("Using cached NS records for '%s': %v", tld, GetTldNsCache(tld))
-		nextNameservers = GetTldNsCache(tld)
-
-		rawTrace += "│\n"
-		indent := strings.Repeat("│  ", level)
-		rawTrace += fmt.Sprintf("%s├─ Level %d: Query for %s\n", indent, level+1, domain)
-		rawTrace += fmt.Sprintf("%s├─ Got answer for: %s\n", indent, tld)
-		rawTrace += fmt.Sprintf("%s├─ Found nameservers: \n", indent)
-		for _, ns := range nextNameservers {
-			rawTrace += fmt.Sprintf("%s│  ├─ %s\n", indent, ns)
-		}
-		rawTrace += fmt.Sprintf("%s└─ Via: %s\n", indent, nextNameservers[0])
-		level++
+	resolver := newIterativeResolver()
+	resolver.trace.WriteString("┌─ DNS Resolution Trace\n")
+	resolver.trace.WriteString(fmt.Sprintf("├─ Target: %s\n", domain))
+	resolver.trace.WriteString("├─ Root Servers: \n")
+	for _, rootNs := range getRootServers() {
+		resolver.trace.WriteString(fmt.Sprintf("│  ├─ %s\n", rootNs.Name))
 	}
 
-	for {
-		var responseNS []string
-		foundNS := false
-
-		indent := strings.Repeat("│  ", level)
-		rawTrace += "│\n"
-		rawTrace += fmt.Sprintf("%s├─ Level %d: Query for %s\n", indent, level+1, domain)
-
-		for _, nameserver := range nextNameservers {
-			response, _, err := dnsClient.Exchange(msg, net.JoinHostPort(strutil.Trim(nameserver, "."), "53"))
-			if err != nil {
-				log.Debugf("Failed to query DNS for domain %s using nameserver %s: %s", domain, nameserver, err)
-				continue
-			}
-
-			if response != nil {
-				log.Debugf("DNS query for %s using nameserver %s Rcode is %d", domain, nameserver, response.Rcode)
-				if response.Rcode == dns.RcodeSuccess {
-					records := response.Answer
-					if len(records) == 0 {
-						records = response.Ns
-					}
-
-					isTldNs := false
-
-					if len(records) > 0 {
-						hdrName := ""
-						for _, rr := range records {
-							if ns, ok := rr.(*dns.NS); ok {
-								foundNS = true
-								responseNS = append(responseNS, ns.Ns)
-								hdrName = strutil.Trim(ns.Hdr.Name, ".")
-								if hdrName == domain {
-									nsRecords = append(nsRecords, strutil.Trim(ns.Ns, "."))
-								} else if hdrName == tld {
-									isTldNs = true
-								}
-								log.Debugf("Found NS record for domain %s from nameserver %s is: %v", ns.Hdr.Name, nameserver, ns.Ns)
-							}
-						}
-						if foundNS {
-							rawTrace += fmt.Sprintf("%s├─ Got answer for: %s\n", indent, hdrName)
-							rawTrace += fmt.Sprintf("%s├─ Found nameservers: \n", indent)
-							for _, ns := range responseNS {
-								rawTrace += fmt.Sprintf("%s│  ├─ %s\n", indent, ns)
-							}
-							rawTrace += fmt.Sprintf("%s└─ Via: %s\n", indent, nameserver)
-
-							if isTldNs {
-								if !HasTldNsCache(tld) {
-									log.Infof("Adding NS records for '%s' to cache: %v", tld, responseNS)
-									AddTldNsCache(tld, responseNS)
-								}
-							}
-							break
-						}
-					}
-				}
-			} else {
-				log.Warnf("DNS query for %s using nameserver %s response is nil", domain, nameserver)
-			}
-		}
+	zone, servers := resolver.startServers(name)
+	if zone != "." {
+		log.Debugf("Using cached NS records for '%s': %v", zone, servers)
 
-		if len(nsRecords) > 0 {
-			// Found the nameservers for the target domain
-			log.Infof("Found NS record for %s are: %v", domain, nsRecords)
-			break
+		resolver.trace.WriteString("│\n")
+		resolver.trace.WriteString(fmt.Sprintf("├─ Level 1: Query for %s\n", domain))
+		resolver.trace.WriteString(fmt.Sprintf("├─ Got answer for: %s\n", strings.TrimSuffix(zone, ".")))
+		resolver.trace.WriteString("├─ Found nameservers: \n")
+		for _, ns := range servers {
+			resolver.trace.WriteString(fmt.Sprintf("│  ├─ %s\n", ns.Name))
 		}
+		resolver.trace.WriteString(fmt.Sprintf("└─ Via: %s\n", servers[0].Name))
+		resolver.level++
+	}
 
-		if !foundNS {
-			log.Infof("No nameservers found for %s", domain)
-			rawTrace += fmt.Sprintf("%s└─ No nameservers found for %s\n", indent, domain)
-			break
-		}
+	result, err := resolver.walk(ctx, name, dns.TypeNS, zone, servers, 0)
+	if ctx.Err() != nil {
+		log.Debugf("Resolving NS record for domain %s canceled", domain)
+		domainInfo.RawResponse = resolver.trace.String()
+		return domainInfo, lookuperror.New(lookuperror.ErrorLookupCanceled, strutil.Trim(result.server, "."), ctx.Err())
+	}
+	if err != nil {
+		log.Debugf("Failed to resolve NS record for domain %s: %s", domain, err)
+	}
 
-		// Use the nameservers found in the current level to resolve the next level
-		nextNameservers = responseNS
-		level++
+	// The server of the last answer is the one which answered the lookup
+	domainInfo.Trace.Server = strutil.Trim(result.server, ".")
 
-		if level > 3 {
-			log.Warnf("Failed to resolve NS record for domain %s", domain)
-			break
+	nsRecords := make([]string, 0)
+	if err == nil {
+		nsRecords = getNsRecordsOfName(result.response, name)
+	}
+
+	if len(nsRecords) > 0 && config.GetConfig().DnsCollectSignals {
+		nsNames := slice.Map(nsRecords, func(_ int, nsRecord string) string {
+			return dns.Fqdn(nsRecord)
+		})
+		source := newAuthoritativeSignalSource(resolver, result, nsNames)
+		domainInfo.DnsSignals = collectDnsSignals(ctx, name, source, nsNames, &resolver.trace)
+		if ctx.Err() != nil {
+			log.Debugf("Collecting DNS signals for domain %s canceled", domain)
+			domainInfo.RawResponse = resolver.trace.String()
+			return domainInfo, lookuperror.New(lookuperror.ErrorLookupCanceled, domainInfo.Trace.Server, ctx.Err())
 		}
 	}
 
-	rawTrace += "│\n"
-	rawTrace += "└─ Resolution Complete\n"
+	resolver.trace.WriteString("│\n")
+	resolver.trace.WriteString("└─ Resolution Complete\n")
 
 	domainInfo.NameServer = nsRecords
-	domainInfo.RawResponse = rawTrace
+	domainInfo.RawResponse = resolver.trace.String()
 
 	if len(nsRecords) == 0 {
-		return domainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorNsNotFound, domain)
+		nsErr := classifyNsFailure(domain, result, err)
+		log.Infof("No nameservers found for %s: %s", domain, nsErr)
+		return domainInfo, nsErr
 	}
 
+	log.Infof("Found NS record for %s are: %v", domain, nsRecords)
 	return domainInfo, nil
 }
+
+// classifyNsFailure returns the error of the NS resolution of the domain which found no NS record.
+// Only an authoritative NXDOMAIN or NODATA answer means the domain is not delegated, the timeouts, the failing or refusing
+// servers and the answers which are not authoritative are errors, so the domain is checked again instead of being reported as free.
+func classifyNsFailure(domain string, result walkResult, err error) error {
+	server := strutil.Trim(result.server, ".")
+
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return lookuperror.New(lookuperror.ErrorDnsTimeout, server, err)
+		}
+		return lookuperror.New(lookuperror.ErrorDnsServerFailed, server, err)
+	}
+
+	response := result.response
+	switch {
+	case !response.Authoritative:
+		return lookuperror.Newf(lookuperror.ErrorDnsServerFailed, server, "%s answer without delegation of %s is not authoritative", dns.RcodeToString[response.Rcode], domain)
+	case response.Rcode == dns.RcodeNameError, response.Rcode == dns.RcodeSuccess:
+		return lookuperror.Newf(lookuperror.ErrorNsNotFound, server, "%s", domain)
+	default:
+		return lookuperror.Newf(lookuperror.ErrorDnsServerFailed, server, "%s answer for %s", dns.RcodeToString[response.Rcode], domain)
+	}
+}
+
+// getNsRecordsOfName returns the names of the NS records of the name in the answer or the authority section of the response.
+func getNsRecordsOfName(response *dns.Msg, name string) []string {
+	nsRecords := make([]string, 0)
+	for _, rr := range append(slices.Clone(response.Answer), response.Ns...) {
+		if ns, ok := rr.(*dns.NS); ok && dns.CanonicalName(ns.Hdr.Name) == name {
+			nsRecords = append(nsRecords, strings.ToLower(strutil.Trim(ns.Ns, ".")))
+		}
+	}
+	return slice.Unique(nsRecords)
+}
@@ -0,0 +1,91 @@
+package dnslib
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"typonamer/lookup/lookuperror"
+
+	"github.com/miekg/dns"
+)
+
+func TestClassifyNsFailure(t *testing.T) {
+	// response returns a response to the NS query of example.test with the response code and the authoritative flag
+	response := func(rcode int, authoritative bool) *dns.Msg {
+		req := new(dns.Msg)
+		req.SetQuestion("example.test.", dns.TypeNS)
+		return testRcode(req, rcode, authoritative)
+	}
+	timeout := &net.DNSError{Err: "i/o timeout", IsTimeout: true}
+
+	tests := []struct {
+		name   string
+		result walkResult
+		err    error
+		want   error
+	}{
+		{
+			name:   "authoritative nxdomain",
+			result: walkResult{response: response(dns.RcodeNameError, true), server: "ns1.nic.test."},
+			want:   lookuperror.ErrorNsNotFound,
+		},
+		{
+			name:   "authoritative nodata",
+			result: walkResult{response: response(dns.RcodeSuccess, true), server: "ns1.nic.test."},
+			want:   lookuperror.ErrorNsNotFound,
+		},
+		{
+			name:   "non-authoritative nxdomain",
+			result: walkResult{response: response(dns.RcodeNameError, false), server: "ns1.nic.test."},
+			want:   lookuperror.ErrorDnsServerFailed,
+		},
+		{
+			name:   "non-authoritative nodata",
+			result: walkResult{response: response(dns.RcodeSuccess, false), server: "ns1.nic.test."},
+			want:   lookuperror.ErrorDnsServerFailed,
+		},
+		{
+			name:   "authoritative not implemented",
+			result: walkResult{response: response(dns.RcodeNotImplemented, true), server: "ns1.nic.test."},
+			want:   lookuperror.ErrorDnsServerFailed,
+		},
+		{
+			name:   "timeout",
+			result: walkResult{server: "ns1.nic.test."},
+			err:    fmt.Errorf("nameserver ns1.nic.test. (192.0.2.2): %w", timeout),
+			want:   lookuperror.ErrorDnsTimeout,
+		},
+		{
+			name:   "servfail of every server",
+			result: walkResult{server: "ns1.nic.test."},
+			err:    &dnsRcodeError{rcode: dns.RcodeServerFailure, server: "ns1.nic.test.", addr: "192.0.2.2"},
+			want:   lookuperror.ErrorDnsServerFailed,
+		},
+		{
+			name:   "connection refused",
+			result: walkResult{server: "ns1.nic.test."},
+			err:    fmt.Errorf("nameserver ns1.nic.test. (192.0.2.2): %w", errors.New("connection refused")),
+			want:   lookuperror.ErrorDnsServerFailed,
+		},
+		{
+			name:   "too many queries",
+			result: walkResult{server: "ns1.nic.test."},
+			err:    errDnsTooManyQueries,
+			want:   lookuperror.ErrorDnsServerFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyNsFailure("example.test", tt.result, tt.err)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("classifyNsFailure() = %v, want %s", err, tt.want)
+			}
+			if lookupErr, ok := lookuperror.AsLookupError(err); !ok || lookupErr.Server != "ns1.nic.test" {
+				t.Errorf("classifyNsFailure() = %v, want a LookupError of server ns1.nic.test", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package dnslib
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"typonamer/config"
+
+	"github.com/miekg/dns"
+)
+
+// testDnsQuery is a query received by a test name server.
+type testDnsQuery struct {
+	name             string
+	qtype            uint16
+	network          string // network is "udp", "tcp" or "https" for DoH.
+	udpSize          uint16 // udpSize is the EDNS0 UDP payload size of the query, zero without EDNS0.
+	recursionDesired bool
+	checkingDisabled bool
+}
+
+// testDnsServer is a name server on 127.0.0.1, answering the queries over UDP and TCP on the same port.
+// A nil answer leaves the query unanswered, as a server which times out.
+type testDnsServer struct {
+	hostPort string
+	answer   func(req *dns.Msg, network string) *dns.Msg
+
+	mux     sync.Mutex
+	queries []testDnsQuery
+}
+
+// startTestDnsServer starts a name server on a random port of 127.0.0.1, and shuts it down after the test.
+func startTestDnsServer(t *testing.T, answer func(req *dns.Msg, network string) *dns.Msg) *testDnsServer {
+	t.Helper()
+
+	server := &testDnsServer{answer: answer}
+
+	packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.hostPort = packetConn.LocalAddr().String()
+	listener, err := net.Listen("tcp", server.hostPort)
+	if err != nil {
+		packetConn.Close()
+		t.Fatal(err)
+	}
+
+	for _, dnsServer := range []*dns.Server{{PacketConn: packetConn, Handler: server}, {Listener: listener, Handler: server}} {
+		started := make(chan struct{})
+		dnsServer.NotifyStartedFunc = func() { close(started) }
+		go dnsServer.ActivateAndServe()
+		<-started
+		t.Cleanup(func() { dnsServer.Shutdown() })
+	}
+
+	return server
+}
+
+func (s *testDnsServer) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	if response := s.respond(req, w.LocalAddr().Network()); response != nil {
+		w.WriteMsg(response)
+	}
+}
+
+// respond records the query received over the network and returns the answer to it.
+func (s *testDnsServer) respond(req *dns.Msg, network string) *dns.Msg {
+	query := testDnsQuery{
+		name:             req.Question[0].Name,
+		qtype:            req.Question[0].Qtype,
+		network:          network,
+		recursionDesired: req.RecursionDesired,
+		checkingDisabled: req.CheckingDisabled,
+	}
+	if opt := req.IsEdns0(); opt != nil {
+		query.udpSize = opt.UDPSize()
+	}
+	s.mux.Lock()
+	s.queries = append(s.queries, query)
+	s.mux.Unlock()
+
+	response := s.answer(req, network)
+	// A response too large for UDP is truncated, as a real name server does
+	if response != nil && network == "udp" {
+		response.Truncate(max(int(query.udpSize), dns.MinMsgSize))
+	}
+	return response
+}
+
+// Queries returns the queries received so far.
+func (s *testDnsServer) Queries() []testDnsQuery {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return append([]testDnsQuery(nil), s.queries...)
+}
+
+// useTestDnsServers sends the queries to the addresses to the test name servers, and makes the name servers the root servers.
+// The TLD cache is emptied before and after the test, and the root servers in use are restored after it.
+func useTestDnsServers(t *testing.T, servers map[string]*testDnsServer, roots []NameServer) {
+	t.Helper()
+
+	previousHostPort := dnsHostPort
+	dnsHostPort = func(addr string) string {
+		if server, ok := servers[addr]; ok {
+			return server.hostPort
+		}
+		// Nothing listens on port 1, the query fails at once
+		return net.JoinHostPort("127.0.0.1", "1")
+	}
+
+	previousRoots := getRootServers()
+	rootServersMux.RLock()
+	previousPrimedAt := rootPrimedAt
+	rootServersMux.RUnlock()
+	setRootServers(roots, "", time.Time{})
+
+	resetTldNsCache()
+	t.Cleanup(func() {
+		dnsHostPort = previousHostPort
+		setRootServers(previousRoots, "", previousPrimedAt)
+		resetTldNsCache()
+	})
+}
+
+// resetTldNsCache empties the TLD cache in memory, leaving Redis alone.
+func resetTldNsCache() {
+	TldNsCache.mux.Lock()
+	TldNsCache.TldNsMap = make(map[string]tldNsCacheEntry)
+	TldNsCache.mux.Unlock()
+}
+
+// setTestDnsConfig puts the configuration changed by update in use for the test, with a DNS timeout of 1 second unless update changes it.
+func setTestDnsConfig(t *testing.T, update func(cfg *config.Config)) {
+	t.Helper()
+
+	cfg := config.GetConfig()
+	cfg.DnsTimeout = 1
+	update(&cfg)
+	config.SetForTest(t, cfg)
+}
+
+// testRR parses the record in the zone file format, it panics on an invalid record as the records are fixed by the tests.
+func testRR(record string) dns.RR {
+	rr, err := dns.NewRR(record)
+	if err != nil {
+		panic(err)
+	}
+	return rr
+}
+
+// testRRs parses the records in the zone file format.
+func testRRs(records ...string) []dns.RR {
+	rrs := make([]dns.RR, 0, len(records))
+	for _, record := range records {
+		rrs = append(rrs, testRR(record))
+	}
+	return rrs
+}
+
+// testReferral returns a referral to the zone of the NS records, with the glue records.
+func testReferral(req *dns.Msg, nsRecords []string, glue ...string) *dns.Msg {
+	response := new(dns.Msg)
+	response.SetReply(req)
+	response.Ns = testRRs(nsRecords...)
+	response.Extra = testRRs(glue...)
+	return response
+}
+
+// testAnswer returns an authoritative answer with the records, NODATA if there is none.
+func testAnswer(req *dns.Msg, records ...string) *dns.Msg {
+	response := new(dns.Msg)
+	response.SetReply(req)
+	response.Authoritative = true
+	response.Answer = testRRs(records...)
+	return response
+}
+
+// testRcode returns an answer with the response code and no record.
+func testRcode(req *dns.Msg, rcode int, authoritative bool) *dns.Msg {
+	response := new(dns.Msg)
+	response.SetRcode(req, rcode)
+	response.Authoritative = authoritative
+	return response
+}
+
+// isTestQueryIn reports whether the query is for a name in the zone.
+func isTestQueryIn(req *dns.Msg, zone string) bool {
+	return dns.IsSubDomain(zone, dns.CanonicalName(req.Question[0].Name))
+}
@@ -0,0 +1,184 @@
+package dnslib
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"typonamer/constant"
+	"typonamer/lookup/lookupinfo"
+
+	"github.com/duke-git/lancet/v2/slice"
+	"github.com/miekg/dns"
+)
+
+// parkingNameServerDomains are the domains of the name servers of the well known parking services.
+var parkingNameServerDomains = []string{
+	"sedoparking.com.",
+	"parkingcrew.net.",
+	"bodis.com.",
+	"above.com.",
+	"dan.com.",
+}
+
+// dnsSignalSource answers the queries of the DNS signals of a domain.
+type dnsSignalSource interface {
+	// query returns the answer of the query for the records of the name, nil if no server answered it.
+	query(ctx context.Context, name string, qtype uint16) *dns.Msg
+}
+
+// collectDnsSignals queries the source for the SOA, address, MX, DNSKEY and DS records of the domain, and writes them to the trace.
+// The domain is lame if the SOA query is not answered, and its other records are not queried then.
+func collectDnsSignals(ctx context.Context, name string, source dnsSignalSource, nsNames []string, trace *strings.Builder) lookupinfo.DnsSignals {
+	signals := lookupinfo.DnsSignals{}
+
+	trace.WriteString("│\n")
+	trace.WriteString("├─ DNS Signals\n")
+
+	response := source.query(ctx, name, dns.TypeSOA)
+	if response == nil {
+		signals.Lame = true
+		signals.State = constant.DnsStateLame
+		trace.WriteString("│  └─ No nameserver answered authoritatively, the delegation is lame\n")
+		return signals
+	}
+	for _, rr := range getAnswersOfName(response, name, dns.TypeSOA) {
+		soa := rr.(*dns.SOA)
+		signals.SoaSerial = soa.Serial
+		signals.SoaContact = soaMailboxToEmail(soa.Mbox)
+	}
+	trace.WriteString(fmt.Sprintf("│  ├─ SOA: serial %d, contact %s\n", signals.SoaSerial, signals.SoaContact))
+
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		for _, rr := range getAnswersOfName(source.query(ctx, name, qtype), name, qtype) {
+			switch record := rr.(type) {
+			case *dns.A:
+				signals.Addresses = append(signals.Addresses, record.A.String())
+			case *dns.AAAA:
+				signals.Addresses = append(signals.Addresses, record.AAAA.String())
+			}
+		}
+	}
+	trace.WriteString(fmt.Sprintf("│  ├─ Addresses: %s\n", strings.Join(signals.Addresses, ", ")))
+
+	mxRecords := slice.Map(getAnswersOfName(source.query(ctx, name, dns.TypeMX), name, dns.TypeMX), func(_ int, rr dns.RR) *dns.MX {
+		return rr.(*dns.MX)
+	})
+	slices.SortStableFunc(mxRecords, func(a, b *dns.MX) int {
+		return int(a.Preference) - int(b.Preference)
+	})
+	for _, mx := range mxRecords {
+		// The null MX of RFC 7505 says the domain accepts no mail
+		if mx.Mx != "." {
+			signals.Mx = append(signals.Mx, strings.ToLower(strings.TrimSuffix(mx.Mx, ".")))
+		}
+	}
+	trace.WriteString(fmt.Sprintf("│  ├─ MX: %s\n", strings.Join(signals.Mx, ", ")))
+
+	signals.HasDnskey = len(getAnswersOfName(source.query(ctx, name, dns.TypeDNSKEY), name, dns.TypeDNSKEY)) > 0
+	signals.HasDs = len(getAnswersOfName(source.query(ctx, name, dns.TypeDS), name, dns.TypeDS)) > 0
+	trace.WriteString(fmt.Sprintf("│  ├─ DNSSEC: DS %t, DNSKEY %t\n", signals.HasDs, signals.HasDnskey))
+
+	signals.State = getDnsState(signals, nsNames)
+	trace.WriteString(fmt.Sprintf("│  └─ State: %s\n", signals.State))
+
+	return signals
+}
+
+// authoritativeSignalSource queries the name servers of the domain for its records, and the name servers of its parent zone
+// for its DS records, as the walk which found the NS records of the domain left them.
+type authoritativeSignalSource struct {
+	resolver      *iterativeResolver
+	servers       []NameServer // servers is the name servers of the domain, the one which answered last first.
+	parentServers []NameServer // parentServers is the name servers of the parent zone.
+}
+
+// newAuthoritativeSignalSource returns the source of the DNS signals of the domain with the result of the walk which found its NS records.
+func newAuthoritativeSignalSource(resolver *iterativeResolver, result walkResult, nsNames []string) *authoritativeSignalSource {
+	return &authoritativeSignalSource{
+		resolver:      resolver,
+		servers:       getReferralServers(result.response, result.zone, nsNames),
+		parentServers: result.servers,
+	}
+}
+
+// query asks the name servers in turn until one of them answers authoritatively, the DS records are in the parent zone.
+// The name server which answered is put first, so the next queries go to it first.
+func (s *authoritativeSignalSource) query(ctx context.Context, name string, qtype uint16) *dns.Msg {
+	if qtype == dns.TypeDS {
+		response, _ := s.resolver.queryAuthoritative(ctx, name, qtype, s.parentServers)
+		return response
+	}
+
+	response, servers := s.resolver.queryAuthoritative(ctx, name, qtype, s.servers)
+	s.servers = servers
+	return response
+}
+
+// queryAuthoritative sends the query to the name servers in turn, until one of them answers authoritatively.
+// The name servers are returned with the one which answered first.
+// The response is nil if none of the name servers answered authoritatively.
+func (r *iterativeResolver) queryAuthoritative(ctx context.Context, name string, qtype uint16, servers []NameServer) (*dns.Msg, []NameServer) {
+	msg := newDnsQuery(name, qtype)
+	for i := range servers {
+		// The name servers without glue are resolved into the slice, so they are resolved only once for all the queries
+		response, _, err := r.exchange(ctx, msg, servers[i:i+1], 1)
+		if err != nil || !response.Authoritative {
+			continue
+		}
+		if response.Rcode != dns.RcodeSuccess && response.Rcode != dns.RcodeNameError {
+			continue
+		}
+
+		if i > 0 {
+			servers = append([]NameServer{servers[i]}, append(slices.Clone(servers[:i]), servers[i+1:]...)...)
+		}
+		return response, servers
+	}
+	return nil, servers
+}
+
+// getAnswersOfName returns the records of the type for the name in the answer of the response, none if the response is nil.
+func getAnswersOfName(response *dns.Msg, name string, qtype uint16) []dns.RR {
+	if response == nil {
+		return nil
+	}
+	return slice.Filter(response.Answer, func(_ int, rr dns.RR) bool {
+		return rr.Header().Rrtype == qtype && dns.CanonicalName(rr.Header().Name) == name
+	})
+}
+
+// getDnsState sums up the signals of the domain, the name servers of a parking service tell more than the records they serve.
+func getDnsState(signals lookupinfo.DnsSignals, nsNames []string) string {
+	parked := slice.Some(nsNames, func(_ int, nsName string) bool {
+		return slice.Some(parkingNameServerDomains, func(_ int, parkingDomain string) bool {
+			return dns.IsSubDomain(parkingDomain, dns.CanonicalName(nsName))
+		})
+	})
+
+	switch {
+	case signals.Lame:
+		return constant.DnsStateLame
+	case parked:
+		return constant.DnsStateParked
+	case len(signals.Addresses) > 0 && len(signals.Mx) > 0:
+		return constant.DnsStateLiveWithMail
+	case len(signals.Addresses) > 0:
+		return constant.DnsStateLive
+	case len(signals.Mx) > 0:
+		return constant.DnsStateMailOnly
+	default:
+		return constant.DnsStateNoRecords
+	}
+}
+
+// soaMailboxToEmail converts the mailbox name of the SOA record to an email, such as "hostmaster.example.com." to "hostmaster@example.com".
+// A dot escaped in the first label belongs to the local part of the email.
+func soaMailboxToEmail(mbox string) string {
+	labels := dns.SplitDomainName(mbox)
+	if len(labels) < 2 {
+		return strings.TrimSuffix(mbox, ".")
+	}
+	return strings.ReplaceAll(labels[0], `\.`, ".") + "@" + strings.ToLower(strings.Join(labels[1:], "."))
+}
@@ -0,0 +1,168 @@
+package dnslib
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"typonamer/constant"
+	"typonamer/lookup/lookupinfo"
+
+	"github.com/miekg/dns"
+)
+
+// testSignalSource answers the queries of the DNS signals with the records of their type, nil for the types it has no answer of.
+type testSignalSource map[uint16][]string
+
+func (s testSignalSource) query(ctx context.Context, name string, qtype uint16) *dns.Msg {
+	records, ok := s[qtype]
+	if !ok {
+		return nil
+	}
+	req := new(dns.Msg)
+	req.SetQuestion(name, qtype)
+	return testAnswer(req, records...)
+}
+
+func TestCollectDnsSignals(t *testing.T) {
+	soa := []string{`example.test. 3600 IN SOA ns1.example.test. john\.doe.example.test. 2024010101 7200 3600 1209600 3600`}
+
+	tests := []struct {
+		name   string
+		source testSignalSource
+		want   lookupinfo.DnsSignals
+	}{
+		{
+			name:   "lame",
+			source: testSignalSource{},
+			want:   lookupinfo.DnsSignals{State: constant.DnsStateLame, Lame: true},
+		},
+		{
+			name: "live with mail and dnssec",
+			source: testSignalSource{
+				dns.TypeSOA:    soa,
+				dns.TypeA:      {"example.test. 300 IN A 192.0.2.10"},
+				dns.TypeAAAA:   {"example.test. 300 IN AAAA 2001:db8::10"},
+				dns.TypeMX:     {"example.test. 300 IN MX 20 MX2.example.test.", "example.test. 300 IN MX 10 mx1.example.test."},
+				dns.TypeDNSKEY: {"example.test. 300 IN DNSKEY 257 3 13 mdsswUyr3DPW132mOi8V9xESWE8jTo0dxCjjnopKl+GqJxpVXckHAeF+KkxLbxILfDLUT0rAK9iUzy1L53eKGQ=="},
+				dns.TypeDS:     {"example.test. 300 IN DS 2371 13 2 1F987CC6583E92DF0890718C42F4D9FFB1E8D4C5A3B8E3E0A9B7D6C5B4A39281"},
+			},
+			want: lookupinfo.DnsSignals{
+				State:      constant.DnsStateLiveWithMail,
+				SoaSerial:  2024010101,
+				SoaContact: "john.doe@example.test",
+				Addresses:  []string{"192.0.2.10", "2001:db8::10"},
+				Mx:         []string{"mx1.example.test", "mx2.example.test"},
+				HasDs:      true,
+				HasDnskey:  true,
+			},
+		},
+		{
+			name: "null mx",
+			source: testSignalSource{
+				dns.TypeSOA: soa,
+				dns.TypeA:   {"example.test. 300 IN A 192.0.2.10"},
+				dns.TypeMX:  {"example.test. 300 IN MX 0 ."},
+			},
+			want: lookupinfo.DnsSignals{
+				State:      constant.DnsStateLive,
+				SoaSerial:  2024010101,
+				SoaContact: "john.doe@example.test",
+				Addresses:  []string{"192.0.2.10"},
+			},
+		},
+		{
+			name: "records of other names",
+			source: testSignalSource{
+				dns.TypeSOA: soa,
+				dns.TypeA:   {"www.example.test. 300 IN A 192.0.2.10"},
+				dns.TypeMX:  {"www.example.test. 300 IN MX 10 mx1.example.test."},
+				dns.TypeDS:  {"www.example.test. 300 IN DS 2371 13 2 1F987CC6583E92DF0890718C42F4D9FFB1E8D4C5A3B8E3E0A9B7D6C5B4A39281"},
+			},
+			want: lookupinfo.DnsSignals{
+				State:      constant.DnsStateNoRecords,
+				SoaSerial:  2024010101,
+				SoaContact: "john.doe@example.test",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var trace strings.Builder
+			got := collectDnsSignals(context.Background(), "example.test.", tt.source, []string{"ns1.example.test."}, &trace)
+
+			if got.State != tt.want.State || got.Lame != tt.want.Lame {
+				t.Errorf("State, Lame = %s, %t, want %s, %t", got.State, got.Lame, tt.want.State, tt.want.Lame)
+			}
+			if got.SoaSerial != tt.want.SoaSerial || got.SoaContact != tt.want.SoaContact {
+				t.Errorf("SOA = %d, %q, want %d, %q", got.SoaSerial, got.SoaContact, tt.want.SoaSerial, tt.want.SoaContact)
+			}
+			if !slices.Equal(got.Addresses, tt.want.Addresses) {
+				t.Errorf("Addresses = %v, want %v", got.Addresses, tt.want.Addresses)
+			}
+			if !slices.Equal(got.Mx, tt.want.Mx) {
+				t.Errorf("Mx = %v, want %v", got.Mx, tt.want.Mx)
+			}
+			if got.HasDs != tt.want.HasDs || got.HasDnskey != tt.want.HasDnskey {
+				t.Errorf("HasDs, HasDnskey = %t, %t, want %t, %t", got.HasDs, got.HasDnskey, tt.want.HasDs, tt.want.HasDnskey)
+			}
+		})
+	}
+}
+
+func TestGetDnsState(t *testing.T) {
+	addresses := []string{"192.0.2.10"}
+	mx := []string{"mx1.example.test"}
+	nsNames := []string{"ns1.example.test."}
+
+	tests := []struct {
+		name    string
+		signals lookupinfo.DnsSignals
+		nsNames []string
+		want    string
+	}{
+		{"lame", lookupinfo.DnsSignals{Lame: true}, nsNames, constant.DnsStateLame},
+		{"lame parked", lookupinfo.DnsSignals{Lame: true}, []string{"ns1.sedoparking.com."}, constant.DnsStateLame},
+		{"parked", lookupinfo.DnsSignals{Addresses: addresses, Mx: mx}, []string{"ns1.sedoparking.com."}, constant.DnsStateParked},
+		{"parked without trailing dot", lookupinfo.DnsSignals{}, []string{"NS1.BODIS.COM"}, constant.DnsStateParked},
+		{"parked by one name server", lookupinfo.DnsSignals{}, []string{"ns1.example.test.", "ns2.parkingcrew.net."}, constant.DnsStateParked},
+		{"look-alike of a parking service", lookupinfo.DnsSignals{Addresses: addresses}, []string{"ns1.notsedoparking.com."}, constant.DnsStateLive},
+		{"live with mail", lookupinfo.DnsSignals{Addresses: addresses, Mx: mx}, nsNames, constant.DnsStateLiveWithMail},
+		{"live", lookupinfo.DnsSignals{Addresses: addresses}, nsNames, constant.DnsStateLive},
+		{"mail only", lookupinfo.DnsSignals{Mx: mx}, nsNames, constant.DnsStateMailOnly},
+		{"no records", lookupinfo.DnsSignals{HasDs: true, HasDnskey: true}, nsNames, constant.DnsStateNoRecords},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getDnsState(tt.signals, tt.nsNames); got != tt.want {
+				t.Errorf("getDnsState() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSoaMailboxToEmail(t *testing.T) {
+	tests := []struct {
+		mbox string
+		want string
+	}{
+		{"hostmaster.example.com.", "hostmaster@example.com"},
+		{"hostmaster.example.com", "hostmaster@example.com"},
+		{`john\.doe.example.com.`, "john.doe@example.com"},
+		{"Admin.EXAMPLE.COM.", "Admin@example.com"},
+		{"dns.nic.co.uk.", "dns@nic.co.uk"},
+		{"root.", "root"},
+		{".", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mbox, func(t *testing.T) {
+			if got := soaMailboxToEmail(tt.mbox); got != tt.want {
+				t.Errorf("soaMailboxToEmail(%q) = %q, want %q", tt.mbox, got, tt.want)
+			}
+		})
+	}
+}
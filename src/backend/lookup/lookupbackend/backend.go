@@ -0,0 +1,89 @@
+package lookupbackend
+
+import (
+	"context"
+	"sync"
+
+	"typonamer/lookup/lookupinfo"
+
+	"github.com/duke-git/lancet/v2/slice"
+)
+
+// Backend is a source of domain registration information, such as RDAP, port 43 WHOIS or DNS.
+// Backends register themselves into the registry, so new backends can be added without changing the lookuper.
+type Backend interface {
+	// Name returns the unique name of the backend.
+	Name() string
+
+	// Supports reports whether the backend is able to look up domains under the given TLD.
+	Supports(tld string) bool
+
+	// Lookup queries the registration information of the given main domain.
+	Lookup(ctx context.Context, domain string) (lookupinfo.DomainInfo, error)
+}
+
+// registry holds the registered backends in registration order.
+type registry struct {
+	backends map[string]Backend
+	names    []string
+	mux      sync.RWMutex
+}
+
+var backendRegistry = registry{
+	backends: make(map[string]Backend),
+}
+
+// Register adds a backend to the registry.
+// If a backend with the same name is already registered, it will be replaced.
+func Register(backend Backend) {
+	backendRegistry.mux.Lock()
+	defer backendRegistry.mux.Unlock()
+
+	name := backend.Name()
+	if _, ok := backendRegistry.backends[name]; !ok {
+		backendRegistry.names = append(backendRegistry.names, name)
+	}
+	backendRegistry.backends[name] = backend
+}
+
+// Unregister removes the backend with the given name from the registry.
+func Unregister(name string) {
+	backendRegistry.mux.Lock()
+	defer backendRegistry.mux.Unlock()
+
+	if _, ok := backendRegistry.backends[name]; ok {
+		delete(backendRegistry.backends, name)
+		backendRegistry.names = slice.Without(backendRegistry.names, name)
+	}
+}
+
+// Get returns the backend registered with the given name.
+func Get(name string) (Backend, bool) {
+	backendRegistry.mux.RLock()
+	defer backendRegistry.mux.RUnlock()
+
+	backend, ok := backendRegistry.backends[name]
+	return backend, ok
+}
+
+// Names returns the names of all registered backends in registration order.
+func Names() []string {
+	backendRegistry.mux.RLock()
+	defer backendRegistry.mux.RUnlock()
+
+	names := make([]string, len(backendRegistry.names))
+	copy(names, backendRegistry.names)
+	return names
+}
+
+// FindSupported returns the first backend of the given names that supports the TLD.
+// The names are checked in the given order, names that are not registered are skipped.
+func FindSupported(tld string, names ...string) (Backend, bool) {
+	for _, name := range names {
+		backend, ok := Get(name)
+		if ok && backend.Supports(tld) {
+			return backend, true
+		}
+	}
+	return nil, false
+}
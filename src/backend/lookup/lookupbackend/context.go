@@ -0,0 +1,17 @@
+package lookupbackend
+
+import "context"
+
+// proxyContextKey is the context key for the proxy flag of a lookup.
+type proxyContextKey struct{}
+
+// WithProxy returns a copy of ctx that tells the backends whether the lookup should go through the proxy.
+func WithProxy(ctx context.Context, useProxy bool) context.Context {
+	return context.WithValue(ctx, proxyContextKey{}, useProxy)
+}
+
+// UseProxy reports whether the lookup carried by ctx should go through the proxy.
+func UseProxy(ctx context.Context) bool {
+	useProxy, _ := ctx.Value(proxyContextKey{}).(bool)
+	return useProxy
+}
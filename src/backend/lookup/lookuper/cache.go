@@ -0,0 +1,122 @@
+package lookuper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"typonamer/config"
+	"typonamer/constant"
+	"typonamer/database"
+	"typonamer/log"
+	"typonamer/lookup/lookuperror"
+	"typonamer/lookup/lookupinfo"
+	"typonamer/utils"
+
+	"github.com/bytedance/sonic"
+	"github.com/dromara/carbon/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// cachedLookupResult is the lookup result saved in the lookup cache.
+type cachedLookupResult struct {
+	DomainInfo    lookupinfo.DomainInfo `json:"domainInfo"`
+	Error         string                `json:"error"`
+	ErrorSentinel string                `json:"errorSentinel"`
+	CachedAt      string                `json:"cachedAt"`
+}
+
+// CachedLookup looks up the domain like Lookup, but returns the cached result if the same domain
+// was looked up with the same query type recently.
+// If bypassCache is true, the cache is not read, but the fresh result is still saved to the cache.
+// The result is cached with the TTL configured for its register status, a TTL of 0 disables caching.
+func CachedLookup(ctx context.Context, domain string, queryType string, bypassCache bool) (lookupinfo.DomainInfo, error) {
+	mainDomain, err := utils.TrimAndGetMainDomain(domain)
+	if err != nil {
+		return Lookup(ctx, domain, queryType)
+	}
+
+	rdb, err := database.GetSharedRedis()
+	if err != nil {
+		log.Warnf("Lookup cache is not available: %s", err)
+		return Lookup(ctx, domain, queryType)
+	}
+
+	cacheKey := lookupCacheKey(mainDomain, queryType)
+
+	if !bypassCache {
+		cachedData, err := rdb.Get(ctx, cacheKey).Bytes()
+		if err == nil {
+			cachedResult := cachedLookupResult{}
+			err = sonic.Unmarshal(cachedData, &cachedResult)
+			if err == nil {
+				log.Debugf("Lookup cache hit for domain %s with query type %s", mainDomain, queryType)
+
+				domainInfo := cachedResult.DomainInfo
+				domainInfo.FromCache = true
+				domainInfo.CachedAt = cachedResult.CachedAt
+
+				if cachedResult.Error != "" {
+					return domainInfo, lookuperror.Restore(cachedResult.ErrorSentinel, cachedResult.Error)
+				}
+				return domainInfo, nil
+			}
+			log.Warnf("Failed to unmarshal lookup cache of domain %s: %s", mainDomain, err)
+		} else if err != redis.Nil {
+			log.Warnf("Failed to get lookup cache of domain %s from redis: %s", mainDomain, err)
+		}
+	}
+
+	domainInfo, lookupErr := Lookup(ctx, mainDomain, queryType)
+
+	// Never cache the result of a canceled lookup
+	if errors.Is(lookupErr, lookuperror.ErrorLookupCanceled) {
+		return domainInfo, lookupErr
+	}
+
+	ttl := lookupCacheTtl(config.GetConfig(), domainInfo, lookupErr)
+	if ttl > 0 {
+		cachedResult := cachedLookupResult{
+			DomainInfo: domainInfo,
+			CachedAt:   carbon.Now().ToDateTimeString(),
+		}
+		if lookupErr != nil {
+			cachedResult.Error = lookupErr.Error()
+			if sentinel := lookuperror.Sentinel(lookupErr); sentinel != nil {
+				cachedResult.ErrorSentinel = sentinel.Error()
+			}
+		}
+
+		cachedData, err := sonic.Marshal(cachedResult)
+		if err == nil {
+			err = rdb.Set(ctx, cacheKey, cachedData, ttl).Err()
+		}
+		if err != nil {
+			log.Warnf("Failed to save lookup cache of domain %s: %s", mainDomain, err)
+		}
+	}
+
+	return domainInfo, lookupErr
+}
+
+// lookupCacheKey returns the redis key of the lookup cache for the domain and query type.
+func lookupCacheKey(domain string, queryType string) string {
+	return fmt.Sprintf("%s:%s:%s", constant.LookupCacheRedisKeyPrefix, queryType, domain)
+}
+
+// lookupCacheTtl returns the cache TTL configured for the register status of the lookup result.
+func lookupCacheTtl(cfg config.Config, domainInfo lookupinfo.DomainInfo, lookupErr error) time.Duration {
+	var ttlSeconds int
+	switch utils.GetLookupRegisterStatus(domainInfo, lookupErr) {
+	case constant.DomainRegisterStatusTaken, constant.DomainRegisterStatusReserved, constant.DomainRegisterStatusPremium, constant.DomainRegisterStatusBlocked:
+		// The names held back by the registry change as rarely as the taken ones
+		ttlSeconds = cfg.LookupCacheTakenTtl
+	case constant.DomainRegisterStatusFree:
+		ttlSeconds = cfg.LookupCacheFreeTtl
+	default:
+		ttlSeconds = cfg.LookupCacheErrorTtl
+	}
+
+	return time.Duration(ttlSeconds) * time.Second
+}
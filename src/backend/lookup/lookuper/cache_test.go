@@ -0,0 +1,127 @@
+package lookuper
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"typonamer/config"
+	"typonamer/constant"
+	"typonamer/lookup/lookuperror"
+	"typonamer/lookup/lookupinfo"
+)
+
+// TestLookupCacheTtl checks the lookup results are cached with the TTL of the register status the check tasks report for them.
+func TestLookupCacheTtl(t *testing.T) {
+	cfg := config.Config{
+		LookupCacheTakenTtl: 3600,
+		LookupCacheFreeTtl:  300,
+		LookupCacheErrorTtl: 10,
+	}
+	takenTtl := time.Hour
+	freeTtl := 5 * time.Minute
+	errorTtl := 10 * time.Second
+
+	tests := []struct {
+		name       string
+		domainInfo lookupinfo.DomainInfo
+		err        error
+		want       time.Duration
+	}{
+		{
+			name:       "whois taken",
+			domainInfo: lookupinfo.DomainInfo{LookupType: constant.LookupTypeWhois},
+			want:       takenTtl,
+		},
+		{
+			name:       "rdap reserved",
+			domainInfo: lookupinfo.DomainInfo{LookupType: constant.LookupTypeRDAP, RegistryStatus: constant.DomainRegisterStatusReserved},
+			want:       takenTtl,
+		},
+		{
+			name:       "whois not found",
+			domainInfo: lookupinfo.DomainInfo{LookupType: constant.LookupTypeWhois},
+			err:        lookuperror.Newf(lookuperror.ErrorWhoisNotFound, "whois.example", "example.com"),
+			want:       freeTtl,
+		},
+		{
+			name:       "whois timeout",
+			domainInfo: lookupinfo.DomainInfo{LookupType: constant.LookupTypeWhois},
+			err:        lookuperror.New(lookuperror.ErrorWhoisTimeout, "whois.example", errors.New("i/o timeout")),
+			want:       errorTtl,
+		},
+		{
+			name:       "dns with name servers",
+			domainInfo: lookupinfo.DomainInfo{LookupType: constant.LookupTypeDNS, NameServer: []string{"ns1.example.com"}},
+			want:       takenTtl,
+		},
+		{
+			name:       "dns without name servers",
+			domainInfo: lookupinfo.DomainInfo{LookupType: constant.LookupTypeDNS},
+			want:       freeTtl,
+		},
+		{
+			name:       "dns ns not found",
+			domainInfo: lookupinfo.DomainInfo{LookupType: constant.LookupTypeDNS},
+			err:        lookuperror.Newf(lookuperror.ErrorNsNotFound, "a.nic.example", "example.com"),
+			want:       freeTtl,
+		},
+		{
+			name:       "dns server failed",
+			domainInfo: lookupinfo.DomainInfo{LookupType: constant.LookupTypeDNS},
+			err:        lookuperror.New(lookuperror.ErrorDnsServerFailed, "a.nic.example", errors.New("SERVFAIL")),
+			want:       errorTtl,
+		},
+		{
+			name:       "verify consensus free",
+			domainInfo: lookupinfo.DomainInfo{LookupType: constant.LookupTypeVerify, ConsensusStatus: constant.DomainRegisterStatusFree},
+			want:       freeTtl,
+		},
+		{
+			name:       "verify error",
+			domainInfo: lookupinfo.DomainInfo{LookupType: constant.LookupTypeVerify, ConsensusStatus: constant.DomainRegisterStatusTaken},
+			err:        lookuperror.New(lookuperror.ErrorAllSourcesFailed, "", errors.New("rdap: timeout")),
+			want:       errorTtl,
+		},
+		{
+			name:       "customize api taken",
+			domainInfo: lookupinfo.DomainInfo{LookupType: "myApi", CustomizedResult: constant.DomainRegisterStatusTaken},
+			want:       takenTtl,
+		},
+		{
+			name:       "customize api error",
+			domainInfo: lookupinfo.DomainInfo{LookupType: "myApi"},
+			err:        lookuperror.New(lookuperror.ErrorCustomizeApiServerResponse, "", errors.New("HTTP 500")),
+			want:       errorTtl,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lookupCacheTtl(cfg, tt.domainInfo, tt.err); got != tt.want {
+				t.Errorf("lookupCacheTtl() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestLookupCacheKey checks the cached results of a domain are kept apart by query type.
+func TestLookupCacheKey(t *testing.T) {
+	whoisKey := lookupCacheKey("example.com", constant.LookupTypeWhois)
+	if want := constant.LookupCacheRedisKeyPrefix + ":whois:example.com"; whoisKey != want {
+		t.Errorf("lookupCacheKey() = %s, want %s", whoisKey, want)
+	}
+
+	keys := map[string]string{}
+	for _, queryType := range []string{constant.LookupTypeWhois, constant.LookupTypeRDAP, constant.LookupTypeDNS, constant.LookupTypeVerify} {
+		key := lookupCacheKey("example.com", queryType)
+		if other, ok := keys[key]; ok {
+			t.Errorf("query types %s and %s share the cache key %s", other, queryType, key)
+		}
+		keys[key] = queryType
+	}
+
+	if lookupCacheKey("example.com", constant.LookupTypeDNS) == lookupCacheKey("example.net", constant.LookupTypeDNS) {
+		t.Error("domains share a cache key")
+	}
+}
@@ -1,27 +1,66 @@
 package lookuper
 
 import (
-	"errors"
-	"fmt"
+	"context"
 	"time"
 
 	"typonamer/config"
 	"typonamer/constant"
 	"typonamer/log"
-	"typonamer/lookup/customize"
-	"typonamer/lookup/dnslib"
+	_ "typonamer/lookup/customize"
+	_ "typonamer/lookup/dnslib"
+	"typonamer/lookup/lookupbackend"
 	"typonamer/lookup/lookuperror"
 	"typonamer/lookup/lookupinfo"
-	"typonamer/lookup/rdaplib"
-	"typonamer/lookup/whoislib"
+	_ "typonamer/lookup/rdaplib"
+	_ "typonamer/lookup/whoislib"
 	"typonamer/utils"
 
-	"github.com/duke-git/lancet/v2/maputil"
-	"github.com/duke-git/lancet/v2/retry"
 	"github.com/duke-git/lancet/v2/slice"
 )
 
-func Lookup(domain string, queryType string) (lookupinfo.DomainInfo, error) {
+// registrationBackends are the backends that query the registration data of a domain, in order of preference.
+var registrationBackends = []string{
+	constant.LookupTypeRDAP,
+	constant.LookupTypeWhois,
+}
+
+// builtinBackends are the backends behind the built-in query types, they are not exposed as query types by name.
+var builtinBackends = []string{
+	constant.LookupTypeRDAP,
+	constant.LookupTypeWhois,
+	constant.LookupTypeDNS,
+}
+
+// QueryTypes returns the query types that can be used for lookup.
+// The built-in query types are only returned if the backends they depend on are registered,
+// the other registered backends are returned as query types by their names.
+func QueryTypes() []string {
+	queryTypes := make([]string, 0)
+
+	if slice.ContainBy(registrationBackends, isRegistered) {
+		queryTypes = append(queryTypes, constant.WhoisQuery, constant.WhoisQueryWithProxy)
+	}
+
+	if isRegistered(constant.LookupTypeDNS) {
+		queryTypes = append(queryTypes, constant.DnsQuery)
+		if slice.ContainBy(registrationBackends, isRegistered) {
+			queryTypes = append(queryTypes, constant.MixedQuery, constant.VerifyQuery)
+		}
+	}
+
+	return append(queryTypes, CustomQueryTypes()...)
+}
+
+// CustomQueryTypes returns the names of the registered backends which are not built-in,
+// such as the customized whois APIs.
+func CustomQueryTypes() []string {
+	return slice.Difference(lookupbackend.Names(), builtinBackends)
+}
+
+// Lookup queries the registration information of the domain with the given query type.
+// The lookup is aborted as soon as the context is canceled.
+func Lookup(ctx context.Context, domain string, queryType string) (lookupinfo.DomainInfo, error) {
 	var errDomainInfo = lookupinfo.DomainInfo{
 		DomainName: domain,
 	}
@@ -32,177 +71,273 @@ func Lookup(domain string, queryType string) (lookupinfo.DomainInfo, error) {
 	tld, suffix, err := utils.GetTld(domain)
 	if err != nil || tld == "" || suffix == "" {
 		log.Error("Invalid domain name: ", domain)
-		return errDomainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorInvalidDomainName, err)
+		return errDomainInfo, lookuperror.New(lookuperror.ErrorInvalidDomainName, "", err)
 	}
 
 	// Get the main domain
 	mainDomain, err := utils.TrimAndGetMainDomain(domain)
 	if err != nil {
 		log.Error("Invalid domain name: ", domain)
-		return errDomainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorInvalidDomainName, err)
+		return errDomainInfo, lookuperror.New(lookuperror.ErrorInvalidDomainName, "", err)
 	}
 
 	switch queryType {
 	case constant.WhoisQuery:
-		if !slice.Contain(rdaplib.RdapSupportedTlds, tld) && !maputil.HasKey(whoislib.WhoisSupportedTlds, tld) {
+		if !supportsRegistrationData(tld) {
 			log.Error("Not supported TLD: ", tld)
-			return errDomainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorNotSupportedTld, tld)
+			return errDomainInfo, lookuperror.Newf(lookuperror.ErrorNotSupportedTld, "", "%s", tld)
 		}
 
-		useProxy := false
-		if !useProxy {
-			if slice.Contain(cfg.GlobalProxyTlds, tld) {
-				log.Debugf("%s is the TLD that needs to go through proxy, forcing the whois query to go through proxy", tld)
-				useProxy = true
-			}
-			if slice.Contain(cfg.GlobalProxyTlds, suffix) {
-				log.Debugf("%s is the TLD that needs to go through proxy, forcing the whois query to go through proxy", suffix)
-				useProxy = true
-			}
-		}
-		domainInfo, err := Whois(mainDomain, tld, useProxy)
+		domainInfo, err := Whois(ctx, mainDomain, tld, needsGlobalProxy(tld, suffix))
 		return domainInfo, err
 	case constant.WhoisQueryWithProxy:
-		if !slice.Contain(rdaplib.RdapSupportedTlds, tld) && !maputil.HasKey(whoislib.WhoisSupportedTlds, tld) {
+		if !supportsRegistrationData(tld) {
 			log.Error("Not supported TLD: ", tld)
-			return errDomainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorNotSupportedTld, tld)
+			return errDomainInfo, lookuperror.Newf(lookuperror.ErrorNotSupportedTld, "", "%s", tld)
 		}
 
-		domainInfo, err := Whois(mainDomain, tld, true)
+		domainInfo, err := Whois(ctx, mainDomain, tld, true)
 		return domainInfo, err
+	case constant.VerifyQuery:
+		return Verify(ctx, mainDomain, tld, needsGlobalProxy(tld, suffix))
 	case constant.DnsQuery:
-		domainInfo, err := dnslib.NsCheck(mainDomain)
-		return domainInfo, err
+		return lookupByName(ctx, constant.LookupTypeDNS, mainDomain, false)
 	case constant.MixedQuery:
 		switch {
 		case slice.Contain(cfg.MixedDnsTlds, tld) || slice.Contain(cfg.MixedDnsTlds, suffix):
-			domainInfo, err := dnslib.NsCheck(mainDomain)
-			return domainInfo, err
-		case !slice.Contain(rdaplib.RdapSupportedTlds, tld) && !maputil.HasKey(whoislib.WhoisSupportedTlds, tld):
-			domainInfo, err := dnslib.NsCheck(mainDomain)
-			return domainInfo, err
+			return lookupByName(ctx, constant.LookupTypeDNS, mainDomain, false)
+		case !supportsRegistrationData(tld):
+			return lookupByName(ctx, constant.LookupTypeDNS, mainDomain, false)
 		case slice.Contain(cfg.MixedProxyTlds, tld) || slice.Contain(cfg.MixedProxyTlds, suffix):
-			domainInfo, err := Whois(mainDomain, tld, true)
-			return domainInfo, err
+			return mixedWhois(ctx, mainDomain, tld, true)
 		case slice.Contain(cfg.GlobalProxyTlds, tld) || slice.Contain(cfg.GlobalProxyTlds, suffix):
-			domainInfo, err := Whois(mainDomain, tld, true)
-			return domainInfo, err
+			return mixedWhois(ctx, mainDomain, tld, true)
 		default:
-			domainInfo, err := Whois(mainDomain, tld, false)
-			return domainInfo, err
+			return mixedWhois(ctx, mainDomain, tld, false)
 		}
 	default:
-		domainInfo, err := customize.CustomizeLookup(mainDomain, queryType)
-		return domainInfo, err
+		return lookupByName(ctx, queryType, mainDomain, false)
 	}
 }
 
-func Whois(mainDomain string, tld string, useProxy bool) (lookupinfo.DomainInfo, error) {
+// Whois queries the registration data of the domain with the backends in the fallback order of the TLD.
+// The next backend is only tried if the previous one timed out or failed, the answering backend is
+// recorded in the LookupType of the result and the failed ones in FallbackFrom.
+// The retries are stopped as soon as the context is canceled.
+func Whois(ctx context.Context, mainDomain string, tld string, useProxy bool) (lookupinfo.DomainInfo, error) {
 	var domainInfo = lookupinfo.DomainInfo{
 		DomainName: mainDomain,
 		LookupType: constant.LookupTypeWhois,
 		ViaProxy:   useProxy,
 	}
 
-	cfg := config.GetConfig()
+	backends := fallbackChain(tld)
+	if len(backends) == 0 {
+		log.Error("No RDAP or WHOIS server known for TLD: ", tld)
+		return domainInfo, lookuperror.Newf(lookuperror.ErrorNoWhoisServerForTld, "", "%s", tld)
+	}
 
-	// If the RDAP server for the TLD is known, query the RDAP information for the domain
-	if slice.Contain(rdaplib.RdapSupportedTlds, tld) {
-		domainInfo.LookupType = constant.LookupTypeRDAP
-
-		if cfg.RetryOnTimeout {
-			var rdapErr error
-			getDomainInfo := func() error {
-				domainInfo, rdapErr = rdaplib.RDAPQuery(mainDomain, tld, useProxy)
-				if rdapErr != nil {
-					switch {
-					case errors.Is(rdapErr, lookuperror.ErrorConnectToProxy):
-						return rdapErr
-					case errors.Is(rdapErr, lookuperror.ErrorWhoisTimeout):
-						return rdapErr
-					case errors.Is(rdapErr, lookuperror.ErrorWhoisServerFailed):
-						return rdapErr
-					default:
-						return nil
-					}
-				}
-				return nil
-			}
+	ctx = lookupbackend.WithProxy(ctx, useProxy)
 
-			err := retry.Retry(getDomainInfo, retry.RetryTimes(uint(cfg.RetryMax)), retry.RetryWithLinearBackoff(time.Second*time.Duration(cfg.RetryInterval)))
+	var lookupErr error
+	fallbackFrom := make([]string, 0)
+	attempts := make([]lookupinfo.TraceAttempt, 0)
+	for _, backend := range backends {
+		domainInfo, lookupErr = lookupWithRetry(ctx, backend, mainDomain)
+		domainInfo.FallbackFrom = fallbackFrom
 
-			if err != nil {
-				log.Errorf("Failed to query RDAP for domain %s with error: %s", mainDomain, err)
-			}
+		// Keep the attempts of the failed sources in the trace
+		attempts = append(attempts, domainInfo.Trace.Attempts...)
+		domainInfo.Trace.Attempts = attempts
 
-			if rdapErr != nil {
-				return domainInfo, rdapErr
-			}
+		// A backend which turns out not to serve the TLD, such as WHOIS for a TLD without a server at IANA,
+		// or which can not be used with the configuration, such as RDAP with an invalid CA file, is skipped as well
+		if lookupErr == nil || !(lookuperror.IsRetryable(lookupErr) || isSkippedBackendError(lookupErr)) {
+			break
+		}
 
-			log.Debugf("RDAP query result: \n%+v", domainInfo)
+		log.Infof("Query %s for domain %s failed, falling back to the next source: %s", backend.Name(), mainDomain, lookupErr)
+		fallbackFrom = append(fallbackFrom, backend.Name())
+	}
 
-			return domainInfo, nil
-		} else {
-			domainInfo, err := rdaplib.RDAPQuery(mainDomain, tld, useProxy)
-			if err != nil {
-				return domainInfo, err
-			}
+	return domainInfo, lookupErr
+}
 
-			log.Debugf("RDAP query result: \n%+v", domainInfo)
+// isSkippedBackendError reports whether the failure of a backend is not retried but the next backend of the fallback chain is tried.
+func isSkippedBackendError(err error) bool {
+	kind := lookuperror.KindOf(err)
+	return kind == lookuperror.KindNotSupported || kind == lookuperror.KindConfig
+}
+
+// mixedWhois queries the registration data of the domain for a mixed query, and resolves its NS records instead
+// if no RDAP or WHOIS server turns out to serve the TLD.
+func mixedWhois(ctx context.Context, mainDomain string, tld string, useProxy bool) (lookupinfo.DomainInfo, error) {
+	domainInfo, err := Whois(ctx, mainDomain, tld, useProxy)
+	if lookuperror.KindOf(err) == lookuperror.KindNotSupported {
+		log.Infof("No RDAP or WHOIS server serves TLD %s, resolving the NS records of domain %s instead", tld, mainDomain)
+		return lookupByName(ctx, constant.LookupTypeDNS, mainDomain, false)
+	}
+	return domainInfo, err
+}
+
+// fallbackChain returns the registered backends supporting the TLD, in the fallback order configured for the TLD.
+// If no fallback order is configured, RDAP is preferred over WHOIS.
+func fallbackChain(tld string) []lookupbackend.Backend {
+	cfg := config.GetConfig()
 
-			return domainInfo, nil
+	order := cfg.FallbackOrder
+	for _, tldFallbackOrder := range cfg.TldFallbackOrders {
+		if tldFallbackOrder.Tld == tld {
+			order = tldFallbackOrder.Order
+			break
 		}
+	}
+	if len(order) == 0 {
+		order = registrationBackends
+	}
 
-		// If the WHOIS server for the TLD is known, query the WHOIS information for the domain
-	} else if maputil.HasKey(whoislib.WhoisSupportedTlds, tld) {
-		domainInfo.LookupType = constant.LookupTypeWhois
-
-		if cfg.RetryOnTimeout {
-			var whoisErr error
-			getDomainInfo := func() error {
-				domainInfo, whoisErr = whoislib.WhoisQuery(mainDomain, tld, useProxy)
-				if whoisErr != nil {
-					switch {
-					case errors.Is(whoisErr, lookuperror.ErrorConnectToProxy):
-						return whoisErr
-					case errors.Is(whoisErr, lookuperror.ErrorWhoisTimeout):
-						return whoisErr
-					case errors.Is(whoisErr, lookuperror.ErrorWhoisServerFailed):
-						return whoisErr
-					case errors.Is(whoisErr, lookuperror.ErrorNoContentInWhoisResponse):
-						return whoisErr
-					default:
-						return nil
-					}
-				}
-				return nil
-			}
+	backends := make([]lookupbackend.Backend, 0, len(order))
+	for _, name := range slice.Unique(order) {
+		backend, ok := lookupbackend.Get(name)
+		if !ok {
+			log.Debugf("Unknown lookup source %s in fallback order, skipped", name)
+			continue
+		}
+		if backend.Supports(tld) {
+			backends = append(backends, backend)
+		}
+	}
+	return backends
+}
+
+// lookupWithRetry looks up the domain with the backend, and retries on the timeout and server failure errors after the retry interval if enabled.
+// A rate limited server is retried after only the wait it asked for, if the wait is within the retries.
+// Every attempt is recorded in the trace of the result.
+// The retries are stopped as soon as the context is canceled.
+func lookupWithRetry(ctx context.Context, backend lookupbackend.Backend, mainDomain string) (lookupinfo.DomainInfo, error) {
+	cfg := config.GetConfig()
 
-			err := retry.Retry(getDomainInfo, retry.RetryTimes(uint(cfg.RetryMax)), retry.RetryWithLinearBackoff(time.Second*time.Duration(cfg.RetryInterval)))
+	var domainInfo lookupinfo.DomainInfo
+	var lookupErr error
+	attempts := make([]lookupinfo.TraceAttempt, 0)
 
-			if err != nil {
-				log.Errorf("Failed to query WHOIS for domain %s with error: %s", mainDomain, err)
-			}
+	if cfg.RetryOnTimeout {
+		// The wait asked for by a rate limited server is only honoured within the time the retries would take anyway
+		retryInterval := time.Second * time.Duration(cfg.RetryInterval)
+		maxRetryAfter := retryInterval * time.Duration(cfg.RetryMax)
 
-			if whoisErr != nil {
-				return domainInfo, whoisErr
+		for attempt := 1; ; attempt++ {
+			domainInfo, lookupErr = tracedLookup(ctx, backend, mainDomain, &attempts)
+			if lookupErr == nil || !lookuperror.IsRetryable(lookupErr) || attempt >= cfg.RetryMax {
+				break
 			}
 
-			log.Debugf("WHOIS query domain result: \n%+v", domainInfo)
+			// The rate limited server is cooling down, it is retried once the wait it asked for is over instead of after the interval,
+			// a longer or unknown wait leaves the error retryable so the lookup falls back to the next source.
+			wait := retryInterval
+			if lookuperror.KindOf(lookupErr) == lookuperror.KindRateLimited {
+				retryAfter := lookuperror.RetryAfterOf(lookupErr)
+				if retryAfter <= 0 || retryAfter > maxRetryAfter {
+					break
+				}
+				log.Debugf("Query %s for domain %s rate limited, retrying after %s", backend.Name(), mainDomain, retryAfter)
+				wait = retryAfter
+			}
 
-			return domainInfo, nil
-		} else {
-			domainInfo, err := whoislib.WhoisQuery(mainDomain, tld, useProxy)
-			if err != nil {
-				return domainInfo, err
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+			}
+			if ctx.Err() != nil {
+				break
 			}
+		}
+		domainInfo.Trace.Attempts = attempts
 
-			log.Debugf("WHOIS query domain result: \n%+v", domainInfo)
+		if ctx.Err() != nil {
+			log.Debugf("Query %s for domain %s canceled", backend.Name(), mainDomain)
+			return domainInfo, lookuperror.New(lookuperror.ErrorLookupCanceled, "", ctx.Err())
+		}
 
-			return domainInfo, nil
+		if lookupErr != nil && lookuperror.IsRetryable(lookupErr) {
+			log.Errorf("Failed to query %s for domain %s after %d attempts with error: %s", backend.Name(), mainDomain, len(attempts), lookupErr)
 		}
 	} else {
-		log.Error("No RDAP or WHOIS server known for TLD: ", tld)
-		return domainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorNoWhoisServerForTld, tld)
+		domainInfo, lookupErr = tracedLookup(ctx, backend, mainDomain, &attempts)
+		domainInfo.Trace.Attempts = attempts
+	}
+
+	if lookupErr != nil {
+		return domainInfo, lookuperror.WithAttempts(lookupErr, len(attempts))
+	}
+
+	log.Debugf("%s query domain result: \n%+v", backend.Name(), domainInfo)
+
+	return domainInfo, nil
+}
+
+// tracedLookup looks up the domain with the backend once, and appends the attempt to attempts.
+func tracedLookup(ctx context.Context, backend lookupbackend.Backend, mainDomain string, attempts *[]lookupinfo.TraceAttempt) (lookupinfo.DomainInfo, error) {
+	start := time.Now()
+	domainInfo, err := backend.Lookup(ctx, mainDomain)
+
+	attempt := lookupinfo.TraceAttempt{
+		Source:    backend.Name(),
+		Server:    domainInfo.Trace.Server,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+		if lookupErr, ok := lookuperror.AsLookupError(err); ok && lookupErr.Server != "" {
+			attempt.Server = lookupErr.Server
+		}
+	}
+	*attempts = append(*attempts, attempt)
+
+	return domainInfo, err
+}
+
+// needsGlobalProxy reports whether the TLD or the suffix is configured to always go through the proxy.
+func needsGlobalProxy(tld string, suffix string) bool {
+	cfg := config.GetConfig()
+
+	useProxy := false
+	if slice.Contain(cfg.GlobalProxyTlds, tld) {
+		log.Debugf("%s is the TLD that needs to go through proxy, forcing the whois query to go through proxy", tld)
+		useProxy = true
 	}
+	if slice.Contain(cfg.GlobalProxyTlds, suffix) {
+		log.Debugf("%s is the TLD that needs to go through proxy, forcing the whois query to go through proxy", suffix)
+		useProxy = true
+	}
+	return useProxy
+}
+
+// lookupByName looks up the domain with the backend registered with the given name.
+func lookupByName(ctx context.Context, name string, mainDomain string, useProxy bool) (lookupinfo.DomainInfo, error) {
+	backend, ok := lookupbackend.Get(name)
+	if !ok {
+		log.Debugf("Invalid query type: %s, no lookup backend found", name)
+		return lookupinfo.DomainInfo{DomainName: mainDomain, LookupType: name}, lookuperror.Newf(lookuperror.ErrorInvalidQueryType, "", "%s", name)
+	}
+
+	attempts := make([]lookupinfo.TraceAttempt, 0, 1)
+	domainInfo, err := tracedLookup(lookupbackend.WithProxy(ctx, useProxy), backend, mainDomain, &attempts)
+	domainInfo.Trace.Attempts = attempts
+
+	return domainInfo, err
+}
+
+// supportsRegistrationData reports whether any registration data backend supports the TLD.
+func supportsRegistrationData(tld string) bool {
+	_, ok := lookupbackend.FindSupported(tld, registrationBackends...)
+	return ok
+}
+
+// isRegistered reports whether a backend is registered with the given name.
+func isRegistered(name string) bool {
+	_, ok := lookupbackend.Get(name)
+	return ok
 }
@@ -0,0 +1,123 @@
+package lookuper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"typonamer/config"
+	"typonamer/lookup/lookuperror"
+	"typonamer/lookup/lookupinfo"
+)
+
+// scriptedBackend is a lookup backend returning the errors of its script in turn, and succeeding once they run out.
+type scriptedBackend struct {
+	errs  []error
+	calls int
+}
+
+func (b *scriptedBackend) Name() string {
+	return "scripted"
+}
+
+func (b *scriptedBackend) Supports(tld string) bool {
+	return true
+}
+
+func (b *scriptedBackend) Lookup(ctx context.Context, domain string) (lookupinfo.DomainInfo, error) {
+	b.calls++
+	if b.calls <= len(b.errs) {
+		return lookupinfo.DomainInfo{}, b.errs[b.calls-1]
+	}
+	return lookupinfo.DomainInfo{DomainName: domain}, nil
+}
+
+// setTestRetryConfig enables the retries for the test.
+func setTestRetryConfig(t *testing.T, retryInterval int, retryMax int) {
+	t.Helper()
+
+	cfg := config.GetConfig()
+	cfg.RetryOnTimeout = true
+	cfg.RetryInterval = retryInterval
+	cfg.RetryMax = retryMax
+	config.SetForTest(t, cfg)
+}
+
+// rateLimitedError returns a rate limited error asking to wait for retryAfter.
+func rateLimitedError(retryAfter time.Duration) error {
+	err := lookuperror.New(lookuperror.ErrorRdapRateLimited, "rdap.example", errors.New("HTTP 429"))
+	err.RetryAfter = retryAfter
+	return err
+}
+
+func TestLookupWithRetryWaitsOnlyRetryAfter(t *testing.T) {
+	setTestRetryConfig(t, 2, 3)
+	backend := &scriptedBackend{errs: []error{rateLimitedError(100 * time.Millisecond)}}
+
+	start := time.Now()
+	domainInfo, err := lookupWithRetry(context.Background(), backend, "example.com")
+	elapsed := time.Since(start)
+
+	if err != nil {
+		t.Fatalf("lookupWithRetry() error = %s", err)
+	}
+	if backend.calls != 2 || len(domainInfo.Trace.Attempts) != 2 {
+		t.Errorf("lookupWithRetry() made %d calls with %d attempts traced, want 2", backend.calls, len(domainInfo.Trace.Attempts))
+	}
+	// The retry interval of 2 seconds is not added to the wait the server asked for
+	if elapsed < 100*time.Millisecond || elapsed > time.Second {
+		t.Errorf("lookupWithRetry() took %s, want about the Retry-After of 100ms", elapsed)
+	}
+}
+
+func TestLookupWithRetryGivesUpOnLongRetryAfter(t *testing.T) {
+	setTestRetryConfig(t, 1, 3)
+	backend := &scriptedBackend{errs: []error{rateLimitedError(time.Hour)}}
+
+	_, err := lookupWithRetry(context.Background(), backend, "example.com")
+
+	if lookuperror.KindOf(err) != lookuperror.KindRateLimited || !lookuperror.IsRetryable(err) {
+		t.Errorf("lookupWithRetry() error = %v, want the retryable rate limited error for the fallback", err)
+	}
+	if backend.calls != 1 {
+		t.Errorf("lookupWithRetry() made %d calls, want 1", backend.calls)
+	}
+}
+
+func TestLookupWithRetryStopsAtRetryMax(t *testing.T) {
+	setTestRetryConfig(t, 0, 3)
+	timeout := lookuperror.New(lookuperror.ErrorWhoisTimeout, "whois.example", errors.New("i/o timeout"))
+	backend := &scriptedBackend{errs: []error{timeout, timeout, timeout, timeout}}
+
+	_, err := lookupWithRetry(context.Background(), backend, "example.com")
+
+	if !errors.Is(err, lookuperror.ErrorWhoisTimeout) {
+		t.Errorf("lookupWithRetry() error = %v, want %s", err, lookuperror.ErrorWhoisTimeout)
+	}
+	if backend.calls != 3 {
+		t.Errorf("lookupWithRetry() made %d calls, want 3", backend.calls)
+	}
+	if lookupErr, ok := lookuperror.AsLookupError(err); !ok || lookupErr.Attempts != 3 {
+		t.Errorf("lookupWithRetry() error = %v, want 3 attempts recorded", err)
+	}
+}
+
+func TestLookupWithRetryCanceled(t *testing.T) {
+	setTestRetryConfig(t, 5, 3)
+	timeout := lookuperror.New(lookuperror.ErrorWhoisTimeout, "whois.example", errors.New("i/o timeout"))
+	backend := &scriptedBackend{errs: []error{timeout}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := lookupWithRetry(ctx, backend, "example.com")
+
+	if !errors.Is(err, lookuperror.ErrorLookupCanceled) {
+		t.Errorf("lookupWithRetry() error = %v, want %s", err, lookuperror.ErrorLookupCanceled)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("lookupWithRetry() took %s after the context was canceled", elapsed)
+	}
+}
@@ -0,0 +1,180 @@
+package lookuper
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"typonamer/constant"
+	"typonamer/log"
+	"typonamer/lookup/lookupbackend"
+	"typonamer/lookup/lookuperror"
+	"typonamer/lookup/lookupinfo"
+	"typonamer/utils"
+)
+
+// verifySources are the backends queried by a verify lookup, the registration data is taken from them in this order.
+var verifySources = []string{
+	constant.LookupTypeRDAP,
+	constant.LookupTypeWhois,
+	constant.LookupTypeDNS,
+}
+
+// verifySourceResult is the lookup result of one source of a verify lookup.
+type verifySourceResult struct {
+	name       string
+	domainInfo lookupinfo.DomainInfo
+	err        error
+}
+
+// Verify queries the domain with every RDAP, WHOIS and DNS backend supporting the TLD at the same time,
+// and reconciles their results into one DomainInfo.
+// The domain is considered taken if any source says so, as a registration record or an NS delegation
+// can not exist for an available domain. The Conflict flag is set if the sources disagree.
+func Verify(ctx context.Context, mainDomain string, tld string, useProxy bool) (lookupinfo.DomainInfo, error) {
+	var domainInfo = lookupinfo.DomainInfo{
+		DomainName: mainDomain,
+		LookupType: constant.LookupTypeVerify,
+		ViaProxy:   useProxy,
+	}
+
+	backends := make([]lookupbackend.Backend, 0, len(verifySources))
+	for _, name := range verifySources {
+		backend, ok := lookupbackend.Get(name)
+		if ok && backend.Supports(tld) {
+			backends = append(backends, backend)
+		}
+	}
+	if len(backends) == 0 {
+		log.Error("No lookup source known for TLD: ", tld)
+		return domainInfo, lookuperror.Newf(lookuperror.ErrorNoWhoisServerForTld, "", "%s", tld)
+	}
+
+	// Query all sources at the same time
+	results := make([]verifySourceResult, len(backends))
+	proxyCtx := lookupbackend.WithProxy(ctx, useProxy)
+
+	var wg sync.WaitGroup
+	for i, backend := range backends {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sourceInfo, err := lookupWithRetry(proxyCtx, backend, mainDomain)
+			results[i] = verifySourceResult{name: backend.Name(), domainInfo: sourceInfo, err: err}
+		}()
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		log.Debugf("Verify query for domain %s canceled", mainDomain)
+		return domainInfo, lookuperror.New(lookuperror.ErrorLookupCanceled, "", ctx.Err())
+	}
+
+	return reconcileSources(domainInfo, results)
+}
+
+// reconcileSources combines the results of the sources of a verify lookup.
+func reconcileSources(domainInfo lookupinfo.DomainInfo, results []verifySourceResult) (lookupinfo.DomainInfo, error) {
+	var taken, free bool
+	var registryStatus string
+	var registrationData *lookupinfo.DomainInfo
+	var dnsNameServer []string
+	var dnsServer string
+	var sourceErrors []string
+	var rawResponses []string
+
+	for i, result := range results {
+		status := utils.GetLookupRegisterStatus(result.domainInfo, result.err)
+
+		domainInfo.Sources = append(domainInfo.Sources, lookupinfo.SourceResult{
+			LookupType:     result.name,
+			RegisterStatus: status,
+			QueryError:     utils.GetDomainHumanError(result.err),
+			CreatedDate:    result.domainInfo.CreationDate,
+			ExpiryDate:     result.domainInfo.ExpiryDate,
+			NameServer:     result.domainInfo.NameServer,
+		})
+
+		domainInfo.Trace.Attempts = append(domainInfo.Trace.Attempts, result.domainInfo.Trace.Attempts...)
+		if result.domainInfo.Trace.Proxy != "" {
+			domainInfo.Trace.Proxy = result.domainInfo.Trace.Proxy
+		}
+
+		switch status {
+		case constant.DomainRegisterStatusTaken:
+			taken = true
+			if result.name == constant.LookupTypeDNS {
+				dnsNameServer = result.domainInfo.NameServer
+				dnsServer = result.domainInfo.Trace.Server
+				domainInfo.DnsSignals = result.domainInfo.DnsSignals
+			} else if registrationData == nil {
+				registrationData = &results[i].domainInfo
+			}
+		case constant.DomainRegisterStatusFree:
+			free = true
+		case constant.DomainRegisterStatusReserved, constant.DomainRegisterStatusPremium, constant.DomainRegisterStatusBlocked:
+			if registryStatus == "" {
+				registryStatus = status
+				registrationData = &results[i].domainInfo
+			}
+		default:
+			sourceErrors = append(sourceErrors, fmt.Sprintf("%s: %s", result.name, result.err))
+		}
+
+		if result.domainInfo.RawResponse != "" {
+			rawResponses = append(rawResponses, fmt.Sprintf("===== %s =====\n%s", result.name, result.domainInfo.RawResponse))
+		}
+	}
+
+	domainInfo.RawResponse = strings.Join(rawResponses, "\n\n")
+	domainInfo.Conflict = taken && free
+
+	if registrationData != nil {
+		domainInfo.Registrar = registrationData.Registrar
+		domainInfo.DomainStatus = registrationData.DomainStatus
+		domainInfo.CreationDate = registrationData.CreationDate
+		domainInfo.ExpiryDate = registrationData.ExpiryDate
+		domainInfo.NameServer = registrationData.NameServer
+		domainInfo.UpdatedDate = registrationData.UpdatedDate
+		domainInfo.RegistrarIanaId = registrationData.RegistrarIanaId
+		domainInfo.RegistrantOrganization = registrationData.RegistrantOrganization
+		domainInfo.RegistrantCountry = registrationData.RegistrantCountry
+		domainInfo.RegistrarAbuseEmail = registrationData.RegistrarAbuseEmail
+		domainInfo.RegistrarAbusePhone = registrationData.RegistrarAbusePhone
+		domainInfo.Dnssec = registrationData.Dnssec
+		domainInfo.DsData = registrationData.DsData
+		domainInfo.Privacy = registrationData.Privacy
+		domainInfo.RedactedFields = registrationData.RedactedFields
+		domainInfo.Events = registrationData.Events
+		domainInfo.Trace.Server = registrationData.Trace.Server
+	}
+	if len(domainInfo.NameServer) == 0 {
+		domainInfo.NameServer = dnsNameServer
+	}
+	if domainInfo.Trace.Server == "" {
+		domainInfo.Trace.Server = dnsServer
+	}
+
+	// The registry knows best whether it holds the name back, so its status comes before the others
+	switch {
+	case registryStatus != "":
+		domainInfo.ConsensusStatus = registryStatus
+		domainInfo.RegistryStatus = registryStatus
+	case taken:
+		domainInfo.ConsensusStatus = constant.DomainRegisterStatusTaken
+	case free:
+		domainInfo.ConsensusStatus = constant.DomainRegisterStatusFree
+	default:
+		domainInfo.ConsensusStatus = constant.DomainRegisterStatusError
+		return domainInfo, lookuperror.Newf(lookuperror.ErrorAllSourcesFailed, "", "%s", strings.Join(sourceErrors, "; "))
+	}
+
+	if domainInfo.Conflict {
+		log.Warnf("Lookup sources of domain %s disagree: %s", domainInfo.DomainName, utils.GetSourcesSummary(domainInfo.Sources))
+	}
+
+	log.Debugf("verify query domain result: \n%+v", domainInfo)
+
+	return domainInfo, nil
+}
@@ -1,6 +1,10 @@
 package lookuperror
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 var (
 	ErrorInvalidDomainName        = errors.New("invalid domain name")
@@ -9,6 +13,8 @@ var (
 	ErrorWhoisNotFound            = errors.New("whois not found")
 	ErrorNsNotFound               = errors.New("dns ns record not found")
 	ErrorWhoisServerFailed        = errors.New("whois server failed")
+	ErrorWhoisRateLimited         = errors.New("whois rate limited")
+	ErrorRdapRateLimited          = errors.New("rdap rate limited")
 	ErrorConnectToProxy           = errors.New("connect to proxy failed")
 	ErrorNoContentInWhoisResponse = errors.New("no content in whois response")
 	ErrorNoParseRuleForTld        = errors.New("no parsing rule for tld")
@@ -16,6 +22,9 @@ var (
 	ErrorInvalidQueryType         = errors.New("invalid query type")
 	ErrorInvalidLookupType        = errors.New("invalid lookup type")
 	ErrorNoWhoisServerForTld      = errors.New("no whois server for tld")
+	ErrorLookupCanceled           = errors.New("lookup canceled")
+	ErrorAllSourcesFailed         = errors.New("all lookup sources failed")
+	ErrorInvalidConfig            = errors.New("invalid config")
 
 	ErrorCustomizeApiServerResponse = errors.New("customize api server response error")
 	ErrorCustomizeApiWhoisResult    = errors.New("customize api whois result error")
@@ -25,3 +34,230 @@ var (
 	ErrorDnsTimeout      = errors.New("dns query timeout")
 	ErrorDnsServerFailed = errors.New("dns server failed")
 )
+
+// knownErrors are the sentinel errors which can be restored from their messages.
+var knownErrors = []error{
+	ErrorInvalidDomainName,
+	ErrorWhoisTimeout,
+	ErrorNotSupportedTld,
+	ErrorWhoisNotFound,
+	ErrorNsNotFound,
+	ErrorWhoisServerFailed,
+	ErrorWhoisRateLimited,
+	ErrorRdapRateLimited,
+	ErrorConnectToProxy,
+	ErrorNoContentInWhoisResponse,
+	ErrorNoParseRuleForTld,
+	ErrorParseWhoisResponse,
+	ErrorInvalidQueryType,
+	ErrorInvalidLookupType,
+	ErrorNoWhoisServerForTld,
+	ErrorLookupCanceled,
+	ErrorAllSourcesFailed,
+	ErrorInvalidConfig,
+	ErrorCustomizeApiServerResponse,
+	ErrorCustomizeApiWhoisResult,
+	ErrorDnsTimeout,
+	ErrorDnsServerFailed,
+}
+
+// restoredError is an error restored from its message, it still wraps the original sentinel error.
+type restoredError struct {
+	sentinel error
+	text     string
+}
+
+func (e *restoredError) Error() string {
+	return e.text
+}
+
+func (e *restoredError) Unwrap() error {
+	return e.sentinel
+}
+
+// Sentinel returns the known sentinel error wrapped by err, or nil if err wraps none of them.
+func Sentinel(err error) error {
+	if lookupErr, ok := AsLookupError(err); ok {
+		return lookupErr.Sentinel
+	}
+	for _, knownError := range knownErrors {
+		if errors.Is(err, knownError) {
+			return knownError
+		}
+	}
+	return nil
+}
+
+// Restore rebuilds an error from its message and the message of the sentinel error it wraps,
+// so errors.Is keeps working on errors which are serialized, for example in the lookup cache.
+func Restore(sentinelText string, text string) error {
+	for _, knownError := range knownErrors {
+		if knownError.Error() == sentinelText {
+			return &restoredError{sentinel: knownError, text: text}
+		}
+	}
+	return errors.New(text)
+}
+
+// Kind is the class of a lookup failure.
+type Kind string
+
+const (
+	KindInvalidInput  Kind = "invalidInput"
+	KindNotFound      Kind = "notFound"
+	KindNotSupported  Kind = "notSupported"
+	KindTimeout       Kind = "timeout"
+	KindServerFailure Kind = "serverFailure"
+	KindRateLimited   Kind = "rateLimited"
+	KindProxy         Kind = "proxy"
+	KindEmptyResponse Kind = "emptyResponse"
+	KindParse         Kind = "parse"
+	KindCanceled      Kind = "canceled"
+	KindConfig        Kind = "config"
+	KindUnknown       Kind = "unknown"
+)
+
+// sentinelKinds maps the sentinel errors to their kinds.
+var sentinelKinds = map[error]Kind{
+	ErrorInvalidDomainName:          KindInvalidInput,
+	ErrorInvalidQueryType:           KindInvalidInput,
+	ErrorInvalidLookupType:          KindInvalidInput,
+	ErrorWhoisNotFound:              KindNotFound,
+	ErrorNsNotFound:                 KindNotFound,
+	ErrorNotSupportedTld:            KindNotSupported,
+	ErrorNoWhoisServerForTld:        KindNotSupported,
+	ErrorNoParseRuleForTld:          KindNotSupported,
+	ErrorWhoisTimeout:               KindTimeout,
+	ErrorDnsTimeout:                 KindTimeout,
+	ErrorWhoisServerFailed:          KindServerFailure,
+	ErrorDnsServerFailed:            KindServerFailure,
+	ErrorCustomizeApiServerResponse: KindServerFailure,
+	ErrorAllSourcesFailed:           KindServerFailure,
+	ErrorWhoisRateLimited:           KindRateLimited,
+	ErrorRdapRateLimited:            KindRateLimited,
+	ErrorConnectToProxy:             KindProxy,
+	ErrorNoContentInWhoisResponse:   KindEmptyResponse,
+	ErrorParseWhoisResponse:         KindParse,
+	ErrorCustomizeApiWhoisResult:    KindParse,
+	ErrorLookupCanceled:             KindCanceled,
+	ErrorInvalidConfig:              KindConfig,
+}
+
+// retryableKinds are the kinds of failures which may not happen again when the lookup is retried.
+var retryableKinds = []Kind{
+	KindTimeout,
+	KindServerFailure,
+	KindRateLimited,
+	KindProxy,
+	KindEmptyResponse,
+}
+
+// LookupError is the error returned by the lookup backends.
+// It wraps both the sentinel error of the failure and the underlying cause,
+// so errors.Is works with the sentinel errors as well as with the cause.
+type LookupError struct {
+	Kind       Kind          // Kind is the class of the failure.
+	Sentinel   error         // Sentinel is the sentinel error of the failure, such as ErrorWhoisTimeout.
+	Retryable  bool          // Retryable is the flag to indicate if the lookup may succeed when it is retried.
+	Server     string        // Server is the address of the server which failed, empty if no server is involved.
+	Attempts   int           // Attempts is the number of attempts made before giving up.
+	Cause      error         // Cause is the underlying error.
+	RetryAfter time.Duration // RetryAfter is how long the server asked to wait before it is queried again, zero if it gave no hint.
+}
+
+// New returns a LookupError of the sentinel error, the kind and the retryable flag are derived from the sentinel.
+func New(sentinel error, server string, cause error) *LookupError {
+	kind, ok := sentinelKinds[sentinel]
+	if !ok {
+		kind = KindUnknown
+	}
+
+	return &LookupError{
+		Kind:      kind,
+		Sentinel:  sentinel,
+		Retryable: isRetryableKind(kind),
+		Server:    server,
+		Attempts:  1,
+		Cause:     cause,
+	}
+}
+
+// Newf returns a LookupError of the sentinel error with the cause formatted from the arguments.
+func Newf(sentinel error, server string, format string, args ...any) *LookupError {
+	return New(sentinel, server, fmt.Errorf(format, args...))
+}
+
+func (e *LookupError) Error() string {
+	message := e.Sentinel.Error()
+	if e.Server != "" {
+		message = fmt.Sprintf("%s (server %s)", message, e.Server)
+	}
+	if e.Cause != nil {
+		message = fmt.Sprintf("%s: %s", message, e.Cause)
+	}
+	return message
+}
+
+func (e *LookupError) Unwrap() []error {
+	if e.Cause == nil {
+		return []error{e.Sentinel}
+	}
+	return []error{e.Sentinel, e.Cause}
+}
+
+// AsLookupError returns the LookupError in the chain of err.
+func AsLookupError(err error) (*LookupError, bool) {
+	var lookupErr *LookupError
+	if errors.As(err, &lookupErr) {
+		return lookupErr, true
+	}
+	return nil, false
+}
+
+// KindOf returns the kind of err, errors which are not LookupError are classified by the sentinel errors they wrap.
+func KindOf(err error) Kind {
+	if lookupErr, ok := AsLookupError(err); ok {
+		return lookupErr.Kind
+	}
+	if kind, ok := sentinelKinds[Sentinel(err)]; ok {
+		return kind
+	}
+	return KindUnknown
+}
+
+// IsRetryable reports whether the lookup may succeed when it is retried after failing with err.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if lookupErr, ok := AsLookupError(err); ok {
+		return lookupErr.Retryable
+	}
+	return isRetryableKind(KindOf(err))
+}
+
+// isRetryableKind reports whether the failures of the kind may not happen again when the lookup is retried.
+func isRetryableKind(kind Kind) bool {
+	for _, retryableKind := range retryableKinds {
+		if kind == retryableKind {
+			return true
+		}
+	}
+	return false
+}
+
+// RetryAfterOf returns how long the server asked to wait before it is queried again, zero if err gives no hint.
+func RetryAfterOf(err error) time.Duration {
+	if lookupErr, ok := AsLookupError(err); ok {
+		return lookupErr.RetryAfter
+	}
+	return 0
+}
+
+// WithAttempts records the number of attempts made in the LookupError of err, other errors are returned as is.
+func WithAttempts(err error, attempts int) error {
+	if lookupErr, ok := AsLookupError(err); ok {
+		lookupErr.Attempts = attempts
+	}
+	return err
+}
@@ -2,44 +2,178 @@ package lookupinfo
 
 // DomainInfo represents the information about a domain.
 type DomainInfo struct {
-	LookupType       string   `json:"LookupType"`       // LookupType is the type of lookup.
-	ViaProxy         bool     `json:"ViaProxy"`         // ViaProxy is the flag to indicate if the lookup is via proxy.
-	DomainName       string   `json:"DomainName"`       // DomainName is the name of the domain.
-	Registrar        string   `json:"Registrar"`        // Registrar is the registrar of the domain.
-	DomainStatus     []string `json:"DomainStatus"`     // DomainStatus is the status of the domain.
-	CreationDate     string   `json:"CreationDate"`     // CreationDate is the creation date of the domain.
-	ExpiryDate       string   `json:"ExpiryDate"`       // ExpiryDate is the expiry date of the domain.
-	NameServer       []string `json:"NameServer"`       // NameServer is the name server of the domain.
-	RawResponse      string   `json:"RawResponse"`      // RawResponse is the raw response of the lookup.
-	CustomizedResult string   `json:"CustomizedResult"` // CustomizedResult is the customized result of the lookup.
+	LookupType             string         `json:"LookupType"`             // LookupType is the type of lookup.
+	ViaProxy               bool           `json:"ViaProxy"`               // ViaProxy is the flag to indicate if the lookup is via proxy.
+	DomainName             string         `json:"DomainName"`             // DomainName is the name of the domain.
+	Registrar              string         `json:"Registrar"`              // Registrar is the registrar of the domain.
+	DomainStatus           []string       `json:"DomainStatus"`           // DomainStatus is the status of the domain.
+	CreationDate           string         `json:"CreationDate"`           // CreationDate is the creation date of the domain.
+	ExpiryDate             string         `json:"ExpiryDate"`             // ExpiryDate is the expiry date of the domain.
+	NameServer             []string       `json:"NameServer"`             // NameServer is the name server of the domain.
+	UpdatedDate            string         `json:"UpdatedDate"`            // UpdatedDate is the last update date of the domain.
+	RegistrarIanaId        string         `json:"RegistrarIanaId"`        // RegistrarIanaId is the IANA ID of the registrar.
+	RegistrantOrganization string         `json:"RegistrantOrganization"` // RegistrantOrganization is the organisation of the registrant.
+	RegistrantCountry      string         `json:"RegistrantCountry"`      // RegistrantCountry is the country of the registrant.
+	RegistrarAbuseEmail    string         `json:"RegistrarAbuseEmail"`    // RegistrarAbuseEmail is the abuse contact email of the registrar.
+	RegistrarAbusePhone    string         `json:"RegistrarAbusePhone"`    // RegistrarAbusePhone is the abuse contact phone of the registrar.
+	Dnssec                 string         `json:"Dnssec"`                 // Dnssec is "signed" or "unsigned" as given by the registry, empty if it is not given.
+	DsData                 []string       `json:"DsData"`                 // DsData is the DS records of the domain, such as "12345 13 2 <digest>".
+	Privacy                bool           `json:"Privacy"`                // Privacy is the flag to indicate if the registrant data is redacted or hidden by a privacy service.
+	RedactedFields         []string       `json:"RedactedFields"`         // RedactedFields is the fields redacted from the response, such as "Registrant Name".
+	DnsSignals             DnsSignals     `json:"DnsSignals"`             // DnsSignals is what the name servers of the domain tell about it, only collected by the DNS lookup.
+	RegistryStatus         string         `json:"RegistryStatus"`         // RegistryStatus is Reserved, Premium or Blocked if the registry gives such a status, empty otherwise.
+	Events                 []DomainEvent  `json:"Events"`                 // Events is every event of the domain given by the RDAP server, such as the registration, transfer and deletion.
+	ReferralServers        []string       `json:"ReferralServers"`        // ReferralServers is the registrar WHOIS servers followed from the registry response.
+	RawResponse            string         `json:"RawResponse"`            // RawResponse is the raw response of the lookup.
+	CustomizedResult       string         `json:"CustomizedResult"`       // CustomizedResult is the customized result of the lookup.
+	FromCache              bool           `json:"FromCache"`              // FromCache is the flag to indicate if the result is loaded from the lookup cache.
+	CachedAt               string         `json:"CachedAt"`               // CachedAt is the time when the result was saved to the lookup cache.
+	ConsensusStatus        string         `json:"ConsensusStatus"`        // ConsensusStatus is the register status reconciled from all sources of a verify lookup.
+	Conflict               bool           `json:"Conflict"`               // Conflict is the flag to indicate if the sources of a verify lookup disagree.
+	Sources                []SourceResult `json:"Sources"`                // Sources is the result of every source of a verify lookup.
+	FallbackFrom           []string       `json:"FallbackFrom"`           // FallbackFrom is the sources which failed before the answering source in the fallback order.
+	Trace                  LookupTrace    `json:"Trace"`                  // Trace is how the lookup was done, such as the servers queried and the attempts made.
+}
+
+// LookupTrace represents how a lookup was done.
+type LookupTrace struct {
+	Server   string         `json:"server"`   // Server is the address of the server which answered the lookup.
+	Proxy    string         `json:"proxy"`    // Proxy is the address of the proxy used by the lookup, empty if no proxy is used.
+	Attempts []TraceAttempt `json:"attempts"` // Attempts is every attempt made by the lookup, including the retries and fallbacks.
+}
+
+// TraceAttempt represents one attempt of a lookup.
+type TraceAttempt struct {
+	Source    string `json:"source"`    // Source is the name of the backend queried.
+	Server    string `json:"server"`    // Server is the address of the server queried.
+	LatencyMs int64  `json:"latencyMs"` // LatencyMs is the duration of the attempt in milliseconds.
+	Error     string `json:"error"`     // Error is the error of the attempt, empty if the attempt succeeded.
+}
+
+// DomainEvent represents an event in the life of a domain, as given by the RDAP server.
+type DomainEvent struct {
+	Action string `json:"action"` // Action is the event action in lowercase, such as "registration", "last changed" or "deletion".
+	Date   string `json:"date"`   // Date is the date of the event in UTC.
+	Actor  string `json:"actor"`  // Actor is the handle of the entity which caused the event, empty if it is not given.
+}
+
+// DomainLifecycle represents where a domain is in the registration lifecycle of the registry.
+type DomainLifecycle struct {
+	Phase   string   `json:"phase"`   // Phase is the lifecycle phase, such as Active, AddGracePeriod, RedemptionPeriod or PendingDelete.
+	Since   string   `json:"since"`   // Since is when the domain entered the phase, empty if the events do not tell.
+	Until   string   `json:"until"`   // Until is when the phase ends, only given when the registry announces the deletion.
+	Hold    bool     `json:"hold"`    // Hold is the flag to indicate if the domain is not published in the DNS by a client or server hold.
+	Locks   []string `json:"locks"`   // Locks is the prohibited operations, such as "clientTransferProhibited".
+	Pending []string `json:"pending"` // Pending is the operations waiting to complete, such as "pendingTransfer".
+}
+
+// DnsSignals represents what the DNS tells about a delegated domain beyond its name servers.
+type DnsSignals struct {
+	State      string   `json:"state"`      // State is the summary of the signals, such as Lame, Parked, LiveWithMail or Live, empty if they are not collected.
+	Lame       bool     `json:"lame"`       // Lame is the flag to indicate if none of the name servers of the domain answers authoritatively for it.
+	SoaSerial  uint32   `json:"soaSerial"`  // SoaSerial is the serial of the SOA record of the domain.
+	SoaContact string   `json:"soaContact"` // SoaContact is the email of the SOA record, converted from its mailbox name.
+	Addresses  []string `json:"addresses"`  // Addresses is the IPv4 and IPv6 addresses of the A and AAAA records of the domain.
+	Mx         []string `json:"mx"`         // Mx is the mail exchangers of the MX records of the domain, by preference.
+	HasDs      bool     `json:"hasDs"`      // HasDs is the flag to indicate if the parent zone has DS records for the domain.
+	HasDnskey  bool     `json:"hasDnskey"`  // HasDnskey is the flag to indicate if the domain has DNSKEY records.
+}
+
+// RegistrationDetails represents the registration data of a domain beyond its dates and name servers.
+type RegistrationDetails struct {
+	Registrar              string          `json:"registrar"`              // Registrar is the registrar of the domain.
+	UpdatedDate            string          `json:"updatedDate"`            // UpdatedDate is the last update date of the domain.
+	RegistrarIanaId        string          `json:"registrarIanaId"`        // RegistrarIanaId is the IANA ID of the registrar.
+	RegistrarAbuseEmail    string          `json:"registrarAbuseEmail"`    // RegistrarAbuseEmail is the abuse contact email of the registrar.
+	RegistrarAbusePhone    string          `json:"registrarAbusePhone"`    // RegistrarAbusePhone is the abuse contact phone of the registrar.
+	RegistrantOrganization string          `json:"registrantOrganization"` // RegistrantOrganization is the organisation of the registrant.
+	RegistrantCountry      string          `json:"registrantCountry"`      // RegistrantCountry is the country of the registrant.
+	Dnssec                 string          `json:"dnssec"`                 // Dnssec is "signed" or "unsigned", empty if it is not given.
+	DsData                 []string        `json:"dsData"`                 // DsData is the DS records of the domain.
+	Privacy                bool            `json:"privacy"`                // Privacy is the flag to indicate if the registrant data is redacted or hidden by a privacy service.
+	RedactedFields         []string        `json:"redactedFields"`         // RedactedFields is the fields redacted from the response.
+	Events                 []DomainEvent   `json:"events"`                 // Events is every event of the domain given by the RDAP server.
+	Lifecycle              DomainLifecycle `json:"lifecycle"`              // Lifecycle is the lifecycle phase mapped from the domain statuses and events.
+}
+
+// SourceResult represents the result of one source in a verify lookup.
+type SourceResult struct {
+	LookupType     string   `json:"lookupType"`
+	RegisterStatus string   `json:"registerStatus"`
+	QueryError     string   `json:"queryError"`
+	CreatedDate    string   `json:"createdDate"`
+	ExpiryDate     string   `json:"expiryDate"`
+	NameServer     []string `json:"nameServer"`
 }
 
 type QueryResult struct {
-	Order           int      `json:"order"`
-	Domain          string   `json:"domain"`
-	LookupType      string   `json:"lookupType"`
-	ViaProxy        bool     `json:"viaProxy"`
-	QueryError      string   `json:"queryError"`
-	RegisterStatus  string   `json:"registerStatus"`
-	CreatedDate     string   `json:"createdDate"`
-	ExpiryDate      string   `json:"expiryDate"`
-	NameServer      []string `json:"nameServer"`
-	DnsLite         string   `json:"dnsLite"`
-	RawDomainStatus []string `json:"rawDomainStatus"`
-	DomainStatus    string   `json:"domainStatus"`
-	RawResponse     string   `json:"rawResponse"`
+	Order           int                 `json:"order"`
+	Domain          string              `json:"domain"`
+	LookupType      string              `json:"lookupType"`
+	ViaProxy        bool                `json:"viaProxy"`
+	QueryError      string              `json:"queryError"`
+	RegisterStatus  string              `json:"registerStatus"`
+	CreatedDate     string              `json:"createdDate"`
+	ExpiryDate      string              `json:"expiryDate"`
+	NameServer      []string            `json:"nameServer"`
+	DnsLite         string              `json:"dnsLite"`
+	RawDomainStatus []string            `json:"rawDomainStatus"`
+	DomainStatus    string              `json:"domainStatus"`
+	RawResponse     string              `json:"rawResponse"`
+	FromCache       bool                `json:"fromCache"`
+	CachedAt        string              `json:"cachedAt"`
+	Conflict        bool                `json:"conflict"`
+	Sources         []SourceResult      `json:"sources"`
+	FallbackFrom    []string            `json:"fallbackFrom"`
+	Trace           LookupTrace         `json:"trace"`
+	Details         RegistrationDetails `json:"details"`
+	DnsSignals      DnsSignals          `json:"dnsSignals"`
 }
 
 type QueryCsvResult struct {
-	Domain          string `csv:"Domain"`
-	LookupType      string `csv:"Lookup Type"`
-	ViaProxy        string `csv:"Via Proxy,omitempty"`
-	Status          string `csv:"Status"`
-	ErrorInfo       string `csv:"Error Info,omitempty"`
-	CreatedDate     string `csv:"Created Date,omitempty"`
-	ExpiryDate      string `csv:"Expiry Date,omitempty"`
-	NameServer      string `csv:"Name Server,omitempty"`
-	DnsLite         string `csv:"Dns Lite,omitempty"`
-	RawDomainStatus string `csv:"Raw Domain Status,omitempty"`
-	DomainStatus    string `csv:"Domain Status,omitempty"`
+	Domain                 string `csv:"Domain"`
+	LookupType             string `csv:"Lookup Type"`
+	ViaProxy               string `csv:"Via Proxy,omitempty"`
+	Status                 string `csv:"Status"`
+	ErrorInfo              string `csv:"Error Info,omitempty"`
+	CreatedDate            string `csv:"Created Date,omitempty"`
+	ExpiryDate             string `csv:"Expiry Date,omitempty"`
+	NameServer             string `csv:"Name Server,omitempty"`
+	DnsLite                string `csv:"Dns Lite,omitempty"`
+	RawDomainStatus        string `csv:"Raw Domain Status,omitempty"`
+	DomainStatus           string `csv:"Domain Status,omitempty"`
+	CachedAt               string `csv:"Cached At,omitempty"`
+	Conflict               string `csv:"Conflict,omitempty"`
+	Sources                string `csv:"Sources,omitempty"`
+	FallbackFrom           string `csv:"Fallback From,omitempty"`
+	Server                 string `csv:"Server,omitempty"`
+	Proxy                  string `csv:"Proxy,omitempty"`
+	Attempts               string `csv:"Attempts,omitempty"`
+	LatencyMs              string `csv:"Latency Ms,omitempty"`
+	AttemptErrors          string `csv:"Attempt Errors,omitempty"`
+	Registrar              string `csv:"Registrar,omitempty"`
+	UpdatedDate            string `csv:"Updated Date,omitempty"`
+	RegistrarIanaId        string `csv:"Registrar IANA ID,omitempty"`
+	RegistrarAbuseEmail    string `csv:"Registrar Abuse Email,omitempty"`
+	RegistrarAbusePhone    string `csv:"Registrar Abuse Phone,omitempty"`
+	RegistrantOrganization string `csv:"Registrant Organization,omitempty"`
+	RegistrantCountry      string `csv:"Registrant Country,omitempty"`
+	Dnssec                 string `csv:"DNSSEC,omitempty"`
+	DsData                 string `csv:"DS Data,omitempty"`
+	Privacy                string `csv:"Privacy,omitempty"`
+	RedactedFields         string `csv:"Redacted Fields,omitempty"`
+	LifecyclePhase         string `csv:"Lifecycle Phase,omitempty"`
+	LifecycleSince         string `csv:"Lifecycle Since,omitempty"`
+	LifecycleUntil         string `csv:"Lifecycle Until,omitempty"`
+	Hold                   string `csv:"Hold,omitempty"`
+	Locks                  string `csv:"Locks,omitempty"`
+	Pending                string `csv:"Pending,omitempty"`
+	Events                 string `csv:"Events,omitempty"`
+	DnsState               string `csv:"DNS State,omitempty"`
+	SoaSerial              string `csv:"SOA Serial,omitempty"`
+	SoaContact             string `csv:"SOA Contact,omitempty"`
+	Addresses              string `csv:"Addresses,omitempty"`
+	Mx                     string `csv:"MX,omitempty"`
+	HasDs                  string `csv:"Has DS,omitempty"`
+	HasDnskey              string `csv:"Has DNSKEY,omitempty"`
 }
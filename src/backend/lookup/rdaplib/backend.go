@@ -0,0 +1,39 @@
+package rdaplib
+
+import (
+	"context"
+
+	"typonamer/constant"
+	"typonamer/lookup/lookupbackend"
+	"typonamer/lookup/lookuperror"
+	"typonamer/lookup/lookupinfo"
+	"typonamer/utils"
+)
+
+// rdapBackend is the lookup backend that queries the RDAP servers.
+type rdapBackend struct{}
+
+func init() {
+	lookupbackend.Register(rdapBackend{})
+}
+
+// Name returns the name of the RDAP backend.
+func (rdapBackend) Name() string {
+	return constant.LookupTypeRDAP
+}
+
+// Supports reports whether the RDAP server for the TLD is known, from the configuration or the bootstrap file.
+func (rdapBackend) Supports(tld string) bool {
+	_, ok := getRdapServer(tld)
+	return ok
+}
+
+// Lookup queries the RDAP information of the domain.
+func (rdapBackend) Lookup(ctx context.Context, domain string) (lookupinfo.DomainInfo, error) {
+	tld, _, err := utils.GetTld(domain)
+	if err != nil {
+		return lookupinfo.DomainInfo{DomainName: domain, LookupType: constant.LookupTypeRDAP}, lookuperror.New(lookuperror.ErrorInvalidDomainName, "", err)
+	}
+
+	return RDAPQuery(ctx, domain, tld, lookupbackend.UseProxy(ctx))
+}
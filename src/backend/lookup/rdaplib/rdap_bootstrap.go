@@ -0,0 +1,343 @@
+package rdaplib
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"typonamer/config"
+	"typonamer/log"
+
+	"github.com/duke-git/lancet/v2/strutil"
+	"golang.org/x/net/idna"
+)
+
+const (
+	// rdapBootstrapFileName is the name of the RDAP bootstrap file of the domains, in the directory of the config file.
+	rdapBootstrapFileName = "rdap_dns.json"
+
+	// defaultRdapBootstrapUrl is where the bootstrap file is downloaded from if no URL is configured.
+	defaultRdapBootstrapUrl = "https://data.iana.org/rdap/dns.json"
+
+	// rdapBootstrapDownloadTimeout is the timeout of downloading the bootstrap file.
+	rdapBootstrapDownloadTimeout = 30 * time.Second
+
+	// rdapBootstrapMaxSize is the maximum size of the bootstrap file accepted, the IANA file is about 40 KB.
+	rdapBootstrapMaxSize = 4 << 20
+)
+
+// ErrorInvalidRdapBootstrap is returned when the RDAP bootstrap file can not be parsed or has no service.
+var ErrorInvalidRdapBootstrap = errors.New("invalid rdap bootstrap file")
+
+// bundledRdapBootstrap is a snapshot of the RDAP bootstrap file of IANA, used until a newer one is loaded or downloaded.
+// Refresh it with go generate before a release.
+//
+//go:generate curl -fsSL -o rdap_dns.json https://data.iana.org/rdap/dns.json
+//go:embed rdap_dns.json
+var bundledRdapBootstrap []byte
+
+// RdapBootstrap is the RDAP bootstrap file of the domains in use, in the format of RFC 9224.
+type RdapBootstrap struct {
+	Description string            `json:"description"` // Description is the description of the file.
+	Publication string            `json:"publication"` // Publication is the time the file was published.
+	Version     string            `json:"version"`     // Version is the version of the file format.
+	Servers     map[string]string `json:"servers"`     // Servers is the RDAP base URL of every TLD of the file.
+}
+
+// RdapBootstrapInfo is the RDAP bootstrap file in use and the RDAP base URLs used for the TLDs.
+type RdapBootstrapInfo struct {
+	Description string            `json:"description"` // Description is the description of the bootstrap file.
+	Publication string            `json:"publication"` // Publication is the time the bootstrap file was published.
+	Version     string            `json:"version"`     // Version is the version of the bootstrap file format.
+	Servers     map[string]string `json:"servers"`     // Servers is the RDAP base URL of every TLD, including the configured ones.
+	Configured  []string          `json:"configured"`  // Configured is the TLDs whose RDAP base URL is configured instead of taken from the file.
+}
+
+var (
+	currentBootstrap atomic.Pointer[RdapBootstrap]
+
+	// rdapBootstrapMux serializes the updates of the bootstrap file.
+	rdapBootstrapMux sync.Mutex
+)
+
+func init() {
+	bootstrap, err := ParseRdapBootstrap(bundledRdapBootstrap)
+	if err != nil {
+		log.Errorf("Failed to parse the bundled RDAP bootstrap file: %s", err)
+		bootstrap = RdapBootstrap{Servers: map[string]string{}}
+	}
+	currentBootstrap.Store(&bootstrap)
+
+	// The first start has no bootstrap file, it is downloaded by DownloadMissingRdapBootstrap
+	err = LoadRdapBootstrap()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Errorf("Failed to load the RDAP bootstrap file, using the bundled one: %s", err)
+	}
+}
+
+// DownloadMissingRdapBootstrap downloads the bootstrap file if there is none yet, such as on the first start.
+// The bundled bootstrap is used until the download succeeds.
+func DownloadMissingRdapBootstrap(ctx context.Context) {
+	_, err := os.Stat(rdapBootstrapFile())
+	if !errors.Is(err, os.ErrNotExist) {
+		return
+	}
+
+	log.Infof("RDAP bootstrap file %s not found, downloading it", rdapBootstrapFile())
+	_, err = RefreshRdapBootstrap(ctx)
+	if err != nil {
+		log.Errorf("Failed to download the RDAP bootstrap file, using the bundled one: %s", err)
+	}
+}
+
+// getRdapServer returns the RDAP base URL of the TLD, the configured one is used before the one of the bootstrap file.
+func getRdapServer(tld string) (string, bool) {
+	tld = normalizeRdapTld(tld)
+
+	for _, rdapServer := range config.GetConfig().TldRdapServers {
+		if normalizeRdapTld(rdapServer.Tld) == tld && rdapServer.Url != "" {
+			return rdapServer.Url, true
+		}
+	}
+
+	server, ok := currentBootstrap.Load().Servers[tld]
+	return server, ok
+}
+
+// GetRdapBootstrapInfo returns the bootstrap file in use and the RDAP base URLs used for the TLDs.
+func GetRdapBootstrapInfo() RdapBootstrapInfo {
+	bootstrap := currentBootstrap.Load()
+
+	info := RdapBootstrapInfo{
+		Description: bootstrap.Description,
+		Publication: bootstrap.Publication,
+		Version:     bootstrap.Version,
+		Servers:     make(map[string]string, len(bootstrap.Servers)),
+		Configured:  []string{},
+	}
+	for tld, server := range bootstrap.Servers {
+		info.Servers[tld] = server
+	}
+	for _, rdapServer := range config.GetConfig().TldRdapServers {
+		tld := normalizeRdapTld(rdapServer.Tld)
+		if tld == "" || rdapServer.Url == "" {
+			continue
+		}
+		info.Servers[tld] = rdapServer.Url
+		info.Configured = append(info.Configured, tld)
+	}
+
+	return info
+}
+
+// ParseRdapBootstrap parses the RDAP bootstrap file of the domains, such as the dns.json file of IANA.
+// The HTTPS base URL of a service is used if it has one, the TLDs are kept in their Unicode form.
+func ParseRdapBootstrap(data []byte) (RdapBootstrap, error) {
+	var file struct {
+		Description string       `json:"description"`
+		Publication string       `json:"publication"`
+		Version     string       `json:"version"`
+		Services    [][][]string `json:"services"`
+	}
+	err := json.Unmarshal(data, &file)
+	if err != nil {
+		return RdapBootstrap{}, fmt.Errorf("%w: %s", ErrorInvalidRdapBootstrap, err)
+	}
+
+	bootstrap := RdapBootstrap{
+		Description: file.Description,
+		Publication: file.Publication,
+		Version:     file.Version,
+		Servers:     make(map[string]string),
+	}
+
+	for i, service := range file.Services {
+		if len(service) != 2 {
+			return RdapBootstrap{}, fmt.Errorf("%w: service %d is not a pair of TLDs and URLs", ErrorInvalidRdapBootstrap, i+1)
+		}
+
+		server := selectRdapBaseUrl(service[1])
+		if server == "" {
+			log.Warnf("RDAP bootstrap service %d has no valid URL, skipping it", i+1)
+			continue
+		}
+
+		for _, tld := range service[0] {
+			if tld = normalizeRdapTld(tld); tld != "" {
+				bootstrap.Servers[tld] = server
+			}
+		}
+	}
+
+	if len(bootstrap.Servers) == 0 {
+		return RdapBootstrap{}, fmt.Errorf("%w: no service found", ErrorInvalidRdapBootstrap)
+	}
+
+	return bootstrap, nil
+}
+
+// LoadRdapBootstrap reads the bootstrap file and puts it in use.
+// The bootstrap in use is kept if the file does not exist, is invalid or was published before it.
+func LoadRdapBootstrap() error {
+	rdapBootstrapMux.Lock()
+	defer rdapBootstrapMux.Unlock()
+
+	bootstrapFile := rdapBootstrapFile()
+
+	data, err := os.ReadFile(bootstrapFile)
+	if err != nil {
+		return err
+	}
+
+	bootstrap, err := ParseRdapBootstrap(data)
+	if err != nil {
+		return err
+	}
+
+	if inUse := currentBootstrap.Load(); isRdapBootstrapOlder(bootstrap, *inUse) {
+		log.Warnf("RDAP bootstrap file %s published at %s is older than the one in use published at %s, keeping the one in use",
+			bootstrapFile, bootstrap.Publication, inUse.Publication)
+		return nil
+	}
+
+	currentBootstrap.Store(&bootstrap)
+	log.Infof("Loaded RDAP servers of %d TLDs from %s, published at %s", len(bootstrap.Servers), bootstrapFile, bootstrap.Publication)
+
+	return nil
+}
+
+// UpdateRdapBootstrap validates the bootstrap file, saves it as the bootstrap file and puts it in use.
+// The bootstrap in use is kept if the file is invalid or can not be saved.
+func UpdateRdapBootstrap(data []byte) error {
+	rdapBootstrapMux.Lock()
+	defer rdapBootstrapMux.Unlock()
+
+	bootstrap, err := ParseRdapBootstrap(data)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so the bootstrap file is never left half written
+	bootstrapFile := rdapBootstrapFile()
+	tmpFile := bootstrapFile + ".tmp"
+	err = os.WriteFile(tmpFile, data, 0644)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmpFile, bootstrapFile)
+	if err != nil {
+		os.Remove(tmpFile)
+		return err
+	}
+
+	currentBootstrap.Store(&bootstrap)
+	log.Infof("Updated RDAP servers of %d TLDs in %s, published at %s", len(bootstrap.Servers), bootstrapFile, bootstrap.Publication)
+
+	return nil
+}
+
+// RefreshRdapBootstrap downloads the bootstrap file from the configured URL, and saves it and puts it in use if it is valid.
+func RefreshRdapBootstrap(ctx context.Context) (RdapBootstrapInfo, error) {
+	bootstrapUrl := config.GetConfig().RdapBootstrapUrl
+	if bootstrapUrl == "" {
+		bootstrapUrl = defaultRdapBootstrapUrl
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, rdapBootstrapDownloadTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, bootstrapUrl, nil)
+	if err != nil {
+		return RdapBootstrapInfo{}, err
+	}
+
+	// The download uses the direct RDAP transport for its TLS settings, with the longer timeout of the context
+	transport, err := getRdapTransport(false, currentRdapRoute(false))
+	if err != nil {
+		return RdapBootstrapInfo{}, err
+	}
+
+	resp, err := (&http.Client{Transport: transport}).Do(req)
+	if err != nil {
+		return RdapBootstrapInfo{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return RdapBootstrapInfo{}, fmt.Errorf("download %s: %s", bootstrapUrl, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, rdapBootstrapMaxSize))
+	if err != nil {
+		return RdapBootstrapInfo{}, err
+	}
+
+	err = UpdateRdapBootstrap(data)
+	if err != nil {
+		return RdapBootstrapInfo{}, err
+	}
+
+	log.Infof("Downloaded the RDAP bootstrap file from %s", bootstrapUrl)
+
+	return GetRdapBootstrapInfo(), nil
+}
+
+// isRdapBootstrapOlder returns true if the bootstrap was published before the other one.
+// A bootstrap whose publication time can not be parsed is not considered older, nor is any bootstrap older than it.
+func isRdapBootstrapOlder(bootstrap, other RdapBootstrap) bool {
+	published, err := time.Parse(time.RFC3339, bootstrap.Publication)
+	if err != nil {
+		return false
+	}
+	otherPublished, err := time.Parse(time.RFC3339, other.Publication)
+	if err != nil {
+		return false
+	}
+	return published.Before(otherPublished)
+}
+
+// selectRdapBaseUrl returns the first HTTPS base URL, or the first HTTP one if there is none, empty if no URL is valid.
+func selectRdapBaseUrl(rawUrls []string) string {
+	var httpUrl string
+	for _, rawUrl := range rawUrls {
+		baseUrl, err := url.Parse(strutil.Trim(rawUrl))
+		if err != nil || baseUrl.Host == "" {
+			continue
+		}
+
+		switch strings.ToLower(baseUrl.Scheme) {
+		case "https":
+			return baseUrl.String()
+		case "http":
+			if httpUrl == "" {
+				httpUrl = baseUrl.String()
+			}
+		}
+	}
+	return httpUrl
+}
+
+// normalizeRdapTld lowercases the TLD and converts it to its Unicode form, as the bootstrap files give the IDN TLDs as A-labels.
+func normalizeRdapTld(tld string) string {
+	tld = strings.ToLower(strutil.Trim(strutil.Trim(tld), "."))
+	if unicodeTld, err := idna.ToUnicode(tld); err == nil {
+		return unicodeTld
+	}
+	return tld
+}
+
+// rdapBootstrapFile returns the path of the bootstrap file.
+func rdapBootstrapFile() string {
+	return filepath.Join(config.GetConfigDir(), rdapBootstrapFileName)
+}
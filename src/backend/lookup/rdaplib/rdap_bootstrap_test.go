@@ -0,0 +1,66 @@
+package rdaplib
+
+import (
+	"os"
+	"testing"
+)
+
+// writeTestBootstrapFile writes the bootstrap file for the test, and removes it and puts the bootstrap in use back after it.
+func writeTestBootstrapFile(t *testing.T, data string) {
+	t.Helper()
+
+	inUse := currentBootstrap.Load()
+	if err := os.WriteFile(rdapBootstrapFile(), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Remove(rdapBootstrapFile())
+		currentBootstrap.Store(inUse)
+	})
+}
+
+// minBundledRdapTlds is the fewest TLDs the bundled bootstrap file may have, the IANA file has RDAP servers for over 1,100 TLDs.
+const minBundledRdapTlds = 1000
+
+func TestBundledRdapBootstrap(t *testing.T) {
+	bootstrap, err := ParseRdapBootstrap(bundledRdapBootstrap)
+	if err != nil {
+		t.Fatalf("ParseRdapBootstrap() error = %s for the bundled bootstrap file", err)
+	}
+	if len(bootstrap.Servers) < minBundledRdapTlds {
+		t.Errorf("bundled bootstrap file has RDAP servers of %d TLDs, want at least %d, refresh it with go generate",
+			len(bootstrap.Servers), minBundledRdapTlds)
+	}
+	if server := bootstrap.Servers["com"]; server == "" {
+		t.Error("bundled bootstrap file has no RDAP server for com")
+	}
+}
+
+func TestLoadRdapBootstrapKeepsNewer(t *testing.T) {
+	currentBootstrap.Store(&RdapBootstrap{
+		Publication: "2025-06-01T00:00:00Z",
+		Servers:     map[string]string{"test": "https://rdap.in-use.test/"},
+	})
+
+	tests := []struct {
+		name        string
+		publication string
+		want        string
+	}{
+		{"older file", "2025-01-01T00:00:00Z", "https://rdap.in-use.test/"},
+		{"newer file", "2025-12-01T00:00:00Z", "https://rdap.file.test/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeTestBootstrapFile(t, `{"publication": "`+tt.publication+`", "version": "1.0", "services": [[["test"], ["https://rdap.file.test/"]]]}`)
+
+			if err := LoadRdapBootstrap(); err != nil {
+				t.Fatalf("LoadRdapBootstrap() error = %s", err)
+			}
+			if server := currentBootstrap.Load().Servers["test"]; server != tt.want {
+				t.Errorf("RDAP server = %q, want %q", server, tt.want)
+			}
+		})
+	}
+}
@@ -26,15 +26,52 @@ func ParseRDAPResponseforDomain(response *rdap.Domain) lookupinfo.DomainInfo {
 		NameServer:   getNameServer(response.Nameservers),
 	}
 
-	// Get the creation and expiry date from the response events
-	creationDate, expiryDate := getDomainDates(response.Events)
+	// Get every event of the domain, and the creation, expiry and last update date from them
+	domainInfo.Events = getDomainEvents(response.Events)
+	creationDate, expiryDate, updatedDate := getDomainDates(domainInfo.Events)
 	domainInfo.CreationDate = creationDate
 	domainInfo.ExpiryDate = expiryDate
+	domainInfo.UpdatedDate = updatedDate
+
+	// Get the registrar and registrant details from the response entities
+	domainInfo.RegistrarIanaId = getRegistrarIanaId(response.Entities)
+	domainInfo.RegistrarAbuseEmail, domainInfo.RegistrarAbusePhone = getRegistrarAbuseContact(response.Entities)
+	domainInfo.RegistrantOrganization, domainInfo.RegistrantCountry = getRegistrant(response.Entities)
+
+	// Get the DNSSEC delegation from the response
+	domainInfo.Dnssec, domainInfo.DsData = getSecureDNS(response.SecureDNS)
+
+	// The registrant data is private if the registry redacted it or a privacy service is the registrant
+	domainInfo.RedactedFields = getRedactedFields(response.DecodeData)
+	domainInfo.Privacy = len(domainInfo.RedactedFields) > 0 || utils.IsPrivacyService(domainInfo.RegistrantOrganization)
+
+	// Get the reserved, premium or blocked status given by the registry
+	domainInfo.RegistryStatus = getRegistryStatus(response.Status)
 
 	// Return the parsed DomainInfo
 	return domainInfo
 }
 
+// rdapRegistryStatuses maps the RDAP statuses given to the domains which are held back by the registries to the register status.
+// They are not in the RDAP JSON values registry, but are used by some registries for the names which can not be registered normally.
+var rdapRegistryStatuses = map[string]string{
+	"reserved":          constant.DomainRegisterStatusReserved,
+	"registry reserved": constant.DomainRegisterStatusReserved,
+	"premium":           constant.DomainRegisterStatusPremium,
+	"blocked":           constant.DomainRegisterStatusBlocked,
+	"dpml blocked":      constant.DomainRegisterStatusBlocked,
+}
+
+// getRegistryStatus returns the register status of the first RDAP status held back by the registry, empty if there is none.
+func getRegistryStatus(statuses []string) string {
+	for _, status := range statuses {
+		if registryStatus, ok := rdapRegistryStatuses[strings.ToLower(strutil.Trim(status))]; ok {
+			return registryStatus
+		}
+	}
+	return ""
+}
+
 // getRegistrar is a function that takes the response entities and returns the registrar name.
 // It will loop through the entities and check if the entity has the role of "registrar".
 // If it does, it will check if the entity has a vcard property and if the vcard property has a "fn" property.
@@ -67,25 +104,162 @@ func getRegistrar(responseEntities []rdap.Entity) string {
 	return ""
 }
 
-// getDomainDates takes the response events and returns the creation and expiry dates of the domain.
-// It loops through the events and checks if the event action is "registration" or "expiration".
-// If the event action is "registration", it sets the creation date to the date of the event.
-// If the event action is "expiration", it sets the expiry date to the date of the event.
-// The dates are parsed using the Carbon library and set to UTC timezone.
-// The function returns the creation and expiry dates as a tuple.
-func getDomainDates(responseEvents []rdap.Event) (string, string) {
-	var creationDate, expiryDate string
+// getDomainEvents returns every event of the response, with the action in lowercase and the date in UTC.
+// The date is kept as given if it can not be parsed, so no event is lost.
+func getDomainEvents(responseEvents []rdap.Event) []lookupinfo.DomainEvent {
+	events := make([]lookupinfo.DomainEvent, 0, len(responseEvents))
 	for _, event := range responseEvents {
-		switch strings.ToLower(event.Action) {
+		date := carbon.SetTimezone(carbon.UTC).Parse(event.Date).ToDateTimeString()
+		if date == "" {
+			date = strutil.Trim(event.Date)
+		}
+
+		events = append(events, lookupinfo.DomainEvent{
+			Action: strings.ToLower(strutil.Trim(event.Action)),
+			Date:   date,
+			Actor:  strutil.Trim(event.Actor),
+		})
+	}
+	return events
+}
+
+// getDomainDates takes the events of the domain and returns the creation, expiry and last update dates of the domain.
+// The creation date is the date of the "registration" event, the expiry date the one of the "expiration" event,
+// and the update date the one of the "last changed" event.
+func getDomainDates(events []lookupinfo.DomainEvent) (string, string, string) {
+	var creationDate, expiryDate, updatedDate string
+	for _, event := range events {
+		switch event.Action {
 		case "registration":
-			// Set the creation date to the date of the event
-			creationDate = carbon.SetTimezone(carbon.UTC).Parse(event.Date).ToDateTimeString()
+			creationDate = event.Date
 		case "expiration":
-			// Set the expiry date to the date of the event
-			expiryDate = carbon.SetTimezone(carbon.UTC).Parse(event.Date).ToDateTimeString()
+			expiryDate = event.Date
+		case "last changed":
+			updatedDate = event.Date
+		}
+	}
+	return creationDate, expiryDate, updatedDate
+}
+
+// getEntityByRole returns the first entity with the role, or nil if no entity has the role.
+func getEntityByRole(responseEntities []rdap.Entity, role string) *rdap.Entity {
+	for i, entity := range responseEntities {
+		roles := slice.Map(entity.Roles, utils.LowerString)
+		if slice.Contain(roles, role) {
+			return &responseEntities[i]
+		}
+	}
+	return nil
+}
+
+// getRegistrarIanaId returns the IANA ID of the registrar, which is given as a public ID of the registrar entity.
+func getRegistrarIanaId(responseEntities []rdap.Entity) string {
+	registrar := getEntityByRole(responseEntities, "registrar")
+	if registrar == nil {
+		return ""
+	}
+
+	for _, publicId := range registrar.PublicIDs {
+		if strings.EqualFold(publicId.Type, "IANA Registrar ID") {
+			return strings.TrimSpace(publicId.Identifier)
+		}
+	}
+	return ""
+}
+
+// getRegistrarAbuseContact returns the email and phone of the abuse contact, which is an entity of the registrar entity.
+func getRegistrarAbuseContact(responseEntities []rdap.Entity) (string, string) {
+	registrar := getEntityByRole(responseEntities, "registrar")
+	if registrar == nil {
+		return "", ""
+	}
+
+	abuse := getEntityByRole(registrar.Entities, "abuse")
+	if abuse == nil || abuse.VCard == nil {
+		return "", ""
+	}
+
+	return abuse.VCard.Email(), strings.TrimPrefix(abuse.VCard.Tel(), "tel:")
+}
+
+// getRegistrant returns the organisation and the country of the registrant.
+// The country is the country code of the address if it is given, otherwise the country name.
+func getRegistrant(responseEntities []rdap.Entity) (string, string) {
+	registrant := getEntityByRole(responseEntities, "registrant")
+	if registrant == nil || registrant.VCard == nil {
+		return "", ""
+	}
+
+	var organization, country string
+	if org := registrant.VCard.GetFirst("org"); org != nil {
+		organization = strings.TrimSpace(strings.Join(org.Values(), " "))
+	}
+	if adr := registrant.VCard.GetFirst("adr"); adr != nil && len(adr.Parameters["cc"]) > 0 {
+		country = strings.ToUpper(adr.Parameters["cc"][0])
+	} else {
+		country = strings.TrimSpace(registrant.VCard.Country())
+	}
+
+	return organization, country
+}
+
+// getSecureDNS returns "signed" or "unsigned" as the delegation signed flag of the response, and the DS records.
+func getSecureDNS(secureDNS *rdap.SecureDNS) (string, []string) {
+	if secureDNS == nil {
+		return "", nil
+	}
+
+	var dsData []string
+	for _, ds := range secureDNS.DS {
+		if ds.KeyTag == nil || ds.Algorithm == nil || ds.DigestType == nil {
+			continue
 		}
+		dsData = append(dsData, fmt.Sprintf("%d %d %d %s", *ds.KeyTag, *ds.Algorithm, *ds.DigestType, strings.ToUpper(ds.Digest)))
 	}
-	return creationDate, expiryDate
+
+	switch {
+	case secureDNS.DelegationSigned != nil && *secureDNS.DelegationSigned, len(dsData) > 0:
+		return "signed", dsData
+	case secureDNS.DelegationSigned != nil:
+		return "unsigned", dsData
+	default:
+		return "", dsData
+	}
+}
+
+// getRedactedFields returns the names of the fields redacted from the response, which are listed by the RDAP redaction extension (RFC 9537).
+// The name of a field is its description, or its registered type if it has no description.
+func getRedactedFields(decodeData *rdap.DecodeData) []string {
+	if decodeData == nil {
+		return nil
+	}
+
+	redactions, ok := decodeData.Value("redacted").([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var fields []string
+	for _, redaction := range redactions {
+		redactionMap, ok := redaction.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, ok := redactionMap["name"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		field := convertor.ToString(name["description"])
+		if field == "" {
+			field = convertor.ToString(name["type"])
+		}
+		if field != "" && !slice.Contain(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
 }
 
 // getNameServer takes the response nameservers and returns the nameservers as a slice of strings.
@@ -1,11 +1,10 @@
 package rdaplib
 
 import (
-	"crypto/tls"
-	"fmt"
+	"context"
+	"errors"
 	"net"
-	"net/http"
-	"strconv"
+	"net/url"
 	"time"
 
 	"typonamer/config"
@@ -15,11 +14,7 @@ import (
 	"typonamer/lookup/lookupinfo"
 
 	"github.com/duke-git/lancet/v2/slice"
-	"github.com/duke-git/lancet/v2/strutil"
 	"github.com/openrdap/rdap"
-	"github.com/openrdap/rdap/bootstrap"
-	"github.com/openrdap/rdap/bootstrap/cache"
-	"golang.org/x/net/proxy"
 )
 
 const (
@@ -31,7 +26,8 @@ const (
 // RDAP is a protocol used to retrieve information about domain names and
 // Internet number resources. It is designed to be a replacement for the
 // WHOIS protocol, which is used to retrieve information about domain names.
-func RDAPQuery(domain string, tld string, useProxy bool) (lookupinfo.DomainInfo, error) {
+// The HTTP requests are aborted as soon as the context is canceled.
+func RDAPQuery(ctx context.Context, domain string, tld string, useProxy bool) (lookupinfo.DomainInfo, error) {
 	log.Debugf("Querying RDAP for domain: %s", domain)
 
 	var domainInfo = lookupinfo.DomainInfo{
@@ -41,96 +37,132 @@ func RDAPQuery(domain string, tld string, useProxy bool) (lookupinfo.DomainInfo,
 
 	cfg := config.GetConfig()
 
-	httpClient := &http.Client{
-		Timeout: time.Duration(cfg.WhoisTimeout) * time.Second,
-		Transport: &http.Transport{
-			DisableKeepAlives:   true,
-			TLSClientConfig:     &tls.Config{InsecureSkipVerify: true},
-			TLSHandshakeTimeout: time.Duration(cfg.WhoisTimeout) * time.Second,
-		},
+	// The HTTP client shares the pooled transport of the route, so the connections to the RDAP servers are reused
+	httpClient, proxyServer, err := getRdapHttpClient(useProxy)
+	domainInfo.Trace.Proxy = proxyServer
+	if err != nil {
+		log.Errorf("Failed to set up the RDAP transport (via proxy: %t): %s", useProxy, err)
+		return domainInfo, err
 	}
 
-	if useProxy {
-		// Set up the proxy server
-		proxyServer := net.JoinHostPort(cfg.SocketProxyHost, strconv.Itoa(cfg.SocketProxyPort))
-		if cfg.SocketProxyAuth {
-			proxyAuth := proxy.Auth{
-				User:     cfg.SocketProxyUser,
-				Password: cfg.SocketProxyPassword,
-			}
-			dialer, err := proxy.SOCKS5("tcp", proxyServer, &proxyAuth, proxy.Direct)
-			if err == nil {
-				httpClient.Transport = &http.Transport{Dial: dialer.Dial}
-			} else {
-				log.Warnf("Failed to create proxy dialer with auth: %s", err)
-				return domainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorConnectToProxy, err.Error())
-			}
-		} else {
-			dialer, err := proxy.SOCKS5("tcp", proxyServer, nil, proxy.Direct)
-			if err == nil {
-				httpClient.Transport = &http.Transport{Dial: dialer.Dial}
-			} else {
-				log.Warnf("Failed to create proxy dialer: %s", err)
-				return domainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorConnectToProxy, err.Error())
-			}
-		}
+	// The RDAP server is taken from the configuration or the local bootstrap file, instead of bootstrapping at query time
+	rdapBaseUrl, ok := getRdapServer(tld)
+	if !ok {
+		return domainInfo, lookuperror.Newf(lookuperror.ErrorNotSupportedTld, "", "%s", tld)
+	}
+	rdapServerUrl, err := url.Parse(rdapBaseUrl)
+	if err != nil {
+		return domainInfo, lookuperror.New(lookuperror.ErrorWhoisServerFailed, rdapBaseUrl, err)
+	}
+
+	// The rate limited RDAP server is not queried until its cool-down ends
+	if until, ok := rdapServerCoolingDown(rdapBaseUrl, useProxy); ok {
+		domainInfo.Trace.Server = rdapServerUrl.Host
+		lookupErr := lookuperror.Newf(lookuperror.ErrorRdapRateLimited, rdapServerUrl.Host, "cooling down until %s", until.Format(time.DateTime))
+		lookupErr.RetryAfter = time.Until(until)
+		return domainInfo, lookupErr
 	}
 
 	// Set up the RDAP client
 	rdapReq := &rdap.Request{
 		Type:    rdap.DomainRequest,
 		Query:   domain,
+		Server:  rdapServerUrl,
 		Timeout: time.Duration(cfg.WhoisTimeout) * time.Second,
 	}
+	rdapReq = rdapReq.WithContext(ctx)
 
 	rdapClient := &rdap.Client{
 		HTTP: httpClient,
-		Bootstrap: &bootstrap.Client{
-			Cache: cache.NewDiskCache(),
-		},
 	}
 
 	// Do the RDAP query
 	rdapResp, err := rdapClient.Do(rdapReq)
+	rdapServer := getRDAPServer(rdapResp)
+	domainInfo.Trace.Server = rdapServer
 	if err != nil {
 		log.Debugf("Failed to query RDAP for domain %s: %s", domain, err)
 
 		domainInfo.RawResponse = err.Error()
 
-		if strutil.ContainsAny(err.Error(), []string{"No RDAP servers responded successfully"}) {
-			return domainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorWhoisTimeout, err.Error())
-		} else if strutil.ContainsAny(err.Error(), []string{"No RDAP servers found for"}) {
-			return domainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorNotSupportedTld, tld)
-		} else if strutil.ContainsAny(err.Error(), []string{"RDAP server returned 404"}) {
-
-			return domainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorWhoisNotFound, domain)
-		} else if strutil.ContainsAny(err.Error(), []string{"Server returned error code"}) {
-			return domainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorWhoisServerFailed, err.Error())
-		} else {
-			log.Errorf("Failed to query RDAP for domain %s: %s", domain, err)
-			return domainInfo, err
+		if ctx.Err() != nil {
+			return domainInfo, lookuperror.New(lookuperror.ErrorLookupCanceled, rdapServer, ctx.Err())
 		}
+
+		// The error responses are classified by their status code, the client only tells they are not successful
+		if httpErr, ok := parseRdapHttpError(rdapResp); ok {
+			log.Debugf("RDAP server %s returned an error response for domain %s: %s", rdapServer, domain, httpErr)
+			domainInfo.RawResponse = httpErr.Error()
+			return domainInfo, classifyRdapHttpError(httpErr, rdapBaseUrl, rdapServer, useProxy)
+		}
+
+		return domainInfo, classifyRDAPError(err, rdapServer, domain, tld)
 	}
 
 	// Parse the RDAP response
 	if domainResult, ok := rdapResp.Object.(*rdap.Domain); ok {
 		log.Debugf("RDAP response of domain %s is: \n%+v", domain, domainResult)
 
+		trace := domainInfo.Trace
 		domainInfo := ParseRDAPResponseforDomain(domainResult)
 		domainInfo.RawResponse = getWhoisStyleRawResponse(rdapResp)
 		domainInfo.ViaProxy = useProxy
+		domainInfo.Trace = trace
+
+		// The registry status is the result, even if the response has no registration data
+		if domainInfo.RegistryStatus != "" {
+			return domainInfo, nil
+		}
 
 		if slice.Contain(domainInfo.DomainStatus, domainFreeStatus) && len(domainInfo.NameServer) == 0 {
-			return domainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorWhoisNotFound, domain)
+			return domainInfo, lookuperror.Newf(lookuperror.ErrorWhoisNotFound, rdapServer, "%s", domain)
 		}
 
 		if domainInfo.Registrar == "" && domainInfo.CreationDate == "" && domainInfo.ExpiryDate == "" && len(domainInfo.NameServer) == 0 {
-			return domainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorWhoisNotFound, domain)
+			return domainInfo, lookuperror.Newf(lookuperror.ErrorWhoisNotFound, rdapServer, "%s", domain)
 		}
 
 		return domainInfo, nil
 	} else {
 		log.Error("RDAP server returned unexpected response for domain: ", domain)
-		return domainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorWhoisServerFailed, "RDAP server returned unexpected response")
+		return domainInfo, lookuperror.Newf(lookuperror.ErrorWhoisServerFailed, rdapServer, "RDAP server returned unexpected response")
+	}
+}
+
+// classifyRDAPError converts the error returned by the RDAP client to a LookupError.
+func classifyRDAPError(err error, rdapServer string, domain string, tld string) error {
+	var clientErr *rdap.ClientError
+	if errors.As(err, &clientErr) {
+		switch clientErr.Type {
+		case rdap.NoWorkingServers:
+			return lookuperror.New(lookuperror.ErrorWhoisTimeout, rdapServer, err)
+		case rdap.BootstrapNoMatch, rdap.BootstrapNotSupported:
+			return lookuperror.Newf(lookuperror.ErrorNotSupportedTld, rdapServer, "%s", tld)
+		case rdap.ObjectDoesNotExist:
+			return lookuperror.Newf(lookuperror.ErrorWhoisNotFound, rdapServer, "%s", domain)
+		case rdap.RDAPServerError, rdap.WrongResponseType:
+			return lookuperror.New(lookuperror.ErrorWhoisServerFailed, rdapServer, err)
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return lookuperror.New(lookuperror.ErrorWhoisTimeout, rdapServer, err)
+	}
+
+	log.Errorf("Failed to query RDAP for domain %s: %s", domain, err)
+	return lookuperror.New(lookuperror.ErrorWhoisServerFailed, rdapServer, err)
+}
+
+// getRDAPServer returns the host of the last RDAP server queried, or an empty string if no server is queried.
+func getRDAPServer(rdapResp *rdap.Response) string {
+	if rdapResp == nil || len(rdapResp.HTTP) == 0 {
+		return ""
+	}
+
+	serverUrl, err := url.Parse(rdapResp.HTTP[len(rdapResp.HTTP)-1].URL)
+	if err != nil {
+		return ""
 	}
+	return serverUrl.Host
 }
@@ -0,0 +1,191 @@
+package rdaplib
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"typonamer/config"
+	"typonamer/log"
+	"typonamer/lookup/lookuperror"
+
+	"github.com/duke-git/lancet/v2/strutil"
+	"github.com/openrdap/rdap"
+)
+
+const (
+	// defaultRdapRateLimitCoolDown is the time to pause the queries to a rate limited RDAP server if it gives no Retry-After and none is configured.
+	defaultRdapRateLimitCoolDown = time.Minute
+
+	// rdapMaxRetryAfter caps the Retry-After of the RDAP servers, so a wrong header does not pause a server for days.
+	rdapMaxRetryAfter = time.Hour
+)
+
+var (
+	// rdapCoolDowns holds the end of the cool-down of the rate limited RDAP base URLs.
+	// It is shared by the web, typo and bulk checks, as they all query the servers from this process.
+	rdapCoolDowns    = make(map[string]time.Time)
+	rdapCoolDownsMux sync.Mutex
+)
+
+// rdapHttpError is an error response of an RDAP server, with the error object of RFC 9083 if the server returned one.
+type rdapHttpError struct {
+	StatusCode  int           // StatusCode is the HTTP status code of the response.
+	RetryAfter  time.Duration // RetryAfter is the wait given in the Retry-After header, zero if there is none.
+	ErrorCode   int           // ErrorCode is the errorCode of the error object, zero if there is none.
+	Title       string        // Title is the title of the error object.
+	Description []string      // Description is the description of the error object.
+}
+
+func (e *rdapHttpError) Error() string {
+	message := fmt.Sprintf("RDAP server returned HTTP %d", e.StatusCode)
+	if e.Title != "" {
+		message = fmt.Sprintf("%s: %s", message, e.Title)
+	}
+	if len(e.Description) > 0 {
+		message = fmt.Sprintf("%s (%s)", message, strings.Join(e.Description, " "))
+	}
+	if e.RetryAfter > 0 {
+		message = fmt.Sprintf("%s, retry after %s", message, e.RetryAfter)
+	}
+	return message
+}
+
+// parseRdapHttpError returns the error response of the last RDAP server queried, ok is false if it answered with no error status.
+// The 404 responses are left to the RDAP client, as they mean the domain is not found.
+func parseRdapHttpError(rdapResp *rdap.Response) (*rdapHttpError, bool) {
+	if rdapResp == nil || len(rdapResp.HTTP) == 0 {
+		return nil, false
+	}
+
+	httpResp := rdapResp.HTTP[len(rdapResp.HTTP)-1]
+	if httpResp.Response == nil {
+		return nil, false
+	}
+	statusCode := httpResp.Response.StatusCode
+	if statusCode < http.StatusBadRequest || statusCode == http.StatusNotFound {
+		return nil, false
+	}
+
+	httpErr := &rdapHttpError{
+		StatusCode: statusCode,
+		RetryAfter: parseRetryAfter(httpResp.Response.Header.Get("Retry-After"), time.Now()),
+	}
+
+	// The error object is optional, a body which is not one is ignored
+	var errorObject struct {
+		ErrorCode   int      `json:"errorCode"`
+		Title       string   `json:"title"`
+		Description []string `json:"description"`
+	}
+	if err := json.Unmarshal(httpResp.Body, &errorObject); err == nil {
+		httpErr.ErrorCode = errorObject.ErrorCode
+		httpErr.Title = strutil.Trim(errorObject.Title)
+		httpErr.Description = errorObject.Description
+	}
+
+	return httpErr, true
+}
+
+// classifyRdapHttpError converts the error response of the RDAP server to a LookupError.
+// The base URL is paused after a 429 response, or after a 503 response with a Retry-After.
+func classifyRdapHttpError(httpErr *rdapHttpError, rdapBaseUrl string, rdapServer string, useProxy bool) error {
+	switch {
+	case httpErr.StatusCode == http.StatusTooManyRequests:
+		until := coolDownRdapServer(rdapBaseUrl, useProxy, httpErr.RetryAfter)
+		lookupErr := lookuperror.New(lookuperror.ErrorRdapRateLimited, rdapServer, httpErr)
+		lookupErr.RetryAfter = time.Until(until)
+		return lookupErr
+	case httpErr.StatusCode == http.StatusServiceUnavailable && httpErr.RetryAfter > 0:
+		until := coolDownRdapServer(rdapBaseUrl, useProxy, httpErr.RetryAfter)
+		lookupErr := lookuperror.New(lookuperror.ErrorWhoisServerFailed, rdapServer, httpErr)
+		lookupErr.RetryAfter = time.Until(until)
+		return lookupErr
+	default:
+		return lookuperror.New(lookuperror.ErrorWhoisServerFailed, rdapServer, httpErr)
+	}
+}
+
+// parseRetryAfter returns the wait of the Retry-After header, given in seconds or as an HTTP date, zero if it is missing or invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strutil.Trim(value)
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return min(time.Duration(seconds)*time.Second, rdapMaxRetryAfter)
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		wait := date.Sub(now)
+		if wait <= 0 {
+			return 0
+		}
+		return min(wait, rdapMaxRetryAfter)
+	}
+
+	return 0
+}
+
+// coolDownRdapServer pauses the queries to the RDAP base URL after it rate limited a query, for the Retry-After of the
+// server if it gave one, or else for the configured cool-down.
+// The queries via proxy come from another address, so they are paused apart from the direct queries.
+func coolDownRdapServer(rdapBaseUrl string, useProxy bool, retryAfter time.Duration) time.Time {
+	coolDown := retryAfter
+	if coolDown <= 0 {
+		coolDown = time.Duration(config.GetConfig().WhoisRateLimitCoolDown) * time.Second
+	}
+	if coolDown <= 0 {
+		coolDown = defaultRdapRateLimitCoolDown
+	}
+
+	until := time.Now().Add(coolDown)
+
+	rdapCoolDownsMux.Lock()
+	key := rdapCoolDownKey(rdapBaseUrl, useProxy)
+	// A later response must not shorten the pause asked for by an earlier one
+	if current, ok := rdapCoolDowns[key]; ok && current.After(until) {
+		until = current
+	}
+	rdapCoolDowns[key] = until
+	rdapCoolDownsMux.Unlock()
+
+	log.Warnf("RDAP server %s rate limited the query (via proxy: %t), pausing the queries to it until %s", rdapBaseUrl, useProxy, until.Format(time.DateTime))
+
+	return until
+}
+
+// rdapServerCoolingDown returns the end of the cool-down of the RDAP base URL, ok is false if the server is not cooling down.
+func rdapServerCoolingDown(rdapBaseUrl string, useProxy bool) (time.Time, bool) {
+	key := rdapCoolDownKey(rdapBaseUrl, useProxy)
+
+	rdapCoolDownsMux.Lock()
+	defer rdapCoolDownsMux.Unlock()
+
+	until, ok := rdapCoolDowns[key]
+	if !ok {
+		return time.Time{}, false
+	}
+	if time.Now().After(until) {
+		delete(rdapCoolDowns, key)
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+// rdapCoolDownKey returns the key of the cool-down of the RDAP base URL, the TLDs sharing a base URL share its cool-down.
+func rdapCoolDownKey(rdapBaseUrl string, useProxy bool) string {
+	key := strings.TrimRight(strings.ToLower(rdapBaseUrl), "/")
+	if useProxy {
+		return key + "|proxy"
+	}
+	return key
+}
@@ -0,0 +1,37 @@
+package rdaplib
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{"missing", "", 0},
+		{"delta seconds", "120", 2 * time.Minute},
+		{"delta seconds with spaces", " 30 ", 30 * time.Second},
+		{"zero delta seconds", "0", 0},
+		{"negative delta seconds", "-5", 0},
+		{"delta seconds over the maximum", "86400", rdapMaxRetryAfter},
+		{"http date", now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{"http date in the past", now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"http date over the maximum", now.Add(48 * time.Hour).Format(http.TimeFormat), rdapMaxRetryAfter},
+		{"invalid", "soon", 0},
+		{"fractional seconds", "1.5", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRetryAfter(tt.value, now); got != tt.want {
+				t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
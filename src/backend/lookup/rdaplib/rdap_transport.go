@@ -0,0 +1,190 @@
+package rdaplib
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"typonamer/config"
+	"typonamer/log"
+	"typonamer/lookup/lookuperror"
+
+	"golang.org/x/net/proxy"
+)
+
+const (
+	// rdapMaxIdleConnsPerHost is the number of idle connections kept to each RDAP server, enough for the concurrent bulk checks.
+	rdapMaxIdleConnsPerHost = 32
+
+	// rdapIdleConnTimeout is how long an idle connection to an RDAP server is kept.
+	rdapIdleConnTimeout = 90 * time.Second
+
+	// rdapDialKeepAlive is the interval of the TCP keep-alive probes of the connections to the RDAP servers.
+	rdapDialKeepAlive = 30 * time.Second
+)
+
+// rdapRoute is the route and the settings the RDAP requests are sent with.
+// The transport of a route is rebuilt when its settings are changed in the configuration.
+type rdapRoute struct {
+	proxyServer   string        // proxyServer is the address of the SOCKS5 proxy, empty for the direct route.
+	proxyUser     string        // proxyUser is the user of the proxy, empty if the proxy needs no authentication.
+	proxyPassword string        // proxyPassword is the password of the proxy.
+	timeout       time.Duration // timeout is the timeout of dialing, the TLS handshake and waiting for the response headers.
+	skipVerify    bool          // skipVerify is the flag to skip the verification of the server certificates.
+	caFile        string        // caFile is the file of the CA certificates trusted besides the system ones.
+}
+
+// rdapTransport is the pooled transport of a route.
+type rdapTransport struct {
+	route     rdapRoute
+	transport *http.Transport
+}
+
+var (
+	// rdapTransports holds the transport of the direct route and the one of the proxy route, shared by all RDAP queries.
+	rdapTransports    = make(map[bool]*rdapTransport)
+	rdapTransportsMux sync.Mutex
+)
+
+// getRdapHttpClient returns an HTTP client using the shared transport of the route, and the address of the proxy if it is used.
+// The error is a LookupError, as the transport fails to be built for a wrong proxy or TLS setting.
+func getRdapHttpClient(useProxy bool) (*http.Client, string, error) {
+	route := currentRdapRoute(useProxy)
+
+	transport, err := getRdapTransport(useProxy, route)
+	if err != nil {
+		return nil, route.proxyServer, err
+	}
+
+	return &http.Client{Transport: transport, Timeout: route.timeout}, route.proxyServer, nil
+}
+
+// currentRdapRoute returns the route of the RDAP requests from the configuration.
+func currentRdapRoute(useProxy bool) rdapRoute {
+	cfg := config.GetConfig()
+
+	route := rdapRoute{
+		timeout:    time.Duration(cfg.WhoisTimeout) * time.Second,
+		skipVerify: cfg.RdapTlsSkipVerify,
+		caFile:     cfg.RdapTlsCaFile,
+	}
+	if useProxy {
+		route.proxyServer = net.JoinHostPort(cfg.SocketProxyHost, strconv.Itoa(cfg.SocketProxyPort))
+		if cfg.SocketProxyAuth {
+			route.proxyUser = cfg.SocketProxyUser
+			route.proxyPassword = cfg.SocketProxyPassword
+		}
+	}
+
+	return route
+}
+
+// getRdapTransport returns the shared transport of the route, a new one is built if the settings of the route are changed.
+func getRdapTransport(useProxy bool, route rdapRoute) (*http.Transport, error) {
+	rdapTransportsMux.Lock()
+	defer rdapTransportsMux.Unlock()
+
+	current, ok := rdapTransports[useProxy]
+	if ok && current.route == route {
+		return current.transport, nil
+	}
+
+	transport, err := newRdapTransport(route)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok {
+		// The requests in flight keep their connections, only the idle ones of the old settings are closed
+		current.transport.CloseIdleConnections()
+		log.Infof("RDAP transport settings changed (via proxy: %t), rebuilding the transport", useProxy)
+	}
+	rdapTransports[useProxy] = &rdapTransport{route: route, transport: transport}
+
+	return transport, nil
+}
+
+// newRdapTransport builds a pooled transport for the route, with the timeouts applied to the direct and proxy connections alike.
+func newRdapTransport(route rdapRoute) (*http.Transport, error) {
+	// The CA file is validated when the configuration is loaded, it fails here only if it is changed on disk afterwards,
+	// which retrying the query does not fix
+	tlsConfig, err := newRdapTlsConfig(route)
+	if err != nil {
+		return nil, lookuperror.New(lookuperror.ErrorInvalidConfig, "", err)
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   route.timeout,
+		KeepAlive: rdapDialKeepAlive,
+	}
+
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   route.timeout,
+		ResponseHeaderTimeout: route.timeout,
+		IdleConnTimeout:       rdapIdleConnTimeout,
+		MaxIdleConns:          rdapMaxIdleConnsPerHost * 4,
+		MaxIdleConnsPerHost:   rdapMaxIdleConnsPerHost,
+		ForceAttemptHTTP2:     true,
+	}
+
+	if route.proxyServer != "" {
+		var proxyAuth *proxy.Auth
+		if route.proxyUser != "" {
+			proxyAuth = &proxy.Auth{
+				User:     route.proxyUser,
+				Password: route.proxyPassword,
+			}
+		}
+
+		// The dialer connecting to the proxy keeps the dial timeout
+		proxyDialer, err := proxy.SOCKS5("tcp", route.proxyServer, proxyAuth, dialer)
+		if err != nil {
+			return nil, lookuperror.New(lookuperror.ErrorConnectToProxy, route.proxyServer, err)
+		}
+		contextDialer, ok := proxyDialer.(proxy.ContextDialer)
+		if !ok {
+			return nil, lookuperror.New(lookuperror.ErrorConnectToProxy, route.proxyServer, errors.New("proxy dialer does not support context"))
+		}
+		transport.DialContext = contextDialer.DialContext
+	}
+
+	return transport, nil
+}
+
+// newRdapTlsConfig returns the TLS config of the route, trusting the CA certificates of the CA file besides the system ones.
+func newRdapTlsConfig(route rdapRoute) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: route.skipVerify,
+	}
+
+	if route.caFile == "" {
+		return tlsConfig, nil
+	}
+
+	caCerts, err := os.ReadFile(route.caFile)
+	if err != nil {
+		return nil, fmt.Errorf("read RDAP CA file: %w", err)
+	}
+
+	rootCAs, err := x509.SystemCertPool()
+	if err != nil {
+		log.Warnf("Failed to load the system CA certificates, trusting the RDAP CA file only: %s", err)
+		rootCAs = x509.NewCertPool()
+	}
+	if !rootCAs.AppendCertsFromPEM(caCerts) {
+		return nil, fmt.Errorf("no PEM certificate found in RDAP CA file %s", route.caFile)
+	}
+	tlsConfig.RootCAs = rootCAs
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,18 @@
+package rdaplib
+
+import (
+	"path/filepath"
+	"testing"
+
+	"typonamer/lookup/lookuperror"
+)
+
+func TestNewRdapTransportInvalidCaFile(t *testing.T) {
+	_, err := newRdapTransport(rdapRoute{caFile: filepath.Join(t.TempDir(), "missing.pem")})
+	if kind := lookuperror.KindOf(err); kind != lookuperror.KindConfig {
+		t.Errorf("newRdapTransport() error kind = %s, want %s", kind, lookuperror.KindConfig)
+	}
+	if lookuperror.IsRetryable(err) {
+		t.Errorf("newRdapTransport() error = %s is retryable for a missing CA file", err)
+	}
+}
@@ -0,0 +1,44 @@
+package whoislib
+
+import (
+	"context"
+
+	"typonamer/constant"
+	"typonamer/lookup/lookupbackend"
+	"typonamer/lookup/lookuperror"
+	"typonamer/lookup/lookupinfo"
+	"typonamer/utils"
+)
+
+// whoisBackend is the lookup backend that queries the port 43 WHOIS servers.
+type whoisBackend struct{}
+
+func init() {
+	lookupbackend.Register(whoisBackend{})
+}
+
+// Name returns the name of the WHOIS backend.
+func (whoisBackend) Name() string {
+	return constant.LookupTypeWhois
+}
+
+// Supports reports whether the WHOIS server for the TLD is known or may be discovered from IANA.
+// It only checks the configured servers and the discoveries kept in memory, the TLDs not discovered yet
+// are discovered by WhoisQuery with the context of the lookup.
+func (whoisBackend) Supports(tld string) bool {
+	if _, ok := getWhoisServers(tld); ok {
+		return true
+	}
+
+	return !HasNoWhoisServer(tld)
+}
+
+// Lookup queries the WHOIS information of the domain.
+func (whoisBackend) Lookup(ctx context.Context, domain string) (lookupinfo.DomainInfo, error) {
+	tld, _, err := utils.GetTld(domain)
+	if err != nil {
+		return lookupinfo.DomainInfo{DomainName: domain, LookupType: constant.LookupTypeWhois}, lookuperror.New(lookuperror.ErrorInvalidDomainName, "", err)
+	}
+
+	return WhoisQuery(ctx, domain, tld, lookupbackend.UseProxy(ctx))
+}
@@ -0,0 +1,309 @@
+package whoislib
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"typonamer/config"
+	"typonamer/constant"
+	"typonamer/database"
+	"typonamer/log"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// defaultIanaWhoisServer is the IANA WHOIS server used if none is configured.
+	defaultIanaWhoisServer = "whois.iana.org"
+
+	// defaultIanaDiscoveryTtl is the TTL of the discovered WHOIS servers used if none is configured.
+	defaultIanaDiscoveryTtl = 7 * 24 * time.Hour
+
+	// noWhoisServer is saved for the TLDs without WHOIS server, so IANA is not queried again until it expires.
+	noWhoisServer = "-"
+
+	// discoveryFailureTtl is how long a failed discovery is remembered, the lookups of the TLD fail at once until it expires
+	// instead of waiting for IANA again.
+	discoveryFailureTtl = time.Minute
+)
+
+// DiscoveryStore persists the WHOIS servers discovered from IANA.
+type DiscoveryStore interface {
+	// Get returns the discovered WHOIS server of the TLD, found is false if the TLD is not discovered yet.
+	Get(ctx context.Context, tld string) (server string, found bool, err error)
+
+	// Set saves the discovered WHOIS server of the TLD for the TTL.
+	Set(ctx context.Context, tld string, server string, ttl time.Duration) error
+}
+
+var (
+	discoveryStore    DiscoveryStore = redisDiscoveryStore{}
+	discoveryStoreMux sync.RWMutex
+
+	// discoveryLocks holds a mutex per TLD, so IANA is queried only once for concurrent lookups of the same TLD.
+	discoveryLocks sync.Map
+
+	// discoveryFailures holds the recently failed discoveries by TLD.
+	discoveryFailures sync.Map
+
+	// discoveredServers holds the discovered WHOIS servers by TLD in memory, so Supports is answered without reading the store.
+	discoveredServers sync.Map
+)
+
+// discoveryFailure is a failed discovery of the WHOIS server of a TLD.
+type discoveryFailure struct {
+	err      error
+	expireAt time.Time
+}
+
+// discoveredServer is the discovered WHOIS server of a TLD, empty if IANA knows no WHOIS server for it.
+type discoveredServer struct {
+	server   string
+	expireAt time.Time
+}
+
+// SetDiscoveryStore replaces the store of the discovered WHOIS servers, the servers are saved in Redis by default.
+func SetDiscoveryStore(store DiscoveryStore) {
+	discoveryStoreMux.Lock()
+	defer discoveryStoreMux.Unlock()
+	discoveryStore = store
+}
+
+func getDiscoveryStore() DiscoveryStore {
+	discoveryStoreMux.RLock()
+	defer discoveryStoreMux.RUnlock()
+	return discoveryStore
+}
+
+// DiscoverWhoisServer returns the WHOIS server of the TLD discovered from the IANA WHOIS server,
+// or an empty string if IANA knows no WHOIS server for the TLD.
+// The discovered server is saved in the discovery store, IANA is only queried if the TLD is not in the store.
+// A failed discovery is remembered for a short time, and its error is returned without querying IANA again.
+// It is called by WhoisQuery only, the other callers read the result kept in memory with HasNoWhoisServer.
+func DiscoverWhoisServer(ctx context.Context, tld string) (string, error) {
+	store := getDiscoveryStore()
+
+	server, found, err := store.Get(ctx, tld)
+	if err != nil {
+		log.Warnf("Failed to get the discovered whois server of TLD %s: %s", tld, err)
+	} else if found {
+		rememberDiscoveredServer(tld, storedServer(server))
+		return storedServer(server), nil
+	}
+	if failureErr, failed := getDiscoveryFailure(tld); failed {
+		return "", failureErr
+	}
+
+	lock, _ := discoveryLocks.LoadOrStore(tld, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	// The server may be discovered by another lookup while waiting for the lock, or its discovery may have failed
+	server, found, err = store.Get(ctx, tld)
+	if err == nil && found {
+		rememberDiscoveredServer(tld, storedServer(server))
+		return storedServer(server), nil
+	}
+	if failureErr, failed := getDiscoveryFailure(tld); failed {
+		return "", failureErr
+	}
+
+	ianaServer := config.GetConfig().IanaWhoisServer
+	if ianaServer == "" {
+		ianaServer = defaultIanaWhoisServer
+	}
+
+	log.Infof("Discovering the whois server of TLD %s from %s", tld, ianaServer)
+
+	response, _, err := queryWhoisServer(ctx, ianaServer, fmt.Sprintf("%s\r\n", tld), false)
+	if err != nil {
+		log.Warnf("Failed to discover the whois server of TLD %s from %s: %s", tld, ianaServer, err)
+		// A canceled lookup says nothing about IANA, so it is not remembered
+		if ctx.Err() == nil {
+			discoveryFailures.Store(tld, discoveryFailure{err: err, expireAt: time.Now().Add(discoveryFailureTtl)})
+		}
+		return "", err
+	}
+	discoveryFailures.Delete(tld)
+
+	server = ParseIanaResponse(response)
+	if server == "" {
+		log.Infof("No whois server found for TLD %s from %s", tld, ianaServer)
+	} else {
+		log.Infof("Discovered whois server %s for TLD %s from %s", server, tld, ianaServer)
+	}
+
+	rememberDiscoveredServer(tld, server)
+
+	saveServer := server
+	if saveServer == "" {
+		saveServer = noWhoisServer
+	}
+	err = store.Set(ctx, tld, saveServer, getIanaDiscoveryTtl())
+	if err != nil {
+		log.Warnf("Failed to save the discovered whois server of TLD %s: %s", tld, err)
+	}
+
+	return server, nil
+}
+
+// HasNoWhoisServer reports whether the TLD is known to have no WHOIS server at IANA, or its discovery failed recently.
+// It only reads the discoveries of this process kept in memory, neither the discovery store nor IANA is queried,
+// so a TLD not discovered by this process yet is not known to have no WHOIS server.
+func HasNoWhoisServer(tld string) bool {
+	if _, failed := getDiscoveryFailure(tld); failed {
+		return true
+	}
+
+	value, ok := discoveredServers.Load(tld)
+	if !ok {
+		return false
+	}
+	discovered := value.(discoveredServer)
+	if time.Now().After(discovered.expireAt) {
+		discoveredServers.Delete(tld)
+		return false
+	}
+	return discovered.server == ""
+}
+
+// rememberDiscoveredServer keeps the discovered WHOIS server of the TLD in memory for the discovery TTL.
+func rememberDiscoveredServer(tld string, server string) {
+	discoveredServers.Store(tld, discoveredServer{server: server, expireAt: time.Now().Add(getIanaDiscoveryTtl())})
+}
+
+// getIanaDiscoveryTtl returns the configured TTL of the discovered WHOIS servers, or the default one if none is configured.
+func getIanaDiscoveryTtl() time.Duration {
+	ttl := time.Duration(config.GetConfig().IanaDiscoveryTtl) * time.Second
+	if ttl <= 0 {
+		return defaultIanaDiscoveryTtl
+	}
+	return ttl
+}
+
+// getDiscoveryFailure returns the error of the discovery of the TLD if it failed recently.
+func getDiscoveryFailure(tld string) (error, bool) {
+	value, ok := discoveryFailures.Load(tld)
+	if !ok {
+		return nil, false
+	}
+
+	failure := value.(discoveryFailure)
+	if time.Now().After(failure.expireAt) {
+		discoveryFailures.Delete(tld)
+		return nil, false
+	}
+	return failure.err, true
+}
+
+// ParseIanaResponse returns the WHOIS server in the response of an IANA-style WHOIS server.
+// The server of the "refer:" line is preferred over the one of the "whois:" line.
+func ParseIanaResponse(response string) string {
+	var referServer, whoisServer string
+
+	scanner := bufio.NewScanner(strings.NewReader(response))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "refer":
+			if referServer == "" {
+				referServer = strings.TrimSpace(value)
+			}
+		case "whois":
+			if whoisServer == "" {
+				whoisServer = strings.TrimSpace(value)
+			}
+		}
+	}
+
+	if referServer != "" {
+		return referServer
+	}
+	return whoisServer
+}
+
+// storedServer converts the server saved in the discovery store back to the WHOIS server.
+func storedServer(server string) string {
+	if server == noWhoisServer {
+		return ""
+	}
+	return server
+}
+
+// redisDiscoveryStore saves the discovered WHOIS servers in Redis.
+type redisDiscoveryStore struct{}
+
+func (redisDiscoveryStore) Get(ctx context.Context, tld string) (string, bool, error) {
+	rdb, err := database.GetSharedRedis()
+	if err != nil {
+		return "", false, err
+	}
+
+	server, err := rdb.Get(ctx, discoveryRedisKey(tld)).Result()
+	if err == redis.Nil {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return server, true, nil
+}
+
+func (redisDiscoveryStore) Set(ctx context.Context, tld string, server string, ttl time.Duration) error {
+	rdb, err := database.GetSharedRedis()
+	if err != nil {
+		return err
+	}
+
+	return rdb.Set(ctx, discoveryRedisKey(tld), server, ttl).Err()
+}
+
+// discoveryRedisKey returns the redis key of the discovered WHOIS server of the TLD.
+func discoveryRedisKey(tld string) string {
+	return fmt.Sprintf("%s:%s", constant.WhoisServerDiscoveryRedisKeyPrefix, tld)
+}
+
+// MemoryDiscoveryStore saves the discovered WHOIS servers in memory, it is used when Redis is not available, such as in tests.
+type MemoryDiscoveryStore struct {
+	servers map[string]memoryDiscoveredServer
+	mux     sync.RWMutex
+}
+
+type memoryDiscoveredServer struct {
+	server   string
+	expireAt time.Time
+}
+
+// NewMemoryDiscoveryStore creates an empty MemoryDiscoveryStore.
+func NewMemoryDiscoveryStore() *MemoryDiscoveryStore {
+	return &MemoryDiscoveryStore{
+		servers: make(map[string]memoryDiscoveredServer),
+	}
+}
+
+func (s *MemoryDiscoveryStore) Get(ctx context.Context, tld string) (string, bool, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	discovered, ok := s.servers[tld]
+	if !ok || time.Now().After(discovered.expireAt) {
+		return "", false, nil
+	}
+	return discovered.server, true, nil
+}
+
+func (s *MemoryDiscoveryStore) Set(ctx context.Context, tld string, server string, ttl time.Duration) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.servers[tld] = memoryDiscoveredServer{server: server, expireAt: time.Now().Add(ttl)}
+	return nil
+}
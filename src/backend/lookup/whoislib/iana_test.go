@@ -0,0 +1,263 @@
+package whoislib
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"typonamer/config"
+	"typonamer/lookup/lookuperror"
+	"typonamer/lookup/whoislib/whoistest"
+)
+
+// setTestConfig puts the configuration changed by update in use for the test, with a timeout of 2 seconds unless update changes it.
+func setTestConfig(t *testing.T, update func(cfg *config.Config)) {
+	t.Helper()
+
+	cfg := config.GetConfig()
+	cfg.WhoisTimeout = 2
+	update(&cfg)
+	config.SetForTest(t, cfg)
+}
+
+// setTestDiscoveryStore replaces the discovery store with an empty memory store for the test,
+// and forgets the discoveries kept in memory after it.
+func setTestDiscoveryStore(t *testing.T) *MemoryDiscoveryStore {
+	t.Helper()
+
+	store := NewMemoryDiscoveryStore()
+	SetDiscoveryStore(store)
+	t.Cleanup(func() {
+		SetDiscoveryStore(redisDiscoveryStore{})
+		discoveredServers.Clear()
+	})
+	return store
+}
+
+// countingDiscoveryStore counts the reads of the discovery store.
+type countingDiscoveryStore struct {
+	*MemoryDiscoveryStore
+	gets atomic.Int32
+}
+
+func (s *countingDiscoveryStore) Get(ctx context.Context, tld string) (string, bool, error) {
+	s.gets.Add(1)
+	return s.MemoryDiscoveryStore.Get(ctx, tld)
+}
+
+// startIanaServer starts a fake IANA WHOIS server with the referrals, and makes it the configured IANA server.
+func startIanaServer(t *testing.T, referrals map[string]string) *whoistest.Server {
+	t.Helper()
+
+	iana, err := whoistest.NewIanaServer(referrals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { iana.Close() })
+
+	setTestConfig(t, func(cfg *config.Config) {
+		cfg.IanaWhoisServer = iana.Addr()
+	})
+	return iana
+}
+
+func TestDiscoverWhoisServerHit(t *testing.T) {
+	store := setTestDiscoveryStore(t)
+	iana := startIanaServer(t, map[string]string{"hit": "whois.nic.hit"})
+
+	for i := 0; i < 2; i++ {
+		server, err := DiscoverWhoisServer(context.Background(), "hit")
+		if err != nil {
+			t.Fatalf("DiscoverWhoisServer() error = %s", err)
+		}
+		if server != "whois.nic.hit" {
+			t.Errorf("DiscoverWhoisServer() = %q, want whois.nic.hit", server)
+		}
+	}
+
+	// The second discovery is answered by the store
+	if queries := iana.Queries(); len(queries) != 1 {
+		t.Errorf("IANA queried %d times, want 1: %v", len(queries), queries)
+	}
+	if stored, found, _ := store.Get(context.Background(), "hit"); !found || stored != "whois.nic.hit" {
+		t.Errorf("stored server = %q (found %t), want whois.nic.hit", stored, found)
+	}
+	if !(whoisBackend{}).Supports("hit") {
+		t.Error("Supports() = false for a discovered TLD")
+	}
+}
+
+func TestDiscoverWhoisServerMiss(t *testing.T) {
+	store := setTestDiscoveryStore(t)
+	iana := startIanaServer(t, nil)
+
+	if !(whoisBackend{}).Supports("miss") {
+		t.Error("Supports() = false for a TLD not discovered yet")
+	}
+
+	for i := 0; i < 2; i++ {
+		server, err := DiscoverWhoisServer(context.Background(), "miss")
+		if err != nil {
+			t.Fatalf("DiscoverWhoisServer() error = %s", err)
+		}
+		if server != "" {
+			t.Errorf("DiscoverWhoisServer() = %q, want no server", server)
+		}
+	}
+
+	if queries := iana.Queries(); len(queries) != 1 {
+		t.Errorf("IANA queried %d times, want 1: %v", len(queries), queries)
+	}
+	if stored, found, _ := store.Get(context.Background(), "miss"); !found || stored != noWhoisServer {
+		t.Errorf("stored server = %q (found %t), want %q", stored, found, noWhoisServer)
+	}
+	if (whoisBackend{}).Supports("miss") {
+		t.Error("Supports() = true for a TLD without WHOIS server")
+	}
+
+	_, err := WhoisQuery(context.Background(), "example.miss", "miss", false)
+	if !errors.Is(err, lookuperror.ErrorNotSupportedTld) {
+		t.Errorf("WhoisQuery() error = %v, want %s", err, lookuperror.ErrorNotSupportedTld)
+	}
+}
+
+func TestSupportsReadsMemoryOnly(t *testing.T) {
+	setTestDiscoveryStore(t)
+	store := &countingDiscoveryStore{MemoryDiscoveryStore: NewMemoryDiscoveryStore()}
+	SetDiscoveryStore(store)
+	startIanaServer(t, nil)
+
+	// Another process saved the TLD without WHOIS server, this process has not discovered it yet
+	store.Set(context.Background(), "elsewhere", noWhoisServer, time.Hour)
+	if !(whoisBackend{}).Supports("elsewhere") {
+		t.Error("Supports() = false for a TLD not discovered by this process yet")
+	}
+	if gets := store.gets.Load(); gets != 0 {
+		t.Errorf("Supports() read the discovery store %d times, want none", gets)
+	}
+
+	// The lookup discovers the TLD from the store, the result is then kept in memory
+	_, err := WhoisQuery(context.Background(), "example.elsewhere", "elsewhere", false)
+	if !errors.Is(err, lookuperror.ErrorNotSupportedTld) {
+		t.Errorf("WhoisQuery() error = %v, want %s", err, lookuperror.ErrorNotSupportedTld)
+	}
+	gets := store.gets.Load()
+	if (whoisBackend{}).Supports("elsewhere") {
+		t.Error("Supports() = true for a TLD discovered without WHOIS server")
+	}
+	if store.gets.Load() != gets {
+		t.Error("Supports() read the discovery store after the discovery")
+	}
+}
+
+func TestDiscoverWhoisServerNetworkFailure(t *testing.T) {
+	store := setTestDiscoveryStore(t)
+	t.Cleanup(func() { discoveryFailures.Delete("down") })
+
+	// Nothing listens on the address of a closed listener
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ianaAddr := listener.Addr().String()
+	listener.Close()
+
+	setTestConfig(t, func(cfg *config.Config) {
+		cfg.IanaWhoisServer = ianaAddr
+	})
+
+	_, discoveryErr := DiscoverWhoisServer(context.Background(), "down")
+	if discoveryErr == nil {
+		t.Fatal("DiscoverWhoisServer() error = nil for an unreachable IANA server")
+	}
+	if !lookuperror.IsRetryable(discoveryErr) {
+		t.Errorf("DiscoverWhoisServer() error = %s, want a retryable error", discoveryErr)
+	}
+
+	// The failure is not saved as a TLD without WHOIS server, but it is remembered for a while
+	if _, found, _ := store.Get(context.Background(), "down"); found {
+		t.Error("failed discovery saved in the store")
+	}
+	if (whoisBackend{}).Supports("down") {
+		t.Error("Supports() = true for a TLD whose discovery just failed")
+	}
+
+	// IANA is back, but the failure is remembered until it expires
+	iana, err := whoistest.NewIanaServer(map[string]string{"down": "whois.nic.down"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer iana.Close()
+	setTestConfig(t, func(cfg *config.Config) {
+		cfg.IanaWhoisServer = iana.Addr()
+	})
+
+	_, cachedErr := DiscoverWhoisServer(context.Background(), "down")
+	if cachedErr == nil || cachedErr.Error() != discoveryErr.Error() {
+		t.Errorf("DiscoverWhoisServer() error = %v, want the remembered error %s", cachedErr, discoveryErr)
+	}
+	if queries := iana.Queries(); len(queries) != 0 {
+		t.Errorf("IANA queried while the failure is remembered: %v", queries)
+	}
+
+	discoveryFailures.Delete("down")
+	server, err := DiscoverWhoisServer(context.Background(), "down")
+	if err != nil || server != "whois.nic.down" {
+		t.Errorf("DiscoverWhoisServer() = %q, %v after the failure expired, want whois.nic.down", server, err)
+	}
+}
+
+func TestWhoisQueryFollowsReferral(t *testing.T) {
+	setTestDiscoveryStore(t)
+
+	registrar, err := whoistest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer registrar.Close()
+	registrar.Handle("thin.referral", `Domain Name: THIN.REFERRAL
+Registrar: Example Registrar, Inc.
+Registrant Organization: Example Org
+Registrant Country: DE
+`)
+
+	registry, err := whoistest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer registry.Close()
+	registry.Handle("thin.referral", `Domain Name: THIN.REFERRAL
+Registrar WHOIS Server: `+registrar.Addr()+`
+Registrar: Example Registrar, Inc.
+Creation Date: 2020-01-02T03:04:05Z
+Registry Expiry Date: 2030-01-02T03:04:05Z
+Name Server: NS1.THIN.REFERRAL
+`)
+
+	startIanaServer(t, map[string]string{"referral": registry.Addr()})
+	setTestConfig(t, func(cfg *config.Config) {
+		cfg.WhoisFollowReferral = true
+	})
+
+	domainInfo, err := WhoisQuery(context.Background(), "thin.referral", "referral", false)
+	if err != nil {
+		t.Fatalf("WhoisQuery() error = %s", err)
+	}
+
+	if !slices.Equal(domainInfo.ReferralServers, []string{registrar.Addr()}) {
+		t.Errorf("ReferralServers = %v, want [%s]", domainInfo.ReferralServers, registrar.Addr())
+	}
+	if domainInfo.RegistrantOrganization != "Example Org" || domainInfo.RegistrantCountry != "DE" {
+		t.Errorf("registrant = %q, %q, want the registrant of the registrar", domainInfo.RegistrantOrganization, domainInfo.RegistrantCountry)
+	}
+	if len(domainInfo.NameServer) != 1 {
+		t.Errorf("NameServer = %v, want the name server of the registry", domainInfo.NameServer)
+	}
+	if queries := registrar.Queries(); !slices.Equal(queries, []string{"thin.referral"}) {
+		t.Errorf("registrar queries = %v, want [thin.referral]", queries)
+	}
+}
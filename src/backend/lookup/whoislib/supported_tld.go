@@ -74,6 +74,13 @@ var WhoisSupportedTlds = map[string]string{
 	"nz": "whois.irs.net.nz",
 }
 
+// WhoisAlternateServers are the other WHOIS servers answering the queries of the TLDs in the same format,
+// they are used along with the server in WhoisSupportedTlds.
+var WhoisAlternateServers = map[string][]string{
+	"ru": {"whois.ripn.net"},
+	"su": {"whois.ripn.net"},
+}
+
 var WhoisTldOptions = map[string]string{
 	"de": "-T dn,ace",
 }
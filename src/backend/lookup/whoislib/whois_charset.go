@@ -0,0 +1,96 @@
+package whoislib
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"typonamer/log"
+
+	"github.com/duke-git/lancet/v2/slice"
+	"github.com/duke-git/lancet/v2/strutil"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/japanese"
+)
+
+// charsetHints are the character sets tried first to decode the responses of the TLDs, in order.
+var charsetHints = map[string][]string{
+	"cn": {"gb18030"},
+	"中国": {"gb18030"},
+	"中國": {"big5", "gb18030"},
+	"tw": {"big5"},
+	"hk": {"big5", "gb18030"},
+	"mo": {"big5", "gb18030"},
+	"jp": {"euc-jp", "shift_jis"},
+	"kr": {"euc-kr"},
+	"ru": {"koi8-r", "windows-1251"},
+	"su": {"koi8-r", "windows-1251"},
+	"рф": {"koi8-r", "windows-1251"},
+}
+
+// genericCharsets are the character sets tried to decode the responses which are not decoded by the hints of their TLD.
+// The single-byte character sets decode any response, so they are tried last.
+var genericCharsets = []string{"gb18030", "big5", "euc-jp", "shift_jis", "euc-kr", "koi8-r"}
+
+// iso2022JpEscapes are the escape sequences switching an ISO-2022-JP response to Japanese,
+// such a response is valid UTF-8 as it only uses 7-bit bytes, so it is recognized by them.
+var iso2022JpEscapes = []string{"\x1b$B", "\x1b$@", "\x1b(J"}
+
+// decodeWhoisResponse decodes the WHOIS response to UTF-8.
+// A response which is valid UTF-8 is returned as is, otherwise it is decoded with the charset if given,
+// or with the first of the character sets hinted for the TLD and the generic ones which decodes it without invalid characters.
+func decodeWhoisResponse(response string, tld string, charset string) string {
+	if utf8.ValidString(response) {
+		if !strutil.ContainsAny(response, iso2022JpEscapes) {
+			return response
+		}
+
+		decoded, err := japanese.ISO2022JP.NewDecoder().String(response)
+		if err != nil {
+			log.Debugf("Failed to decode whois response as iso-2022-jp: %s", err)
+			return response
+		}
+		return decoded
+	}
+
+	candidates := []string{charset}
+	if charset == "" {
+		candidates = slice.Concat(charsetHints[tld], genericCharsets)
+	}
+
+	best, bestInvalid := response, -1
+	for _, name := range candidates {
+		enc, err := getCharset(name)
+		if err != nil {
+			log.Warnf("Unknown charset %s for whois response of TLD %s", name, tld)
+			continue
+		}
+
+		decoded, err := enc.NewDecoder().String(response)
+		if err != nil {
+			continue
+		}
+
+		invalid := strings.Count(decoded, string(utf8.RuneError))
+		if invalid == 0 {
+			log.Debugf("Decoded whois response of TLD %s as %s", tld, name)
+			return decoded
+		}
+		if bestInvalid < 0 || invalid < bestInvalid {
+			best, bestInvalid = decoded, invalid
+		}
+	}
+
+	log.Debugf("No charset decodes the whois response of TLD %s without invalid characters", tld)
+	return best
+}
+
+// getCharset returns the encoding of the character set name, such as "gbk", "big5" or "shift_jis".
+func getCharset(name string) (encoding.Encoding, error) {
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown charset %s", name)
+	}
+	return enc, nil
+}
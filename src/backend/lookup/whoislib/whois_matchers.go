@@ -10,9 +10,22 @@ type WhoisInfoMatcher struct {
 	ReExpiryDate                  *regexp.Regexp // ReExpiryDate matches the expiration date.
 	ReNameServer                  *regexp.Regexp // ReNameServer matches the name server.
 	ReFree                        *regexp.Regexp // ReFree matches the free status.
+	ReRateLimited                 *regexp.Regexp // ReRateLimited matches the rate limit or quota exceeded response.
+	ReReserved                    *regexp.Regexp // ReReserved matches the response of a domain reserved by the registry.
+	RePremium                     *regexp.Regexp // RePremium matches the response of a domain available at a premium price.
+	ReBlocked                     *regexp.Regexp // ReBlocked matches the response of a domain blocked by the registry.
+	ReRegistrantOrganization      *regexp.Regexp // ReRegistrantOrganization matches the registrant organisation.
+	ReRegistrarAbuseEmail         *regexp.Regexp // ReRegistrarAbuseEmail matches the registrar abuse contact email.
+	ReRegistrarAbusePhone         *regexp.Regexp // ReRegistrarAbusePhone matches the registrar abuse contact phone.
+	ReUpdatedDate                 *regexp.Regexp // ReUpdatedDate matches the last update date.
+	ReRegistrarIanaId             *regexp.Regexp // ReRegistrarIanaId matches the registrar IANA ID.
+	ReRegistrantCountry           *regexp.Regexp // ReRegistrantCountry matches the registrant country.
+	ReDnssec                      *regexp.Regexp // ReDnssec matches the DNSSEC delegation status.
+	ReDsData                      *regexp.Regexp // ReDsData matches the DS records.
 	DateTimeLayout                string         // DateTimeLayout is the layout of the date and time strings.
 	DateTimeLayoutForCreationDate string         // DateTimeLayoutForCreationDate is the layout of the creation date strings.
 	DateTimeLayoutForExpiryDate   string         // DateTimeLayoutForExpiryDate is the layout of the expiration date strings.
+	Charset                       string         // Charset is the character set of the responses, such as "gbk", empty to detect it.
 }
 
 // 使用正则表达式匹配 WHOIS 数据中的相关信息
@@ -24,6 +37,8 @@ var WhoisMatchers = map[string]WhoisInfoMatcher{
 		ReExpiryDate:   regexp.MustCompile(`Expiration Time: (.*)`),
 		ReNameServer:   regexp.MustCompile(`Name Server: (.*)`),
 		ReFree:         regexp.MustCompile(`^No matching record`),
+		ReRateLimited:  regexp.MustCompile(`Queried interval is too short`),
+		ReReserved:     regexp.MustCompile(`you want to register is reserved`),
 	},
 	"中国": {
 		ReRegistrar:    regexp.MustCompile(`Sponsoring Registrar: (.*)`),
@@ -32,6 +47,8 @@ var WhoisMatchers = map[string]WhoisInfoMatcher{
 		ReExpiryDate:   regexp.MustCompile(`Expiration Time: (.*)`),
 		ReNameServer:   regexp.MustCompile(`Name Server: (.*)`),
 		ReFree:         regexp.MustCompile(`^No matching record`),
+		ReRateLimited:  regexp.MustCompile(`Queried interval is too short`),
+		ReReserved:     regexp.MustCompile(`you want to register is reserved`),
 	},
 	"中國": {
 		ReRegistrar:    regexp.MustCompile(`Sponsoring Registrar: (.*)`),
@@ -40,6 +57,8 @@ var WhoisMatchers = map[string]WhoisInfoMatcher{
 		ReExpiryDate:   regexp.MustCompile(`Expiration Time: (.*)`),
 		ReNameServer:   regexp.MustCompile(`Name Server: (.*)`),
 		ReFree:         regexp.MustCompile(`^No matching record`),
+		ReRateLimited:  regexp.MustCompile(`Queried interval is too short`),
+		ReReserved:     regexp.MustCompile(`you want to register is reserved`),
 	},
 	"au": {
 		ReRegistrar:    regexp.MustCompile(`Registrar Name: (.*)`),
@@ -162,6 +181,7 @@ var WhoisMatchers = map[string]WhoisInfoMatcher{
 		ReExpiryDate:   regexp.MustCompile(`Registry Expiry Date:\s+(.*)`),
 		ReNameServer:   regexp.MustCompile(`Name Server:\s+(.*)`),
 		ReFree:         regexp.MustCompile(`^Domain not found`),
+		ReReserved:     regexp.MustCompile(`(?m)^Registrar:\s+Reserved by Registry`),
 	},
 	"me": {
 		ReRegistrar:    regexp.MustCompile(`Registrar:\s+(.*)`),
@@ -178,6 +198,7 @@ var WhoisMatchers = map[string]WhoisInfoMatcher{
 		ReNameServer:   regexp.MustCompile(`\[Name Server\]\s+(.*)`),
 		DateTimeLayout: "2006/01/02",
 		ReFree:         regexp.MustCompile(`No match!!`),
+		ReRateLimited:  regexp.MustCompile(`(?i)(query|access) limit (has been |was )?exceeded`),
 	},
 	"by": {
 		ReRegistrar:    regexp.MustCompile(`Registrar:\s+(.*)`),
@@ -206,6 +227,7 @@ var WhoisMatchers = map[string]WhoisInfoMatcher{
 		ReExpiryDate:   regexp.MustCompile(`Expire Date:\s+(.*)`),
 		ReNameServer:   regexp.MustCompile(`Nameservers\s*\n\s+((?:.+\n)+)`),
 		ReFree:         regexp.MustCompile(`Status:\s+AVAILABLE`),
+		ReRateLimited:  regexp.MustCompile(`(?i)(too many requests|access refused)`),
 	},
 	"mx": {
 		ReRegistrar:    regexp.MustCompile(`Registrar:\s+(.*)`),
@@ -533,6 +555,7 @@ var WhoisMatchers = map[string]WhoisInfoMatcher{
 		ReDomainStatus: regexp.MustCompile(`Status:\s+(.*)`),
 		ReNameServer:   regexp.MustCompile(`Nserver:\s+(.*)`),
 		ReFree:         regexp.MustCompile(`Status:\s+free`),
+		ReRateLimited:  regexp.MustCompile(`access control limit (reached|exceeded)`),
 	},
 	"nz": {
 		ReRegistrar:    regexp.MustCompile(`Registrar:\s+(.*)`),
@@ -542,3 +565,28 @@ var WhoisMatchers = map[string]WhoisInfoMatcher{
 		ReFree:         regexp.MustCompile(`^Not found:`),
 	},
 }
+
+// DefaultWhoisMatcher parses the WHOIS responses of the TLDs without their own matcher,
+// such as the TLDs whose WHOIS server is discovered from IANA. It matches the common ICANN response format.
+// Its reserved, premium and blocked patterns are only used for these TLDs, the TLDs with a matcher have their own.
+var DefaultWhoisMatcher = WhoisInfoMatcher{
+	ReRegistrar:    regexp.MustCompile(`(?i)Registrar:\s+(.*)`),
+	ReDomainStatus: regexp.MustCompile(`(?i)Domain Status:\s+(.*)`),
+	ReCreationDate: regexp.MustCompile(`(?i)Creation Date:\s+(.*)`),
+	ReExpiryDate:   regexp.MustCompile(`(?i)(?:Registry Expiry Date|Expiration Date|Expiry Date):\s+(.*)`),
+	ReNameServer:   regexp.MustCompile(`(?i)Name Server:\s+(.*)`),
+	ReFree:         regexp.MustCompile(`(?i)(No match for|NOT FOUND|No Data Found|No entries found|Domain not found|Status:\s+free|Status:\s+AVAILABLE)`),
+
+	ReRegistrantOrganization: regexp.MustCompile(`(?i)Registrant Organi[sz]ation:[ \t]*(.*)`),
+	ReRegistrarAbuseEmail:    regexp.MustCompile(`(?i)Registrar Abuse Contact Email:[ \t]*(.*)`),
+	ReRegistrarAbusePhone:    regexp.MustCompile(`(?i)Registrar Abuse Contact Phone:[ \t]*(.*)`),
+	ReUpdatedDate:            regexp.MustCompile(`(?i)(?:Updated Date|Last Updated|Last Modified|Changed|last-update):[ \t]*(.*)`),
+	ReRegistrarIanaId:        regexp.MustCompile(`(?i)Registrar IANA ID:[ \t]*(.*)`),
+	ReRegistrantCountry:      regexp.MustCompile(`(?i)Registrant Country(?: Code)?:[ \t]*(.*)`),
+	ReDnssec:                 regexp.MustCompile(`(?im)^[ \t]*DNSSEC:[ \t]*(.*)`),
+	ReDsData:                 regexp.MustCompile(`(?i)(?:DNSSEC DS Data|DS Data|ds-rdata):[ \t]*(.*)`),
+
+	ReReserved: regexp.MustCompile(`(?im)(reserved by (?:the )?registry|^[ \t]*(?:domain )?status:[ \t]*reserved\b|\b(?:domain|name) (?:name )?(?:is|has been) reserved\b|you want to register is reserved)`),
+	RePremium:  regexp.MustCompile(`(?im)(^[ \t]*(?:domain )?status:[ \t]*premium\b|\b(?:domain|name) (?:name )?is (?:a )?premium\b|premium (?:domain|name) (?:is )?available)`),
+	ReBlocked:  regexp.MustCompile(`(?im)(^[ \t]*(?:domain )?status:[ \t]*blocked\b|\b(?:domain|name) (?:name )?(?:is|has been) blocked\b|blocked by (?:the )?registry|protected by (?:the )?[^\n]*\bDPML\b)`),
+}
@@ -2,11 +2,14 @@ package whoislib
 
 import (
 	"errors"
+	"regexp"
 	"strings"
 	"typonamer/constant"
 	"typonamer/lookup/lookupinfo"
+	"typonamer/utils"
 
 	"github.com/dromara/carbon/v2"
+	"github.com/duke-git/lancet/v2/slice"
 	"github.com/duke-git/lancet/v2/strutil"
 )
 
@@ -21,6 +24,24 @@ var whoisResponseReplaceList = map[string]string{
 	"\r\n": "\n",
 }
 
+var (
+	errDomainNotFound = errors.New("domain not found")
+	errNoDomainInfo   = errors.New("no domain info found in whois response")
+	errRateLimited    = errors.New("whois query rate limited")
+)
+
+// reRateLimitedGeneric matches the common rate limit and quota exceeded responses,
+// it is used for the matchers without their own pattern when no domain info is found in the response.
+var reRateLimitedGeneric = regexp.MustCompile(`(?i)(limit (has been |was )?exceeded|exceeded (the |your )?(query |request |connection )?(limit|quota)|too many (requests|queries|connections)|access control limit|rate limit(ed)?|quota exceeded|interval is too short|try again later)`)
+
+// reRedactedField matches the fields whose values are redacted for privacy, the field name is in the first capture group.
+var reRedactedField = regexp.MustCompile(`(?im)^[ \t]*([A-Za-z][^:\n]*?)[ \t]*:[ \t]*(?:REDACTED(?: FOR PRIVACY)?|Redacted for privacy purposes|Data Protected|Not Disclosed|Non-Public Data|Withheld for Privacy[^\n]*|GDPR Masked|Hidden upon user request|Statutory Masking Enabled)[ \t.]*$`)
+
+const (
+	dnssecSigned   = "signed"
+	dnssecUnsigned = "unsigned"
+)
+
 // ParseWhoisResponse parses the WHOIS response and returns the DomainInfo struct.
 func ParseWhoisResponse(response string, domain string, matcher WhoisInfoMatcher) (lookupinfo.DomainInfo, error) {
 	domainInfo := lookupinfo.DomainInfo{
@@ -32,9 +53,19 @@ func ParseWhoisResponse(response string, domain string, matcher WhoisInfoMatcher
 	// Clean up the response by replacing unwanted characters with a newline.
 	responseContent := strutil.ReplaceWithMap(response, whoisResponseReplaceList)
 
-	if matcher.ReFree != nil {
+	// The rate limit response is checked first, as it may be taken for a free domain.
+	if matcher.ReRateLimited != nil {
+		if matcher.ReRateLimited.MatchString(responseContent) {
+			return domainInfo, errRateLimited
+		}
+	}
+
+	// The reserved, premium and blocked responses are checked before the free one, as they may look like a free domain.
+	domainInfo.RegistryStatus = getRegistryStatus(responseContent, matcher)
+
+	if matcher.ReFree != nil && domainInfo.RegistryStatus == "" {
 		if matcher.ReFree.MatchString(responseContent) {
-			return domainInfo, errors.New("domain not found")
+			return domainInfo, errDomainNotFound
 		}
 	}
 
@@ -72,22 +103,7 @@ func ParseWhoisResponse(response string, domain string, matcher WhoisInfoMatcher
 	if matcher.ReCreationDate != nil {
 		matchCreationDate := matcher.ReCreationDate.FindStringSubmatch(responseContent)
 		if len(matchCreationDate) > 1 {
-			matchCreationDateStr := strutil.ReplaceWithMap(strutil.Trim(matchCreationDate[1]), dateSeqRemoveList)
-			if matcher.DateTimeLayoutForCreationDate != "" {
-				domainInfo.CreationDate = carbon.SetTimezone(carbon.UTC).ParseByLayout(matchCreationDateStr, matcher.DateTimeLayoutForCreationDate).ToDateTimeString()
-			} else if matcher.DateTimeLayout != "" {
-				domainInfo.CreationDate = carbon.SetTimezone(carbon.UTC).ParseByLayout(matchCreationDateStr, matcher.DateTimeLayout).ToDateTimeString()
-			} else {
-				creationDate := carbon.SetTimezone(carbon.UTC).Parse(matchCreationDateStr).ToDateTimeString()
-				if creationDate != "" {
-					domainInfo.CreationDate = creationDate
-				} else {
-					if strings.Contains(matchCreationDateStr, "+") {
-						dateTimStrs := strutil.SplitAndTrim(matchCreationDateStr, "+")
-						domainInfo.CreationDate = carbon.SetTimezone(carbon.UTC).Parse(dateTimStrs[0]).ToDateTimeString()
-					}
-				}
-			}
+			domainInfo.CreationDate = parseWhoisDate(matchCreationDate[1], matcher.DateTimeLayoutForCreationDate, matcher.DateTimeLayout)
 		}
 	}
 
@@ -95,22 +111,7 @@ func ParseWhoisResponse(response string, domain string, matcher WhoisInfoMatcher
 	if matcher.ReExpiryDate != nil {
 		matchExpiryDate := matcher.ReExpiryDate.FindStringSubmatch(responseContent)
 		if len(matchExpiryDate) > 1 {
-			matchExpiryDateStr := strutil.ReplaceWithMap(strutil.Trim(matchExpiryDate[1]), dateSeqRemoveList)
-			if matcher.DateTimeLayoutForExpiryDate != "" {
-				domainInfo.ExpiryDate = carbon.SetTimezone(carbon.UTC).ParseByLayout(matchExpiryDateStr, matcher.DateTimeLayoutForExpiryDate).ToDateTimeString()
-			} else if matcher.DateTimeLayout != "" {
-				domainInfo.ExpiryDate = carbon.SetTimezone(carbon.UTC).ParseByLayout(matchExpiryDateStr, matcher.DateTimeLayout).ToDateTimeString()
-			} else {
-				expiryDate := carbon.SetTimezone(carbon.UTC).Parse(matchExpiryDateStr).ToDateTimeString()
-				if expiryDate != "" {
-					domainInfo.ExpiryDate = expiryDate
-				} else {
-					if strings.Contains(matchExpiryDateStr, "+") {
-						dateTimStrs := strutil.SplitAndTrim(matchExpiryDateStr, "+")
-						domainInfo.ExpiryDate = carbon.SetTimezone(carbon.UTC).Parse(dateTimStrs[0]).ToDateTimeString()
-					}
-				}
-			}
+			domainInfo.ExpiryDate = parseWhoisDate(matchExpiryDate[1], matcher.DateTimeLayoutForExpiryDate, matcher.DateTimeLayout)
 		}
 	}
 
@@ -149,9 +150,137 @@ func ParseWhoisResponse(response string, domain string, matcher WhoisInfoMatcher
 		}
 	}
 
+	// Extract the registration details from the response, the matchers without their own patterns use the common ones.
+	if updatedDate := matchFirstValue(detailPattern(matcher.ReUpdatedDate, DefaultWhoisMatcher.ReUpdatedDate), responseContent); updatedDate != "" {
+		domainInfo.UpdatedDate = parseWhoisDate(updatedDate, "", matcher.DateTimeLayout)
+	}
+	domainInfo.RegistrarIanaId = matchFirstValue(detailPattern(matcher.ReRegistrarIanaId, DefaultWhoisMatcher.ReRegistrarIanaId), responseContent)
+	domainInfo.RegistrantOrganization = matchFirstValue(detailPattern(matcher.ReRegistrantOrganization, DefaultWhoisMatcher.ReRegistrantOrganization), responseContent)
+	domainInfo.RegistrantCountry = matchFirstValue(detailPattern(matcher.ReRegistrantCountry, DefaultWhoisMatcher.ReRegistrantCountry), responseContent)
+	domainInfo.RegistrarAbuseEmail = matchFirstValue(detailPattern(matcher.ReRegistrarAbuseEmail, DefaultWhoisMatcher.ReRegistrarAbuseEmail), responseContent)
+	domainInfo.RegistrarAbusePhone = matchFirstValue(detailPattern(matcher.ReRegistrarAbusePhone, DefaultWhoisMatcher.ReRegistrarAbusePhone), responseContent)
+
+	// Extract the DNSSEC delegation from the response, the DS records mean the delegation is signed if the status is not given.
+	domainInfo.Dnssec = normalizeDnssec(matchFirstValue(detailPattern(matcher.ReDnssec, DefaultWhoisMatcher.ReDnssec), responseContent))
+	if reDsData := detailPattern(matcher.ReDsData, DefaultWhoisMatcher.ReDsData); reDsData != nil {
+		for _, match := range reDsData.FindAllStringSubmatch(responseContent, -1) {
+			if ds := strutil.Trim(match[1]); ds != "" {
+				domainInfo.DsData = append(domainInfo.DsData, ds)
+			}
+		}
+	}
+	if domainInfo.Dnssec == "" && len(domainInfo.DsData) > 0 {
+		domainInfo.Dnssec = dnssecSigned
+	}
+
+	// The registrant data is private if the fields are redacted or a privacy service is the registrant.
+	domainInfo.RedactedFields = getRedactedFields(responseContent)
+	domainInfo.Privacy = len(domainInfo.RedactedFields) > 0 || utils.IsPrivacyService(domainInfo.RegistrantOrganization)
+
+	// A registered domain has a creation date, so a registry status matched in its response, such as in a notice, is not its status.
+	if domainInfo.CreationDate != "" {
+		domainInfo.RegistryStatus = ""
+	}
+
+	// The registry status is the result, even if the response has no domain info.
+	if domainInfo.RegistryStatus != "" {
+		return domainInfo, nil
+	}
+
 	if domainInfo.Registrar == "" && domainInfo.CreationDate == "" && domainInfo.ExpiryDate == "" && len(domainInfo.NameServer) == 0 {
-		return domainInfo, errors.New("no domain info found in whois response")
+		if matcher.ReRateLimited == nil && reRateLimitedGeneric.MatchString(responseContent) {
+			return domainInfo, errRateLimited
+		}
+		return domainInfo, errNoDomainInfo
 	}
 
 	return domainInfo, nil
 }
+
+// getRegistryStatus returns the register status given by the registry in the WHOIS response, Blocked, Reserved or Premium,
+// or an empty string if the response gives none. Only the patterns of the matcher are used, as the wording differs between registries.
+func getRegistryStatus(responseContent string, matcher WhoisInfoMatcher) string {
+	registryStatuses := []struct {
+		re     *regexp.Regexp
+		status string
+	}{
+		{matcher.ReBlocked, constant.DomainRegisterStatusBlocked},
+		{matcher.ReReserved, constant.DomainRegisterStatusReserved},
+		{matcher.RePremium, constant.DomainRegisterStatusPremium},
+	}
+
+	for _, registryStatus := range registryStatuses {
+		if registryStatus.re != nil && registryStatus.re.MatchString(responseContent) {
+			return registryStatus.status
+		}
+	}
+	return ""
+}
+
+// parseWhoisDate parses the date of the WHOIS response to a UTC date time string, empty if it can not be parsed.
+// The date is parsed with the first layout given, or detected if no layout is given.
+func parseWhoisDate(value string, layouts ...string) string {
+	dateStr := strutil.ReplaceWithMap(strutil.Trim(value), dateSeqRemoveList)
+
+	for _, layout := range layouts {
+		if layout != "" {
+			return carbon.SetTimezone(carbon.UTC).ParseByLayout(dateStr, layout).ToDateTimeString()
+		}
+	}
+
+	date := carbon.SetTimezone(carbon.UTC).Parse(dateStr).ToDateTimeString()
+	if date == "" && strings.Contains(dateStr, "+") {
+		dateTimStrs := strutil.SplitAndTrim(dateStr, "+")
+		date = carbon.SetTimezone(carbon.UTC).Parse(dateTimStrs[0]).ToDateTimeString()
+	}
+	return date
+}
+
+// detailPattern returns the pattern of the matcher for a registration detail, or the common pattern if the matcher has none.
+func detailPattern(re *regexp.Regexp, common *regexp.Regexp) *regexp.Regexp {
+	if re != nil {
+		return re
+	}
+	return common
+}
+
+// normalizeDnssec converts the DNSSEC status of the WHOIS response, such as "signedDelegation", "yes" or "unsigned",
+// to "signed" or "unsigned". An unknown status is returned as is.
+func normalizeDnssec(status string) string {
+	lowerStatus := strings.ToLower(status)
+	switch {
+	case lowerStatus == "":
+		return ""
+	case strings.Contains(lowerStatus, "unsigned"), strings.Contains(lowerStatus, "not signed"), strings.Contains(lowerStatus, "inactive"),
+		strings.HasPrefix(lowerStatus, "no"), lowerStatus == "false":
+		return dnssecUnsigned
+	case strings.Contains(lowerStatus, "signed"), strings.Contains(lowerStatus, "active"), strings.HasPrefix(lowerStatus, "yes"), lowerStatus == "true":
+		return dnssecSigned
+	default:
+		return status
+	}
+}
+
+// getRedactedFields returns the names of the fields whose values are redacted in the WHOIS response, such as "Registrant Name".
+func getRedactedFields(responseContent string) []string {
+	var fields []string
+	for _, match := range reRedactedField.FindAllStringSubmatch(responseContent, -1) {
+		if field := strutil.Trim(match[1]); !slice.Contain(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// matchFirstValue returns the trimmed value of the first match of the regular expression, or an empty string if it does not match.
+func matchFirstValue(re *regexp.Regexp, content string) string {
+	if re == nil {
+		return ""
+	}
+
+	match := re.FindStringSubmatch(content)
+	if len(match) > 1 {
+		return strutil.Trim(match[1])
+	}
+	return ""
+}
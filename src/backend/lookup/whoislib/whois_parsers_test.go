@@ -0,0 +1,203 @@
+package whoislib
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fixturesDir holds the captured raw WHOIS responses, one directory per TLD.
+// Each <case>.txt raw response has a <case>.json file with the expected result of parsing it.
+const fixturesDir = "testdata/fixtures"
+
+var updateFixtures = flag.Bool("update", false, "rewrite the expected results of the WHOIS fixtures from the current parsers")
+
+// whoisFixture is the expected result of parsing a captured WHOIS response.
+type whoisFixture struct {
+	Domain string              `json:"domain"` // Domain is the domain queried.
+	Error  string              `json:"error"`  // Error is the parse error, "notFound", "rateLimited", "noDomainInfo" or empty if the response is parsed.
+	Result *whoisFixtureResult `json:"result"` // Result is the parsed data, nil if the response is not parsed.
+}
+
+// whoisFixtureResult is the data extracted from a WHOIS response.
+type whoisFixtureResult struct {
+	RegistryStatus         string   `json:"registryStatus"`
+	Registrar              string   `json:"registrar"`
+	DomainStatus           []string `json:"domainStatus"`
+	CreationDate           string   `json:"creationDate"`
+	ExpiryDate             string   `json:"expiryDate"`
+	NameServer             []string `json:"nameServer"`
+	UpdatedDate            string   `json:"updatedDate"`
+	RegistrarIanaId        string   `json:"registrarIanaId"`
+	RegistrantOrganization string   `json:"registrantOrganization"`
+	RegistrantCountry      string   `json:"registrantCountry"`
+	RegistrarAbuseEmail    string   `json:"registrarAbuseEmail"`
+	RegistrarAbusePhone    string   `json:"registrarAbusePhone"`
+	Dnssec                 string   `json:"dnssec"`
+	DsData                 []string `json:"dsData"`
+	Privacy                bool     `json:"privacy"`
+	RedactedFields         []string `json:"redactedFields"`
+}
+
+// TestParseWhoisResponseFixtures parses every captured response with the matcher of its TLD and compares the result
+// with the expected one. Run the test with -update to rewrite the expected results after changing a matcher on purpose.
+func TestParseWhoisResponseFixtures(t *testing.T) {
+	rawFiles, err := filepath.Glob(filepath.Join(fixturesDir, "*", "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rawFiles) == 0 {
+		t.Fatalf("no fixtures found in %s", fixturesDir)
+	}
+
+	for _, rawFile := range rawFiles {
+		tld := filepath.Base(filepath.Dir(rawFile))
+		expectedFile := strings.TrimSuffix(rawFile, ".txt") + ".json"
+
+		t.Run(tld+"/"+strings.TrimSuffix(filepath.Base(rawFile), ".txt"), func(t *testing.T) {
+			response, err := os.ReadFile(rawFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var expected whoisFixture
+			err = readFixture(expectedFile, &expected)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			actual := parseFixture(tld, expected.Domain, string(response))
+
+			if *updateFixtures {
+				err = writeFixture(expectedFile, actual)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			if !reflect.DeepEqual(expected, actual) {
+				expectedJson, _ := json.MarshalIndent(expected, "", "  ")
+				actualJson, _ := json.MarshalIndent(actual, "", "  ")
+				t.Errorf("parse result of %s differs from %s\nexpected:\n%s\nactual:\n%s", rawFile, expectedFile, expectedJson, actualJson)
+			}
+		})
+	}
+}
+
+// TestRecordWhoisFixture records a new fixture from the RawResponse of a live lookup saved in a file,
+// the expected result is taken from the current parser and should be checked by hand before committing it.
+// It only runs when the fixture is given by the environment variables, for example:
+//
+//	WHOIS_FIXTURE_RAW=/tmp/raw.txt WHOIS_FIXTURE_TLD=cn WHOIS_FIXTURE_CASE=taken WHOIS_FIXTURE_DOMAIN=example.cn \
+//		go test ./lookup/whoislib -run TestRecordWhoisFixture
+func TestRecordWhoisFixture(t *testing.T) {
+	rawFile := os.Getenv("WHOIS_FIXTURE_RAW")
+	if rawFile == "" {
+		t.Skip("WHOIS_FIXTURE_RAW not set")
+	}
+
+	tld := os.Getenv("WHOIS_FIXTURE_TLD")
+	caseName := os.Getenv("WHOIS_FIXTURE_CASE")
+	domain := os.Getenv("WHOIS_FIXTURE_DOMAIN")
+	if tld == "" || caseName == "" || domain == "" {
+		t.Fatal("WHOIS_FIXTURE_TLD, WHOIS_FIXTURE_CASE and WHOIS_FIXTURE_DOMAIN are required")
+	}
+
+	response, err := os.ReadFile(rawFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fixture, err := recordFixture(tld, caseName, domain, string(response))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fixtureJson, _ := json.MarshalIndent(fixture, "", "  ")
+	t.Logf("recorded fixture %s/%s:\n%s", tld, caseName, fixtureJson)
+}
+
+// recordFixture saves the raw response and the result of parsing it as the fixture of the TLD.
+func recordFixture(tld string, caseName string, domain string, response string) (whoisFixture, error) {
+	dir := filepath.Join(fixturesDir, tld)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return whoisFixture{}, err
+	}
+
+	// The fixtures use the line endings of the parser, which replaces CRLF anyway
+	response = strings.ReplaceAll(response, "\r\n", "\n")
+	err = os.WriteFile(filepath.Join(dir, caseName+".txt"), []byte(response), 0644)
+	if err != nil {
+		return whoisFixture{}, err
+	}
+
+	fixture := parseFixture(tld, domain, response)
+	return fixture, writeFixture(filepath.Join(dir, caseName+".json"), fixture)
+}
+
+// parseFixture decodes and parses the response with the matcher of the TLD, or the default matcher if the TLD has none.
+func parseFixture(tld string, domain string, response string) whoisFixture {
+	matcher, ok := getWhoisMatcher(tld)
+	if !ok {
+		matcher = DefaultWhoisMatcher
+	}
+	response = decodeWhoisResponse(response, tld, matcher.Charset)
+
+	fixture := whoisFixture{Domain: domain}
+
+	domainInfo, err := ParseWhoisResponse(response, domain, matcher)
+	switch {
+	case errors.Is(err, errDomainNotFound):
+		fixture.Error = "notFound"
+	case errors.Is(err, errRateLimited):
+		fixture.Error = "rateLimited"
+	case errors.Is(err, errNoDomainInfo):
+		fixture.Error = "noDomainInfo"
+	case err != nil:
+		fixture.Error = err.Error()
+	default:
+		fixture.Result = &whoisFixtureResult{
+			RegistryStatus:         domainInfo.RegistryStatus,
+			Registrar:              domainInfo.Registrar,
+			DomainStatus:           domainInfo.DomainStatus,
+			CreationDate:           domainInfo.CreationDate,
+			ExpiryDate:             domainInfo.ExpiryDate,
+			NameServer:             domainInfo.NameServer,
+			UpdatedDate:            domainInfo.UpdatedDate,
+			RegistrarIanaId:        domainInfo.RegistrarIanaId,
+			RegistrantOrganization: domainInfo.RegistrantOrganization,
+			RegistrantCountry:      domainInfo.RegistrantCountry,
+			RegistrarAbuseEmail:    domainInfo.RegistrarAbuseEmail,
+			RegistrarAbusePhone:    domainInfo.RegistrarAbusePhone,
+			Dnssec:                 domainInfo.Dnssec,
+			DsData:                 domainInfo.DsData,
+			Privacy:                domainInfo.Privacy,
+			RedactedFields:         domainInfo.RedactedFields,
+		}
+	}
+
+	return fixture
+}
+
+func readFixture(path string, fixture *whoisFixture) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, fixture)
+}
+
+func writeFixture(path string, fixture whoisFixture) error {
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -3,10 +3,12 @@ package whoislib
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"strconv"
+	"strings"
 	"time"
 
 	"typonamer/config"
@@ -15,14 +17,26 @@ import (
 	"typonamer/lookup/lookuperror"
 	"typonamer/lookup/lookupinfo"
 
-	"github.com/duke-git/lancet/v2/maputil"
+	"github.com/duke-git/lancet/v2/slice"
 	"github.com/duke-git/lancet/v2/strutil"
 	"golang.org/x/net/proxy"
 )
 
+const (
+	// defaultWhoisPort is the port of the WHOIS servers given without a port.
+	defaultWhoisPort = "43"
+)
+
 // WhoisQuery function is used to query the WHOIS information for a given domain.
 // If the useProxy parameter is set to true, it will use the proxy server to query the WHOIS information.
-func WhoisQuery(domain string, tld string, useProxy bool) (lookupinfo.DomainInfo, error) {
+// If the WHOIS server of the TLD is unknown, it is discovered from the IANA WHOIS server.
+// The response is decoded to UTF-8 with the charset of the matcher, or a detected one, before it is parsed.
+// If following referrals is enabled, the registrar WHOIS server referred by a thin registry is queried as well.
+// A server which rate limited a query is not queried again until its cool-down ends.
+// A TLD may have several servers, one of their addresses is selected by the configured selection,
+// skipping the addresses ejected after failing in a row.
+// The connection is closed as soon as the context is canceled.
+func WhoisQuery(ctx context.Context, domain string, tld string, useProxy bool) (lookupinfo.DomainInfo, error) {
 	log.Debugf("Querying whois for domain: %s", domain)
 
 	var domainInfo = lookupinfo.DomainInfo{
@@ -31,22 +45,119 @@ func WhoisQuery(domain string, tld string, useProxy bool) (lookupinfo.DomainInfo
 	}
 
 	// Check if the TLD is supported
-	whoisServer, ok := WhoisSupportedTlds[tld]
+	whoisServers, ok := getWhoisServers(tld)
+	discovered := false
 	if !ok {
-		log.Warnf("Whois not supported for TLD: %s", tld)
-		return domainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorNotSupportedTld, tld)
+		whoisServer, err := DiscoverWhoisServer(ctx, tld)
+		if err != nil {
+			return domainInfo, err
+		}
+		if whoisServer == "" {
+			log.Warnf("Whois not supported for TLD: %s", tld)
+			return domainInfo, lookuperror.Newf(lookuperror.ErrorNotSupportedTld, "", "%s", tld)
+		}
+		whoisServers = []string{whoisServer}
+		discovered = true
+	}
+
+	// Select the server to query, the servers cooling down from a rate limit are not queried
+	endpoint, coolDownEnd, ok := selectWhoisEndpoint(ctx, tld, whoisServers, useProxy)
+	if !ok {
+		log.Infof("Whois servers of TLD %s are cooling down until %s, skipping domain %s", tld, coolDownEnd.Format(time.DateTime), domain)
+		return domainInfo, lookuperror.Newf(lookuperror.ErrorWhoisRateLimited, slice.Join(whoisServers, ","), "cooling down until %s", coolDownEnd.Format(time.DateTime))
+	}
+	whoisServer := endpoint.server
+
+	domainInfo.Trace.Server = endpoint.String()
+
+	// Use the matcher corresponding to the TLD to decode and parse the WHOIS data,
+	// the servers discovered from IANA are parsed with the default matcher if the TLD has no matcher.
+	matcher, hasMatcher := getWhoisMatcher(tld)
+	if !hasMatcher && discovered {
+		matcher, hasMatcher = DefaultWhoisMatcher, true
+	}
+
+	queryInfo := fmt.Sprintf("%s\r\n", domain)
+	if option, ok := getWhoisTldOption(tld); ok {
+		queryInfo = fmt.Sprintf("%s %s\r\n", option, domain)
+	}
+
+	log.Infof("Querying WHOIS for domain: %s with TLD: %s on server: %s", domain, tld, endpoint)
+
+	queryStart := time.Now()
+	queryResult, proxyServer, err := queryWhoisAddress(ctx, endpoint.String(), endpoint.address, queryInfo, useProxy)
+	latency := time.Since(queryStart)
+	domainInfo.Trace.Proxy = proxyServer
+	if err == nil && strutil.Trim(queryResult) == "" {
+		err = lookuperror.Newf(lookuperror.ErrorNoContentInWhoisResponse, whoisServer, "%s", domain)
+	}
+	if err != nil {
+		recordWhoisResult(endpoint, useProxy, latency, err)
+		return domainInfo, err
 	}
 
+	// Decode the response to UTF-8 before matching, as some registries answer in their local character set
+	queryResult = decodeWhoisResponse(queryResult, tld, matcher.Charset)
+	domainInfo.RawResponse = queryResult
+
+	log.Debugf("Whois query raw result: \n%s", queryResult)
+
+	if hasMatcher {
+		trace := domainInfo.Trace
+		domainInfo, err = ParseWhoisResponse(queryResult, domain, matcher)
+		domainInfo.ViaProxy = useProxy
+		domainInfo.Trace = trace
+
+		// A rate limited response is not a successful query, so only the other responses update the latency of the server
+		if !errors.Is(err, errRateLimited) {
+			recordWhoisResult(endpoint, useProxy, latency, nil)
+		}
+
+		if err != nil {
+			if errors.Is(err, errDomainNotFound) {
+				log.Infof("Domain %s is not registered", domain)
+				return domainInfo, lookuperror.Newf(lookuperror.ErrorWhoisNotFound, whoisServer, "%s", domain)
+			} else if errors.Is(err, errRateLimited) {
+				until := coolDownServer(whoisServer, useProxy)
+				return domainInfo, lookuperror.Newf(lookuperror.ErrorWhoisRateLimited, whoisServer, "cooling down until %s", until.Format(time.DateTime))
+			} else {
+				log.Errorf("Failed to parse WHOIS response for domain %s: %s", domain, err)
+				return domainInfo, lookuperror.New(lookuperror.ErrorParseWhoisResponse, whoisServer, err)
+			}
+		}
+
+		// Follow the referral of thin registries to merge the registrar data
+		if config.GetConfig().WhoisFollowReferral {
+			domainInfo = followReferrals(ctx, domainInfo, domain, whoisServer, queryResult, useProxy)
+		}
+
+		return domainInfo, nil
+	} else {
+		recordWhoisResult(endpoint, useProxy, latency, nil)
+		log.Error("No parsing rule for TLD: ", tld)
+		return domainInfo, lookuperror.Newf(lookuperror.ErrorNoParseRuleForTld, whoisServer, "%s", tld)
+	}
+}
+
+// queryWhoisServer sends the query to the WHOIS server and returns the raw response,
+// and the address of the proxy server if the query goes through the proxy.
+// The WHOIS server may be given with a port, otherwise the port 43 is used.
+// The connection is closed as soon as the context is canceled.
+func queryWhoisServer(ctx context.Context, whoisServer string, queryInfo string, useProxy bool) (string, string, error) {
+	return queryWhoisAddress(ctx, whoisServer, whoisServerAddress(whoisServer), queryInfo, useProxy)
+}
+
+// queryWhoisAddress sends the query to the address of the WHOIS server, such as one of the IP addresses of its host.
+// The errors are reported with the WHOIS server given.
+func queryWhoisAddress(ctx context.Context, whoisServer string, whoisAddr string, queryInfo string, useProxy bool) (string, string, error) {
 	// Read the configuration
 	cfg := config.GetConfig()
 
-	// Create the address for the WHOIS server
-	whoisAddr := net.JoinHostPort(whoisServer, "43")
-
 	// Create the connection
 	conn := net.Conn(nil)
+	proxyServer := ""
 	if useProxy {
-		proxyServer := net.JoinHostPort(cfg.SocketProxyHost, strconv.Itoa(cfg.SocketProxyPort))
+		proxyServer = net.JoinHostPort(cfg.SocketProxyHost, strconv.Itoa(cfg.SocketProxyPort))
 		var proxyDialer proxy.Dialer
 		var err error
 
@@ -64,45 +175,52 @@ func WhoisQuery(domain string, tld string, useProxy bool) (lookupinfo.DomainInfo
 			proxyDialer, err = proxy.SOCKS5("tcp", proxyServer, &proxyAuth, baseTCPDialer)
 			if err != nil {
 				log.Warnf("Failed to connect to the proxy server with auth: %s", err)
-				return domainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorConnectToProxy, err.Error())
+				return "", proxyServer, lookuperror.New(lookuperror.ErrorConnectToProxy, proxyServer, err)
 			}
 		} else {
 			proxyDialer, err = proxy.SOCKS5("tcp", proxyServer, nil, baseTCPDialer)
 			if err != nil {
 				log.Warnf("Failed to connect to the proxy server without auth: %s", err)
-				return domainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorConnectToProxy, err.Error())
+				return "", proxyServer, lookuperror.New(lookuperror.ErrorConnectToProxy, proxyServer, err)
 			}
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*time.Duration(cfg.WhoisTimeout))
+		dialCtx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(cfg.WhoisTimeout))
 		defer cancel()
 
-		proxyConn, err := proxyDialer.(proxy.ContextDialer).DialContext(ctx, "tcp", whoisAddr)
+		proxyConn, err := proxyDialer.(proxy.ContextDialer).DialContext(dialCtx, "tcp", whoisAddr)
 		if err != nil {
+			if ctx.Err() != nil {
+				return "", proxyServer, lookuperror.New(lookuperror.ErrorLookupCanceled, whoisServer, ctx.Err())
+			}
 			log.Warnf("Failed to connect to the whois server %s via proxy: %s", whoisAddr, err)
-			return domainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorWhoisTimeout, err.Error())
+			return "", proxyServer, lookuperror.New(lookuperror.ErrorWhoisTimeout, whoisServer, err)
 		}
 
 		// Set timeouts for the proxy connection
 		err = proxyConn.SetDeadline(time.Now().Add(time.Second * time.Duration(cfg.WhoisTimeout)))
 		if err != nil {
 			log.Warnf("Failed to set deadline for proxy connection: %s", err)
-			return domainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorWhoisTimeout, err.Error())
+			return "", proxyServer, lookuperror.New(lookuperror.ErrorWhoisTimeout, whoisServer, err)
 		}
 
 		conn = proxyConn
 	} else {
-		rawConn, err := net.DialTimeout("tcp", whoisAddr, time.Second*time.Duration(cfg.WhoisTimeout))
+		dialer := &net.Dialer{Timeout: time.Second * time.Duration(cfg.WhoisTimeout)}
+		rawConn, err := dialer.DialContext(ctx, "tcp", whoisAddr)
 		if err != nil {
+			if ctx.Err() != nil {
+				return "", proxyServer, lookuperror.New(lookuperror.ErrorLookupCanceled, whoisServer, ctx.Err())
+			}
 			log.Warnf("Failed to connect to the whois server %s: %s", whoisAddr, err)
-			return domainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorWhoisTimeout, err.Error())
+			return "", proxyServer, lookuperror.New(lookuperror.ErrorWhoisTimeout, whoisServer, err)
 		}
 
 		// Set timeouts for the direct connection
 		err = rawConn.SetDeadline(time.Now().Add(time.Second * time.Duration(cfg.WhoisTimeout)))
 		if err != nil {
 			log.Warnf("Failed to set deadline for connection: %s", err)
-			return domainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorWhoisTimeout, err.Error())
+			return "", proxyServer, lookuperror.New(lookuperror.ErrorWhoisTimeout, whoisServer, err)
 		}
 
 		conn = rawConn
@@ -110,71 +228,60 @@ func WhoisQuery(domain string, tld string, useProxy bool) (lookupinfo.DomainInfo
 
 	defer conn.Close()
 
-	log.Infof("Querying WHOIS for domain: %s with TLD: %s on server: %s", domain, tld, whoisServer)
+	// Close the connection when the context is canceled to abort the pending read or write
+	stopCloseOnCancel := context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+	defer stopCloseOnCancel()
 
 	// Set write deadline
 	err := conn.SetWriteDeadline(time.Now().Add(time.Second * time.Duration(cfg.WhoisTimeout)))
 	if err != nil {
-		return domainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorWhoisTimeout, err.Error())
-	}
-
-	queryInfo := fmt.Sprintf("%s\r\n", domain)
-	if maputil.HasKey(WhoisTldOptions, tld) {
-		queryInfo = fmt.Sprintf("%s %s\r\n", WhoisTldOptions[tld], domain)
+		return "", proxyServer, lookuperror.New(lookuperror.ErrorWhoisTimeout, whoisServer, err)
 	}
 
 	// Write the query to the server
 	_, err = conn.Write([]byte(queryInfo))
 	if err != nil {
+		if ctx.Err() != nil {
+			return "", proxyServer, lookuperror.New(lookuperror.ErrorLookupCanceled, whoisServer, ctx.Err())
+		}
 		log.Warnf("Failed to write query: %s", err)
-		return domainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorWhoisTimeout, err.Error())
+		return "", proxyServer, lookuperror.New(lookuperror.ErrorWhoisTimeout, whoisServer, err)
 	}
 
 	// Set read deadline
 	err = conn.SetReadDeadline(time.Now().Add(time.Second * time.Duration(cfg.WhoisTimeout)))
 	if err != nil {
-		return domainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorWhoisTimeout, err.Error())
+		return "", proxyServer, lookuperror.New(lookuperror.ErrorWhoisTimeout, whoisServer, err)
 	}
 
 	// Read the response from the server
 	var buf bytes.Buffer
 	_, err = io.Copy(&buf, conn)
 	if err != nil {
+		if ctx.Err() != nil {
+			return "", proxyServer, lookuperror.New(lookuperror.ErrorLookupCanceled, whoisServer, ctx.Err())
+		}
 		log.Warnf("Failed to read WHOIS response: %s", err)
 		if _, ok := err.(net.Error); ok {
-			return domainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorWhoisTimeout, err.Error())
+			return "", proxyServer, lookuperror.New(lookuperror.ErrorWhoisTimeout, whoisServer, err)
 		}
-		return domainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorWhoisServerFailed, err.Error())
+		return "", proxyServer, lookuperror.New(lookuperror.ErrorWhoisServerFailed, whoisServer, err)
 	}
 
-	// Get the query result from the buffer
-	queryResult := buf.String()
-	domainInfo.RawResponse = queryResult
-
-	log.Debugf("Whois query raw result: \n%s", queryResult)
+	return buf.String(), proxyServer, nil
+}
 
-	// Check if the query result is empty
-	if strutil.Trim(queryResult) == "" {
-		return domainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorNoContentInWhoisResponse, domain)
+// whoisServerAddress returns the address of the WHOIS server, the port 43 is used if the server has no port.
+func whoisServerAddress(whoisServer string) string {
+	if _, _, err := net.SplitHostPort(whoisServer); err == nil {
+		return whoisServer
 	}
+	return net.JoinHostPort(whoisServer, defaultWhoisPort)
+}
 
-	// Use the matcher corresponding to the TLD to parse the WHOIS data
-	if matcher, ok := WhoisMatchers[tld]; ok {
-		domainInfo, err = ParseWhoisResponse(queryResult, domain, matcher)
-		domainInfo.ViaProxy = useProxy
-		if err != nil {
-			if err.Error() == "domain not found" {
-				log.Infof("Domain %s is not registered", domain)
-				return domainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorWhoisNotFound, domain)
-			} else {
-				log.Errorf("Failed to parse WHOIS response for domain %s: %s", domain, err)
-				return domainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorParseWhoisResponse, err.Error())
-			}
-		}
-
-		return domainInfo, nil
-	} else {
-		log.Error("No parsing rule for TLD: ", tld)
-		return domainInfo, fmt.Errorf("%w: %s", lookuperror.ErrorNoParseRuleForTld, tld)
-	}
+// whoisServerKey returns the key identifying the WHOIS server, the same server with and without the port 43 has the same key.
+func whoisServerKey(whoisServer string) string {
+	return strings.ToLower(whoisServerAddress(whoisServer))
 }
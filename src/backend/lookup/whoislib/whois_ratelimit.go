@@ -0,0 +1,66 @@
+package whoislib
+
+import (
+	"sync"
+	"time"
+
+	"typonamer/config"
+	"typonamer/log"
+)
+
+const (
+	// defaultWhoisRateLimitCoolDown is the time to pause the queries to a rate limited WHOIS server if none is configured.
+	defaultWhoisRateLimitCoolDown = time.Minute
+)
+
+var (
+	// coolDowns holds the end of the cool-down of the rate limited WHOIS servers.
+	// It is shared by the web, typo and bulk checks, as they all query the servers from this process.
+	coolDowns    = make(map[string]time.Time)
+	coolDownsMux sync.Mutex
+)
+
+// coolDownServer pauses the queries to the WHOIS server for the configured cool-down after it rate limited a query.
+// The queries via proxy come from another address, so they are paused apart from the direct queries.
+func coolDownServer(whoisServer string, useProxy bool) time.Time {
+	coolDown := time.Duration(config.GetConfig().WhoisRateLimitCoolDown) * time.Second
+	if coolDown <= 0 {
+		coolDown = defaultWhoisRateLimitCoolDown
+	}
+
+	until := time.Now().Add(coolDown)
+
+	coolDownsMux.Lock()
+	coolDowns[coolDownKey(whoisServer, useProxy)] = until
+	coolDownsMux.Unlock()
+
+	log.Warnf("Whois server %s rate limited the query (via proxy: %t), pausing the queries to it until %s", whoisServer, useProxy, until.Format(time.DateTime))
+
+	return until
+}
+
+// serverCoolingDown returns the end of the cool-down of the WHOIS server, ok is false if the server is not cooling down.
+func serverCoolingDown(whoisServer string, useProxy bool) (time.Time, bool) {
+	key := coolDownKey(whoisServer, useProxy)
+
+	coolDownsMux.Lock()
+	defer coolDownsMux.Unlock()
+
+	until, ok := coolDowns[key]
+	if !ok {
+		return time.Time{}, false
+	}
+	if time.Now().After(until) {
+		delete(coolDowns, key)
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+// coolDownKey returns the key of the cool-down of the WHOIS server.
+func coolDownKey(whoisServer string, useProxy bool) string {
+	if useProxy {
+		return whoisServerKey(whoisServer) + "|proxy"
+	}
+	return whoisServerKey(whoisServer)
+}
@@ -0,0 +1,170 @@
+package whoislib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"typonamer/config"
+	"typonamer/log"
+	"typonamer/lookup/lookupinfo"
+	"typonamer/utils"
+
+	"github.com/duke-git/lancet/v2/slice"
+)
+
+const (
+	// defaultWhoisReferralMaxDepth is the maximum number of referrals followed if none is configured.
+	defaultWhoisReferralMaxDepth = 1
+)
+
+// referralWhoisMatcher parses the responses of the registrar WHOIS servers, which do not give the registry status of the domain.
+var referralWhoisMatcher = func() WhoisInfoMatcher {
+	matcher := DefaultWhoisMatcher
+	matcher.ReReserved, matcher.RePremium, matcher.ReBlocked = nil, nil, nil
+	return matcher
+}()
+
+// reReferralServer matches the registrar WHOIS server referred by the response of a thin registry.
+var reReferralServer = regexp.MustCompile(`(?im)^[ \t]*(?:Registrar WHOIS Server|ReferralServer|Whois Server):[ \t]*(\S+)`)
+
+// ParseReferralServer returns the WHOIS server referred by the WHOIS response, or an empty string if it refers none.
+// The "ReferralServer:" lines given as URLs are accepted with the whois scheme only, such as whois://whois.example.com.
+func ParseReferralServer(response string) string {
+	match := reReferralServer.FindStringSubmatch(response)
+	if len(match) < 2 {
+		return ""
+	}
+
+	server := strings.TrimSpace(match[1])
+	if scheme, address, ok := strings.Cut(server, "://"); ok {
+		if !strings.EqualFold(scheme, "whois") {
+			return ""
+		}
+		server = address
+	}
+
+	return strings.ToLower(strings.TrimRight(server, "/"))
+}
+
+// followReferrals queries the registrar WHOIS servers referred by the registry response, up to the configured depth,
+// and merges the registrar data into the domain info. The raw responses of all servers queried are kept in RawResponse.
+// A referral to a server already queried ends the following, as well as a failed referral,
+// in which case the data found so far is returned.
+func followReferrals(ctx context.Context, domainInfo lookupinfo.DomainInfo, domain string, registryServer string, response string, useProxy bool) lookupinfo.DomainInfo {
+	maxDepth := config.GetConfig().WhoisReferralMaxDepth
+	if maxDepth <= 0 {
+		maxDepth = defaultWhoisReferralMaxDepth
+	}
+
+	visited := map[string]bool{whoisServerKey(registryServer): true}
+	rawResponses := []string{referralSection(registryServer, response)}
+
+	for depth := 0; depth < maxDepth; depth++ {
+		referralServer := ParseReferralServer(response)
+		if referralServer == "" {
+			break
+		}
+		if visited[whoisServerKey(referralServer)] {
+			log.Debugf("Whois referral loop for domain %s at server %s", domain, referralServer)
+			break
+		}
+		visited[whoisServerKey(referralServer)] = true
+
+		if ctx.Err() != nil {
+			break
+		}
+		if _, coolingDown := serverCoolingDown(referralServer, useProxy); coolingDown {
+			log.Infof("Whois server %s is cooling down, skipping the referral for domain %s", referralServer, domain)
+			break
+		}
+
+		log.Infof("Following whois referral for domain %s to server %s", domain, referralServer)
+
+		referralResponse, _, err := queryWhoisServer(ctx, referralServer, fmt.Sprintf("%s\r\n", domain), useProxy)
+		if err != nil {
+			log.Warnf("Failed to follow whois referral for domain %s to server %s: %s", domain, referralServer, err)
+			break
+		}
+
+		referralResponse = decodeWhoisResponse(referralResponse, "", DefaultWhoisMatcher.Charset)
+
+		domainInfo.ReferralServers = append(domainInfo.ReferralServers, referralServer)
+		rawResponses = append(rawResponses, referralSection(referralServer, referralResponse))
+
+		referralInfo, err := ParseWhoisResponse(referralResponse, domain, referralWhoisMatcher)
+		if errors.Is(err, errRateLimited) {
+			coolDownServer(referralServer, useProxy)
+			break
+		}
+		if err != nil {
+			log.Warnf("Failed to parse whois referral response for domain %s from server %s: %s", domain, referralServer, err)
+			break
+		}
+		mergeReferralInfo(&domainInfo, referralInfo)
+
+		response = referralResponse
+	}
+
+	if len(rawResponses) > 1 {
+		domainInfo.RawResponse = strings.Join(rawResponses, "\n\n")
+	}
+
+	return domainInfo
+}
+
+// mergeReferralInfo merges the registrar data into the registry data.
+// The registry data is kept if it is present, while the contacts are taken from the registrar, which is the source of them.
+func mergeReferralInfo(domainInfo *lookupinfo.DomainInfo, referralInfo lookupinfo.DomainInfo) {
+	if domainInfo.Registrar == "" {
+		domainInfo.Registrar = referralInfo.Registrar
+	}
+	if len(domainInfo.DomainStatus) == 0 {
+		domainInfo.DomainStatus = referralInfo.DomainStatus
+	}
+	if domainInfo.CreationDate == "" {
+		domainInfo.CreationDate = referralInfo.CreationDate
+	}
+	if domainInfo.ExpiryDate == "" {
+		domainInfo.ExpiryDate = referralInfo.ExpiryDate
+	}
+	if len(domainInfo.NameServer) == 0 {
+		domainInfo.NameServer = referralInfo.NameServer
+	}
+	if domainInfo.UpdatedDate == "" {
+		domainInfo.UpdatedDate = referralInfo.UpdatedDate
+	}
+	if domainInfo.RegistrarIanaId == "" {
+		domainInfo.RegistrarIanaId = referralInfo.RegistrarIanaId
+	}
+	if domainInfo.Dnssec == "" {
+		domainInfo.Dnssec = referralInfo.Dnssec
+	}
+	if len(domainInfo.DsData) == 0 {
+		domainInfo.DsData = referralInfo.DsData
+	}
+
+	if referralInfo.RegistrantOrganization != "" {
+		domainInfo.RegistrantOrganization = referralInfo.RegistrantOrganization
+	}
+	if referralInfo.RegistrantCountry != "" {
+		domainInfo.RegistrantCountry = referralInfo.RegistrantCountry
+	}
+	if referralInfo.RegistrarAbuseEmail != "" {
+		domainInfo.RegistrarAbuseEmail = referralInfo.RegistrarAbuseEmail
+	}
+	if referralInfo.RegistrarAbusePhone != "" {
+		domainInfo.RegistrarAbusePhone = referralInfo.RegistrarAbusePhone
+	}
+
+	// The registrant data is private if either server redacted it
+	domainInfo.RedactedFields = slice.Union(domainInfo.RedactedFields, referralInfo.RedactedFields)
+	domainInfo.Privacy = domainInfo.Privacy || referralInfo.Privacy || utils.IsPrivacyService(domainInfo.RegistrantOrganization)
+}
+
+// referralSection returns the raw response of the WHOIS server headed by the server.
+func referralSection(whoisServer string, response string) string {
+	return fmt.Sprintf("===== %s =====\n%s", whoisServer, response)
+}
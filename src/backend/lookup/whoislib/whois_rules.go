@@ -0,0 +1,432 @@
+package whoislib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"typonamer/config"
+	"typonamer/log"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// whoisRulesFileName is the name of the WHOIS matcher rules file.
+	// The file is located in the same directory as the configuration file.
+	whoisRulesFileName = "whois_rules.yaml"
+
+	// whoisRulesPollInterval is how often the rules file is checked for changes.
+	whoisRulesPollInterval = 5 * time.Second
+)
+
+// ErrorInvalidWhoisRules is returned when the WHOIS matcher rules can not be parsed or fail the validation.
+var ErrorInvalidWhoisRules = errors.New("invalid whois rules")
+
+// WhoisMatcherRule is the definition of the WHOIS matcher of a TLD in the rules file.
+// The regular expressions are given as strings, the values are extracted from their first capture group.
+type WhoisMatcherRule struct {
+	Tld                           string `json:"tld" yaml:"tld"`                                                               // Tld is the TLD the rule applies to.
+	QueryOption                   string `json:"queryOption" yaml:"queryOption,omitempty"`                                     // QueryOption is the option sent before the domain in the query, such as "-T dn,ace".
+	Registrar                     string `json:"registrar" yaml:"registrar,omitempty"`                                         // Registrar matches the registrar name.
+	DomainStatus                  string `json:"domainStatus" yaml:"domainStatus,omitempty"`                                   // DomainStatus matches the domain status.
+	CreationDate                  string `json:"creationDate" yaml:"creationDate,omitempty"`                                   // CreationDate matches the creation date.
+	ExpiryDate                    string `json:"expiryDate" yaml:"expiryDate,omitempty"`                                       // ExpiryDate matches the expiration date.
+	NameServer                    string `json:"nameServer" yaml:"nameServer,omitempty"`                                       // NameServer matches the name server.
+	Free                          string `json:"free" yaml:"free,omitempty"`                                                   // Free matches the free status.
+	RateLimited                   string `json:"rateLimited" yaml:"rateLimited,omitempty"`                                     // RateLimited matches the rate limit or quota exceeded response.
+	Reserved                      string `json:"reserved" yaml:"reserved,omitempty"`                                           // Reserved matches the response of a domain reserved by the registry.
+	Premium                       string `json:"premium" yaml:"premium,omitempty"`                                             // Premium matches the response of a domain available at a premium price.
+	Blocked                       string `json:"blocked" yaml:"blocked,omitempty"`                                             // Blocked matches the response of a domain blocked by the registry.
+	RegistrantOrganization        string `json:"registrantOrganization" yaml:"registrantOrganization,omitempty"`               // RegistrantOrganization matches the registrant organisation.
+	RegistrarAbuseEmail           string `json:"registrarAbuseEmail" yaml:"registrarAbuseEmail,omitempty"`                     // RegistrarAbuseEmail matches the registrar abuse contact email.
+	RegistrarAbusePhone           string `json:"registrarAbusePhone" yaml:"registrarAbusePhone,omitempty"`                     // RegistrarAbusePhone matches the registrar abuse contact phone.
+	UpdatedDate                   string `json:"updatedDate" yaml:"updatedDate,omitempty"`                                     // UpdatedDate matches the last update date.
+	RegistrarIanaId               string `json:"registrarIanaId" yaml:"registrarIanaId,omitempty"`                             // RegistrarIanaId matches the registrar IANA ID.
+	RegistrantCountry             string `json:"registrantCountry" yaml:"registrantCountry,omitempty"`                         // RegistrantCountry matches the registrant country.
+	Dnssec                        string `json:"dnssec" yaml:"dnssec,omitempty"`                                               // Dnssec matches the DNSSEC delegation status.
+	DsData                        string `json:"dsData" yaml:"dsData,omitempty"`                                               // DsData matches the DS records.
+	DateTimeLayout                string `json:"dateTimeLayout" yaml:"dateTimeLayout,omitempty"`                               // DateTimeLayout is the layout of the date and time strings.
+	DateTimeLayoutForCreationDate string `json:"dateTimeLayoutForCreationDate" yaml:"dateTimeLayoutForCreationDate,omitempty"` // DateTimeLayoutForCreationDate is the layout of the creation date strings.
+	DateTimeLayoutForExpiryDate   string `json:"dateTimeLayoutForExpiryDate" yaml:"dateTimeLayoutForExpiryDate,omitempty"`     // DateTimeLayoutForExpiryDate is the layout of the expiration date strings.
+	Charset                       string `json:"charset" yaml:"charset,omitempty"`                                             // Charset is the character set of the responses, such as "gbk", empty to detect it.
+}
+
+// WhoisRules is the content of the WHOIS matcher rules file.
+// The rule of a TLD replaces its built-in matcher and query option, the other TLDs keep the built-in ones.
+type WhoisRules struct {
+	Rules []WhoisMatcherRule `json:"rules" yaml:"rules"`
+}
+
+// whoisRuleSet is the matchers and query options in use, it is replaced as a whole when the rules are updated.
+type whoisRuleSet struct {
+	rules    WhoisRules
+	matchers map[string]WhoisInfoMatcher
+	options  map[string]string
+}
+
+// whoisRulesFileStamp identifies a version of the rules file by its modification time and size.
+type whoisRulesFileStamp struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+}
+
+var (
+	currentRuleSet atomic.Pointer[whoisRuleSet]
+
+	// whoisRulesMux serializes the updates of the rules file.
+	whoisRulesMux sync.Mutex
+
+	// loadedRulesStamp is the stamp of the rules file last loaded or saved, protected by whoisRulesMux.
+	loadedRulesStamp whoisRulesFileStamp
+)
+
+func init() {
+	currentRuleSet.Store(buildRuleSet(WhoisRules{}, nil))
+
+	err := LoadWhoisRules()
+	if err != nil {
+		log.Errorf("Failed to load whois rules, using the built-in rules: %s", err)
+	}
+}
+
+// getWhoisMatcher returns the matcher in use for the TLD.
+func getWhoisMatcher(tld string) (WhoisInfoMatcher, bool) {
+	matcher, ok := currentRuleSet.Load().matchers[tld]
+	return matcher, ok
+}
+
+// getWhoisTldOption returns the query option in use for the TLD.
+func getWhoisTldOption(tld string) (string, bool) {
+	option, ok := currentRuleSet.Load().options[tld]
+	return option, ok
+}
+
+// GetWhoisRules returns the rules loaded from the rules file.
+func GetWhoisRules() WhoisRules {
+	return currentRuleSet.Load().rules
+}
+
+// BuiltinWhoisRules returns the built-in matchers and query options as rules sorted by TLD,
+// so they can be copied into the rules file to be customized.
+func BuiltinWhoisRules() []WhoisMatcherRule {
+	rules := make([]WhoisMatcherRule, 0, len(WhoisMatchers))
+	for tld, matcher := range WhoisMatchers {
+		rules = append(rules, WhoisMatcherRule{
+			Tld:                           tld,
+			QueryOption:                   WhoisTldOptions[tld],
+			Registrar:                     regexpString(matcher.ReRegistrar),
+			DomainStatus:                  regexpString(matcher.ReDomainStatus),
+			CreationDate:                  regexpString(matcher.ReCreationDate),
+			ExpiryDate:                    regexpString(matcher.ReExpiryDate),
+			NameServer:                    regexpString(matcher.ReNameServer),
+			Free:                          regexpString(matcher.ReFree),
+			RateLimited:                   regexpString(matcher.ReRateLimited),
+			Reserved:                      regexpString(matcher.ReReserved),
+			Premium:                       regexpString(matcher.RePremium),
+			Blocked:                       regexpString(matcher.ReBlocked),
+			RegistrantOrganization:        regexpString(matcher.ReRegistrantOrganization),
+			RegistrarAbuseEmail:           regexpString(matcher.ReRegistrarAbuseEmail),
+			RegistrarAbusePhone:           regexpString(matcher.ReRegistrarAbusePhone),
+			UpdatedDate:                   regexpString(matcher.ReUpdatedDate),
+			RegistrarIanaId:               regexpString(matcher.ReRegistrarIanaId),
+			RegistrantCountry:             regexpString(matcher.ReRegistrantCountry),
+			Dnssec:                        regexpString(matcher.ReDnssec),
+			DsData:                        regexpString(matcher.ReDsData),
+			DateTimeLayout:                matcher.DateTimeLayout,
+			DateTimeLayoutForCreationDate: matcher.DateTimeLayoutForCreationDate,
+			DateTimeLayoutForExpiryDate:   matcher.DateTimeLayoutForExpiryDate,
+			Charset:                       matcher.Charset,
+		})
+	}
+
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].Tld < rules[j].Tld
+	})
+
+	return rules
+}
+
+// ParseWhoisRules parses the rules from YAML or JSON data.
+func ParseWhoisRules(data []byte) (WhoisRules, error) {
+	var rules WhoisRules
+	err := yaml.Unmarshal(data, &rules)
+	if err != nil {
+		return rules, fmt.Errorf("%w: %s", ErrorInvalidWhoisRules, err)
+	}
+	return rules, nil
+}
+
+// LoadWhoisRules reads the rules file and puts its rules in use, only the built-in rules are used if the file does not exist.
+// The rules in use are kept if the file is invalid.
+func LoadWhoisRules() error {
+	whoisRulesMux.Lock()
+	defer whoisRulesMux.Unlock()
+
+	rulesFile := whoisRulesFile()
+
+	// The stamp is taken before reading, so a change made while reading is seen by the next check
+	loadedRulesStamp = statWhoisRulesFile(rulesFile)
+
+	data, err := os.ReadFile(rulesFile)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Debugf("Whois rules file %s not found, using the built-in rules", rulesFile)
+		currentRuleSet.Store(buildRuleSet(WhoisRules{}, nil))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	rules, err := ParseWhoisRules(data)
+	if err != nil {
+		return err
+	}
+
+	matchers, err := compileWhoisRules(&rules)
+	if err != nil {
+		return err
+	}
+
+	currentRuleSet.Store(buildRuleSet(rules, matchers))
+	log.Infof("Loaded %d whois rules from %s", len(rules.Rules), rulesFile)
+
+	return nil
+}
+
+// UpdateWhoisRules validates the rules, saves them to the rules file and puts them in use.
+// The rules in use are kept if the rules are invalid or can not be saved.
+func UpdateWhoisRules(rules WhoisRules) error {
+	whoisRulesMux.Lock()
+	defer whoisRulesMux.Unlock()
+
+	matchers, err := compileWhoisRules(&rules)
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(rules)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so the rules file is never left half written
+	rulesFile := whoisRulesFile()
+	tmpFile := rulesFile + ".tmp"
+	err = os.WriteFile(tmpFile, data, 0644)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmpFile, rulesFile)
+	if err != nil {
+		os.Remove(tmpFile)
+		return err
+	}
+	loadedRulesStamp = statWhoisRulesFile(rulesFile)
+
+	currentRuleSet.Store(buildRuleSet(rules, matchers))
+	log.Infof("Updated %d whois rules in %s", len(rules.Rules), rulesFile)
+
+	return nil
+}
+
+// WatchWhoisRules checks the rules file for changes until the context is canceled, and reloads the rules when it changes.
+// An invalid file is logged and the rules in use are kept, the file is loaded again once it changes again.
+func WatchWhoisRules(ctx context.Context) {
+	ticker := time.NewTicker(whoisRulesPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloadChangedWhoisRules()
+		}
+	}
+}
+
+// reloadChangedWhoisRules reloads the rules if the rules file was changed, created or removed since it was last loaded or saved.
+// It reports whether the file was changed, and returns the error of loading it.
+func reloadChangedWhoisRules() (bool, error) {
+	rulesFile := whoisRulesFile()
+
+	whoisRulesMux.Lock()
+	changed := statWhoisRulesFile(rulesFile) != loadedRulesStamp
+	whoisRulesMux.Unlock()
+	if !changed {
+		return false, nil
+	}
+
+	log.Infof("Whois rules file %s changed, reloading the rules", rulesFile)
+	err := LoadWhoisRules()
+	if err != nil {
+		log.Errorf("Failed to reload the changed whois rules, keeping the rules in use: %s", err)
+	}
+	return true, err
+}
+
+// statWhoisRulesFile returns the stamp of the rules file, the zero stamp if it does not exist.
+func statWhoisRulesFile(rulesFile string) whoisRulesFileStamp {
+	info, err := os.Stat(rulesFile)
+	if err != nil {
+		return whoisRulesFileStamp{}
+	}
+	return whoisRulesFileStamp{exists: true, modTime: info.ModTime(), size: info.Size()}
+}
+
+// compileWhoisRules normalizes the TLDs of the rules and compiles them into matchers.
+// All problems of the rules are returned together, wrapped in ErrorInvalidWhoisRules.
+func compileWhoisRules(rules *WhoisRules) (map[string]WhoisInfoMatcher, error) {
+	matchers := make(map[string]WhoisInfoMatcher, len(rules.Rules))
+	var problems []error
+
+	for i := range rules.Rules {
+		rule := &rules.Rules[i]
+		rule.Tld = strings.ToLower(strings.Trim(strings.TrimSpace(rule.Tld), "."))
+		rule.QueryOption = strings.TrimSpace(rule.QueryOption)
+		rule.Charset = strings.ToLower(strings.TrimSpace(rule.Charset))
+
+		if rule.Tld == "" {
+			problems = append(problems, fmt.Errorf("rule %d: tld is required", i+1))
+			continue
+		}
+		if _, ok := matchers[rule.Tld]; ok {
+			problems = append(problems, fmt.Errorf("rule %d (%s): duplicated tld", i+1, rule.Tld))
+			continue
+		}
+		if rule.Registrar == "" && rule.CreationDate == "" && rule.ExpiryDate == "" && rule.NameServer == "" {
+			problems = append(problems, fmt.Errorf("rule %d (%s): at least one of registrar, creationDate, expiryDate and nameServer is required", i+1, rule.Tld))
+			continue
+		}
+
+		matcher, err := compileWhoisRule(*rule)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("rule %d (%s): %w", i+1, rule.Tld, err))
+			continue
+		}
+		matchers[rule.Tld] = matcher
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%w: %w", ErrorInvalidWhoisRules, errors.Join(problems...))
+	}
+
+	return matchers, nil
+}
+
+// compileWhoisRule compiles the regular expressions of the rule into a matcher.
+func compileWhoisRule(rule WhoisMatcherRule) (WhoisInfoMatcher, error) {
+	matcher := WhoisInfoMatcher{
+		DateTimeLayout:                rule.DateTimeLayout,
+		DateTimeLayoutForCreationDate: rule.DateTimeLayoutForCreationDate,
+		DateTimeLayoutForExpiryDate:   rule.DateTimeLayoutForExpiryDate,
+		Charset:                       rule.Charset,
+	}
+
+	// The regular expressions extracting a value need a capture group, the free, rate limit, reserved, premium and blocked patterns only need to match
+	patterns := []struct {
+		name      string
+		pattern   string
+		target    **regexp.Regexp
+		needGroup bool
+	}{
+		{"registrar", rule.Registrar, &matcher.ReRegistrar, true},
+		{"domainStatus", rule.DomainStatus, &matcher.ReDomainStatus, true},
+		{"creationDate", rule.CreationDate, &matcher.ReCreationDate, true},
+		{"expiryDate", rule.ExpiryDate, &matcher.ReExpiryDate, true},
+		{"nameServer", rule.NameServer, &matcher.ReNameServer, true},
+		{"free", rule.Free, &matcher.ReFree, false},
+		{"rateLimited", rule.RateLimited, &matcher.ReRateLimited, false},
+		{"reserved", rule.Reserved, &matcher.ReReserved, false},
+		{"premium", rule.Premium, &matcher.RePremium, false},
+		{"blocked", rule.Blocked, &matcher.ReBlocked, false},
+		{"registrantOrganization", rule.RegistrantOrganization, &matcher.ReRegistrantOrganization, true},
+		{"registrarAbuseEmail", rule.RegistrarAbuseEmail, &matcher.ReRegistrarAbuseEmail, true},
+		{"registrarAbusePhone", rule.RegistrarAbusePhone, &matcher.ReRegistrarAbusePhone, true},
+		{"updatedDate", rule.UpdatedDate, &matcher.ReUpdatedDate, true},
+		{"registrarIanaId", rule.RegistrarIanaId, &matcher.ReRegistrarIanaId, true},
+		{"registrantCountry", rule.RegistrantCountry, &matcher.ReRegistrantCountry, true},
+		{"dnssec", rule.Dnssec, &matcher.ReDnssec, true},
+		{"dsData", rule.DsData, &matcher.ReDsData, true},
+	}
+
+	var problems []error
+	if rule.Charset != "" {
+		if _, err := getCharset(rule.Charset); err != nil {
+			problems = append(problems, fmt.Errorf("charset: %w", err))
+		}
+	}
+
+	for _, p := range patterns {
+		if p.pattern == "" {
+			continue
+		}
+
+		re, err := regexp.Compile(p.pattern)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s: %w", p.name, err))
+			continue
+		}
+		if p.needGroup && re.NumSubexp() < 1 {
+			problems = append(problems, fmt.Errorf("%s: a capture group is required", p.name))
+			continue
+		}
+		*p.target = re
+	}
+
+	return matcher, errors.Join(problems...)
+}
+
+// buildRuleSet puts the compiled rules over the built-in matchers and query options.
+func buildRuleSet(rules WhoisRules, ruleMatchers map[string]WhoisInfoMatcher) *whoisRuleSet {
+	ruleSet := &whoisRuleSet{
+		rules:    rules,
+		matchers: make(map[string]WhoisInfoMatcher, len(WhoisMatchers)+len(ruleMatchers)),
+		options:  make(map[string]string, len(WhoisTldOptions)+len(ruleMatchers)),
+	}
+
+	for tld, matcher := range WhoisMatchers {
+		ruleSet.matchers[tld] = matcher
+	}
+	for tld, option := range WhoisTldOptions {
+		ruleSet.options[tld] = option
+	}
+
+	for _, rule := range rules.Rules {
+		matcher, ok := ruleMatchers[rule.Tld]
+		if !ok {
+			continue
+		}
+		ruleSet.matchers[rule.Tld] = matcher
+
+		delete(ruleSet.options, rule.Tld)
+		if rule.QueryOption != "" {
+			ruleSet.options[rule.Tld] = rule.QueryOption
+		}
+	}
+
+	return ruleSet
+}
+
+// whoisRulesFile returns the path of the rules file.
+func whoisRulesFile() string {
+	return filepath.Join(config.GetConfigDir(), whoisRulesFileName)
+}
+
+// regexpString returns the source of the regular expression, or an empty string if it is nil.
+func regexpString(re *regexp.Regexp) string {
+	if re == nil {
+		return ""
+	}
+	return re.String()
+}
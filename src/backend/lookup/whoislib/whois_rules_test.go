@@ -0,0 +1,153 @@
+package whoislib
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+const (
+	// validRulesYaml is a rules file with a rule for the .rulestest TLD.
+	validRulesYaml = `rules:
+  - tld: rulestest
+    queryOption: "-T dn"
+    registrar: 'Registrar:\s+(.*)'
+`
+
+	// updatedRulesYaml is validRulesYaml with a name server pattern added.
+	updatedRulesYaml = `rules:
+  - tld: rulestest
+    queryOption: "-T dn"
+    registrar: 'Registrar:\s+(.*)'
+    nameServer: 'Name Server:\s+(.*)'
+`
+)
+
+// writeTestRulesFile writes the rules file for the test, and removes it and reloads the built-in rules after it.
+func writeTestRulesFile(t *testing.T, data string) {
+	t.Helper()
+
+	if err := os.WriteFile(whoisRulesFile(), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Remove(whoisRulesFile())
+		LoadWhoisRules()
+	})
+}
+
+func TestLoadWhoisRulesRejectsInvalidFile(t *testing.T) {
+	writeTestRulesFile(t, validRulesYaml)
+	if err := LoadWhoisRules(); err != nil {
+		t.Fatalf("LoadWhoisRules() error = %s", err)
+	}
+	loadedRuleSet := currentRuleSet.Load()
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"yaml syntax", "rules: [\n  - tld: rulestest\n"},
+		{"invalid regexp", "rules:\n  - tld: rulestest\n    registrar: 'Registrar:\\s+(.*'\n"},
+		{"missing capture group", "rules:\n  - tld: rulestest\n    registrar: 'Registrar:'\n"},
+		{"missing tld", "rules:\n  - registrar: 'Registrar:\\s+(.*)'\n"},
+		{"duplicated tld", validRulesYaml + "  - tld: rulestest\n    registrar: 'Registrar:\\s+(.*)'\n"},
+		{"unknown charset", "rules:\n  - tld: rulestest\n    registrar: 'Registrar:\\s+(.*)'\n    charset: no-such-charset\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(whoisRulesFile(), []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := LoadWhoisRules()
+			if !errors.Is(err, ErrorInvalidWhoisRules) {
+				t.Fatalf("LoadWhoisRules() error = %v, want %s", err, ErrorInvalidWhoisRules)
+			}
+			if currentRuleSet.Load() != loadedRuleSet {
+				t.Error("the rule set in use was replaced by an invalid rules file")
+			}
+			if option, ok := getWhoisTldOption("rulestest"); !ok || option != "-T dn" {
+				t.Errorf("query option = %q, want the option of the rules in use", option)
+			}
+		})
+	}
+}
+
+func TestUpdateWhoisRulesRejectsInvalidRules(t *testing.T) {
+	writeTestRulesFile(t, validRulesYaml)
+	if err := LoadWhoisRules(); err != nil {
+		t.Fatalf("LoadWhoisRules() error = %s", err)
+	}
+	loadedRuleSet := currentRuleSet.Load()
+
+	err := UpdateWhoisRules(WhoisRules{Rules: []WhoisMatcherRule{{Tld: "rulestest", Registrar: "("}}})
+	if !errors.Is(err, ErrorInvalidWhoisRules) {
+		t.Fatalf("UpdateWhoisRules() error = %v, want %s", err, ErrorInvalidWhoisRules)
+	}
+	if currentRuleSet.Load() != loadedRuleSet {
+		t.Error("the rule set in use was replaced by invalid rules")
+	}
+
+	data, err := os.ReadFile(whoisRulesFile())
+	if err != nil || string(data) != validRulesYaml {
+		t.Errorf("rules file = %q, %v, want the file left unchanged", data, err)
+	}
+}
+
+func TestReloadChangedWhoisRules(t *testing.T) {
+	writeTestRulesFile(t, validRulesYaml)
+
+	changed, err := reloadChangedWhoisRules()
+	if !changed || err != nil {
+		t.Fatalf("reloadChangedWhoisRules() = %t, %v for a new rules file, want true, nil", changed, err)
+	}
+	if matcher, ok := getWhoisMatcher("rulestest"); !ok || matcher.ReRegistrar == nil {
+		t.Fatal("rule of the new rules file not in use")
+	}
+
+	if changed, _ := reloadChangedWhoisRules(); changed {
+		t.Error("reloadChangedWhoisRules() = true for an unchanged rules file")
+	}
+
+	// A changed file is reloaded
+	if err := os.WriteFile(whoisRulesFile(), []byte(updatedRulesYaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	changed, err = reloadChangedWhoisRules()
+	if !changed || err != nil {
+		t.Fatalf("reloadChangedWhoisRules() = %t, %v for a changed rules file, want true, nil", changed, err)
+	}
+	if matcher, _ := getWhoisMatcher("rulestest"); matcher.ReNameServer == nil {
+		t.Fatal("rule of the changed rules file not in use")
+	}
+	loadedRuleSet := currentRuleSet.Load()
+
+	// A broken file is rejected once, and the rules in use are kept
+	if err := os.WriteFile(whoisRulesFile(), []byte("rules:\n  - tld: rulestest\n    registrar: '('\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	changed, err = reloadChangedWhoisRules()
+	if !changed || !errors.Is(err, ErrorInvalidWhoisRules) {
+		t.Fatalf("reloadChangedWhoisRules() = %t, %v for a broken rules file, want true, %s", changed, err, ErrorInvalidWhoisRules)
+	}
+	if currentRuleSet.Load() != loadedRuleSet {
+		t.Error("the rule set in use was replaced by a broken rules file")
+	}
+	if changed, _ := reloadChangedWhoisRules(); changed {
+		t.Error("reloadChangedWhoisRules() = true for a broken rules file already rejected")
+	}
+
+	// A removed file brings the built-in rules back
+	if err := os.Remove(whoisRulesFile()); err != nil {
+		t.Fatal(err)
+	}
+	changed, err = reloadChangedWhoisRules()
+	if !changed || err != nil {
+		t.Fatalf("reloadChangedWhoisRules() = %t, %v for a removed rules file, want true, nil", changed, err)
+	}
+	if _, ok := getWhoisMatcher("rulestest"); ok {
+		t.Error("rule of the removed rules file still in use")
+	}
+}
@@ -0,0 +1,340 @@
+package whoislib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"typonamer/config"
+	"typonamer/log"
+	"typonamer/lookup/lookuperror"
+
+	"github.com/duke-git/lancet/v2/slice"
+)
+
+const (
+	// whoisSelectionRoundRobin selects the servers of a TLD in turn, it is the default selection.
+	whoisSelectionRoundRobin = "roundRobin"
+
+	// whoisSelectionLeastLatency selects the server of a TLD with the lowest recent latency.
+	whoisSelectionLeastLatency = "leastLatency"
+
+	// defaultWhoisServerMaxFailures is the number of consecutive failures ejecting a server if none is configured.
+	defaultWhoisServerMaxFailures = 3
+
+	// defaultWhoisServerEjectTime is the time a failing server is ejected for if none is configured.
+	defaultWhoisServerEjectTime = time.Minute
+
+	// defaultWhoisServerResolveTimeout is the timeout of resolving a WHOIS server if no DNS timeout is configured.
+	defaultWhoisServerResolveTimeout = 3 * time.Second
+
+	// whoisServerResolveTtl is the time the resolved addresses of a WHOIS server are used before resolving it again.
+	whoisServerResolveTtl = 5 * time.Minute
+
+	// latencySmoothing is the weight of the latest latency in the moving average latency of a server.
+	latencySmoothing = 0.3
+)
+
+// whoisFailureKinds are the kinds of failures counted against the health of a server.
+// The rate limits have their own cool-down, and the proxy and cancellation failures are not caused by the server.
+var whoisFailureKinds = []lookuperror.Kind{
+	lookuperror.KindTimeout,
+	lookuperror.KindServerFailure,
+	lookuperror.KindEmptyResponse,
+}
+
+// whoisEndpoint is an address of a WHOIS server.
+type whoisEndpoint struct {
+	server  string // server is the WHOIS server as configured, such as whois.nic.io or whois.example.com:4343.
+	address string // address is the address dialed, such as 65.22.160.17:43.
+}
+
+// String returns the server with the IP address dialed, such as "whois.nic.io (65.22.160.17)".
+func (e whoisEndpoint) String() string {
+	host, _, err := net.SplitHostPort(e.address)
+	serverHost, _, _ := net.SplitHostPort(whoisServerAddress(e.server))
+	if err != nil || strings.EqualFold(host, serverHost) {
+		return e.server
+	}
+	return fmt.Sprintf("%s (%s)", e.server, host)
+}
+
+// endpointHealth is the passive health of an address of a WHOIS server, tracked from the results of the queries to it.
+type endpointHealth struct {
+	endpoint            whoisEndpoint
+	useProxy            bool
+	latency             time.Duration // latency is the moving average latency of the successful queries.
+	consecutiveFailures int           // consecutiveFailures is the number of failed queries since the last successful one.
+	lastError           string
+	ejectedUntil        time.Time // ejectedUntil is the end of the ejection of the address, zero if it is not ejected.
+}
+
+// resolvedServer is the addresses a WHOIS server host resolved to.
+type resolvedServer struct {
+	addresses []string
+	expiresAt time.Time
+}
+
+// WhoisServerStatus is the health of an address of a WHOIS server.
+type WhoisServerStatus struct {
+	Server              string `json:"server"`              // Server is the WHOIS server.
+	Address             string `json:"address"`             // Address is the address of the server dialed.
+	ViaProxy            bool   `json:"viaProxy"`            // ViaProxy is the flag to indicate if the health is of the queries via proxy.
+	LatencyMs           int64  `json:"latencyMs"`           // LatencyMs is the moving average latency of the successful queries in milliseconds.
+	ConsecutiveFailures int    `json:"consecutiveFailures"` // ConsecutiveFailures is the number of failed queries since the last successful one.
+	LastError           string `json:"lastError"`           // LastError is the error of the last failed query.
+	EjectedUntil        string `json:"ejectedUntil"`        // EjectedUntil is the end of the ejection of the address, empty if it is not ejected.
+}
+
+var (
+	// endpointHealths holds the health of the addresses of the WHOIS servers queried.
+	endpointHealths    = make(map[string]*endpointHealth)
+	endpointHealthsMux sync.Mutex
+
+	// resolvedServers caches the addresses of the WHOIS server hosts.
+	resolvedServers    = make(map[string]resolvedServer)
+	resolvedServersMux sync.Mutex
+
+	// roundRobinCounters holds the number of selections of each TLD for the round-robin selection.
+	roundRobinCounters sync.Map
+)
+
+// getWhoisServers returns the WHOIS servers of the TLD, the configured servers replace the built-in ones.
+// ok is false if no server is known for the TLD.
+func getWhoisServers(tld string) ([]string, bool) {
+	for _, tldServers := range config.GetConfig().TldWhoisServers {
+		if tldServers.Tld == tld && len(tldServers.Servers) > 0 {
+			return tldServers.Servers, true
+		}
+	}
+
+	server, ok := WhoisSupportedTlds[tld]
+	if !ok {
+		return nil, false
+	}
+	return append([]string{server}, WhoisAlternateServers[tld]...), true
+}
+
+// selectWhoisEndpoint selects the address to query among the addresses of the WHOIS servers of the TLD.
+// The servers cooling down from a rate limit and the ejected addresses are skipped, if every address is ejected
+// the one whose ejection ends first is selected, so the TLD is still queried.
+// ok is false if every server is cooling down, in which case the end of the first cool-down is returned.
+func selectWhoisEndpoint(ctx context.Context, tld string, servers []string, useProxy bool) (whoisEndpoint, time.Time, bool) {
+	var endpoints []whoisEndpoint
+	var coolDownEnd time.Time
+	for _, server := range servers {
+		if until, coolingDown := serverCoolingDown(server, useProxy); coolingDown {
+			if coolDownEnd.IsZero() || until.Before(coolDownEnd) {
+				coolDownEnd = until
+			}
+			continue
+		}
+		endpoints = append(endpoints, resolveWhoisServer(ctx, server)...)
+	}
+	if len(endpoints) == 0 {
+		return whoisEndpoint{}, coolDownEnd, false
+	}
+	if len(endpoints) == 1 {
+		return endpoints[0], time.Time{}, true
+	}
+
+	now := time.Now()
+
+	endpointHealthsMux.Lock()
+	defer endpointHealthsMux.Unlock()
+
+	available := slice.Filter(endpoints, func(_ int, endpoint whoisEndpoint) bool {
+		health, ok := endpointHealths[healthKey(endpoint, useProxy)]
+		return !ok || !now.Before(health.ejectedUntil)
+	})
+	if len(available) == 0 {
+		sort.SliceStable(endpoints, func(i, j int) bool {
+			return endpointHealths[healthKey(endpoints[i], useProxy)].ejectedUntil.Before(endpointHealths[healthKey(endpoints[j], useProxy)].ejectedUntil)
+		})
+		log.Warnf("All whois servers of TLD %s are ejected, querying %s", tld, endpoints[0])
+		return endpoints[0], time.Time{}, true
+	}
+
+	if config.GetConfig().WhoisServerSelection == whoisSelectionLeastLatency {
+		// The addresses without a latency yet are selected first, so every address gets measured
+		selected := available[0]
+		selectedLatency := endpointLatency(selected, useProxy)
+		for _, endpoint := range available[1:] {
+			if latency := endpointLatency(endpoint, useProxy); latency < selectedLatency {
+				selected, selectedLatency = endpoint, latency
+			}
+		}
+		return selected, time.Time{}, true
+	}
+
+	// The round-robin selection is the default one
+	counter, _ := roundRobinCounters.LoadOrStore(tld, &atomic.Uint64{})
+	next := counter.(*atomic.Uint64).Add(1) - 1
+	return available[next%uint64(len(available))], time.Time{}, true
+}
+
+// endpointLatency returns the moving average latency of the address, zero if it has no successful query yet.
+// The caller must hold endpointHealthsMux.
+func endpointLatency(endpoint whoisEndpoint, useProxy bool) time.Duration {
+	if health, ok := endpointHealths[healthKey(endpoint, useProxy)]; ok {
+		return health.latency
+	}
+	return 0
+}
+
+// recordWhoisResult updates the health of the address from the result of a query to it.
+// The address is ejected for the configured time after the configured number of consecutive failures.
+func recordWhoisResult(endpoint whoisEndpoint, useProxy bool, latency time.Duration, err error) {
+	var lookupErr *lookuperror.LookupError
+	if err != nil && (!errors.As(err, &lookupErr) || !slice.Contain(whoisFailureKinds, lookupErr.Kind)) {
+		return
+	}
+
+	cfg := config.GetConfig()
+
+	endpointHealthsMux.Lock()
+	defer endpointHealthsMux.Unlock()
+
+	key := healthKey(endpoint, useProxy)
+	health, ok := endpointHealths[key]
+	if !ok {
+		health = &endpointHealth{endpoint: endpoint, useProxy: useProxy}
+		endpointHealths[key] = health
+	}
+
+	if err == nil {
+		if health.latency == 0 {
+			health.latency = latency
+		} else {
+			health.latency = time.Duration(latencySmoothing*float64(latency) + (1-latencySmoothing)*float64(health.latency))
+		}
+		health.consecutiveFailures = 0
+		health.ejectedUntil = time.Time{}
+		return
+	}
+
+	health.consecutiveFailures++
+	health.lastError = err.Error()
+
+	maxFailures := cfg.WhoisServerMaxFailures
+	if maxFailures <= 0 {
+		maxFailures = defaultWhoisServerMaxFailures
+	}
+	if health.consecutiveFailures < maxFailures {
+		return
+	}
+
+	ejectTime := time.Duration(cfg.WhoisServerEjectTime) * time.Second
+	if ejectTime <= 0 {
+		ejectTime = defaultWhoisServerEjectTime
+	}
+	health.ejectedUntil = time.Now().Add(ejectTime)
+
+	log.Warnf("Whois server %s failed %d times in a row (via proxy: %t), ejecting it until %s", endpoint, health.consecutiveFailures, useProxy, health.ejectedUntil.Format(time.DateTime))
+}
+
+// WhoisServerStatuses returns the health of the addresses of the WHOIS servers queried, sorted by server.
+func WhoisServerStatuses() []WhoisServerStatus {
+	endpointHealthsMux.Lock()
+	defer endpointHealthsMux.Unlock()
+
+	now := time.Now()
+	statuses := make([]WhoisServerStatus, 0, len(endpointHealths))
+	for _, health := range endpointHealths {
+		status := WhoisServerStatus{
+			Server:              health.endpoint.server,
+			Address:             health.endpoint.address,
+			ViaProxy:            health.useProxy,
+			LatencyMs:           health.latency.Milliseconds(),
+			ConsecutiveFailures: health.consecutiveFailures,
+			LastError:           health.lastError,
+		}
+		if now.Before(health.ejectedUntil) {
+			status.EjectedUntil = health.ejectedUntil.Format(time.DateTime)
+		}
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Server != statuses[j].Server {
+			return statuses[i].Server < statuses[j].Server
+		}
+		if statuses[i].Address != statuses[j].Address {
+			return statuses[i].Address < statuses[j].Address
+		}
+		return !statuses[i].ViaProxy && statuses[j].ViaProxy
+	})
+
+	return statuses
+}
+
+// resolveWhoisServer returns the addresses of the WHOIS server, one for each IPv4 and IPv6 address of its host.
+// The server itself is returned if it is an IP address or its host can not be resolved, so it is resolved when dialed.
+func resolveWhoisServer(ctx context.Context, server string) []whoisEndpoint {
+	address := whoisServerAddress(server)
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || net.ParseIP(host) != nil {
+		return []whoisEndpoint{{server: server, address: address}}
+	}
+
+	ips, err := lookupWhoisServerHost(ctx, host)
+	if err != nil || len(ips) == 0 {
+		log.Debugf("Failed to resolve whois server %s, dialing it by name: %v", server, err)
+		return []whoisEndpoint{{server: server, address: address}}
+	}
+
+	endpoints := make([]whoisEndpoint, 0, len(ips))
+	for _, ip := range ips {
+		endpoints = append(endpoints, whoisEndpoint{server: server, address: net.JoinHostPort(ip, port)})
+	}
+	return endpoints
+}
+
+// lookupWhoisServerHost returns the IP addresses of the host, which are cached for whoisServerResolveTtl.
+func lookupWhoisServerHost(ctx context.Context, host string) ([]string, error) {
+	host = strings.ToLower(host)
+
+	resolvedServersMux.Lock()
+	resolved, ok := resolvedServers[host]
+	resolvedServersMux.Unlock()
+	if ok && time.Now().Before(resolved.expiresAt) {
+		return resolved.addresses, nil
+	}
+
+	resolveTimeout := time.Duration(config.GetConfig().DnsTimeout) * time.Second
+	if resolveTimeout <= 0 {
+		resolveTimeout = defaultWhoisServerResolveTimeout
+	}
+	resolveCtx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+
+	ipAddrs, err := net.DefaultResolver.LookupIPAddr(resolveCtx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := make([]string, 0, len(ipAddrs))
+	for _, ipAddr := range ipAddrs {
+		addresses = append(addresses, ipAddr.IP.String())
+	}
+
+	resolvedServersMux.Lock()
+	resolvedServers[host] = resolvedServer{addresses: addresses, expiresAt: time.Now().Add(whoisServerResolveTtl)}
+	resolvedServersMux.Unlock()
+
+	return addresses, nil
+}
+
+// healthKey returns the key of the health of the address, the queries via proxy are tracked apart from the direct queries.
+func healthKey(endpoint whoisEndpoint, useProxy bool) string {
+	if useProxy {
+		return strings.ToLower(endpoint.address) + "|proxy"
+	}
+	return strings.ToLower(endpoint.address)
+}
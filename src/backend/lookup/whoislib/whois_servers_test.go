@@ -0,0 +1,72 @@
+package whoislib
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"typonamer/config"
+	"typonamer/lookup/lookuperror"
+	"typonamer/lookup/whoislib/whoistest"
+)
+
+// startTestCnServer starts a WHOIS server of .cn answering every query with the response, and makes it the only server of .cn.
+// The health and the cool-down of the server are forgotten after the test.
+func startTestCnServer(t *testing.T, response string) whoisEndpoint {
+	t.Helper()
+
+	server, err := whoistest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	server.NotFoundResponse = response
+
+	setTestConfig(t, func(cfg *config.Config) {
+		cfg.TldWhoisServers = []config.TldWhoisServers{{Tld: "cn", Servers: []string{server.Addr()}}}
+	})
+
+	endpoint := whoisEndpoint{server: server.Addr(), address: server.Addr()}
+	t.Cleanup(func() {
+		endpointHealthsMux.Lock()
+		delete(endpointHealths, healthKey(endpoint, false))
+		endpointHealthsMux.Unlock()
+		coolDownsMux.Lock()
+		delete(coolDowns, coolDownKey(endpoint.server, false))
+		coolDownsMux.Unlock()
+	})
+	return endpoint
+}
+
+func TestWhoisQueryRecordsHealthAfterParsing(t *testing.T) {
+	t.Run("answered", func(t *testing.T) {
+		endpoint := startTestCnServer(t, "No matching record.\n")
+
+		_, err := WhoisQuery(context.Background(), "example.cn", "cn", false)
+		if !errors.Is(err, lookuperror.ErrorWhoisNotFound) {
+			t.Fatalf("WhoisQuery() error = %v, want %s", err, lookuperror.ErrorWhoisNotFound)
+		}
+
+		endpointHealthsMux.Lock()
+		defer endpointHealthsMux.Unlock()
+		if endpointLatency(endpoint, false) == 0 {
+			t.Error("latency of the server not recorded after a successful query")
+		}
+	})
+
+	t.Run("rate limited", func(t *testing.T) {
+		endpoint := startTestCnServer(t, "Queried interval is too short.\n")
+
+		_, err := WhoisQuery(context.Background(), "example.cn", "cn", false)
+		if !errors.Is(err, lookuperror.ErrorWhoisRateLimited) {
+			t.Fatalf("WhoisQuery() error = %v, want %s", err, lookuperror.ErrorWhoisRateLimited)
+		}
+
+		// The rate limited response is not a successful query, so it does not make the server look fast
+		endpointHealthsMux.Lock()
+		defer endpointHealthsMux.Unlock()
+		if latency := endpointLatency(endpoint, false); latency != 0 {
+			t.Errorf("latency of the server = %s after a rate limited query, want none recorded", latency)
+		}
+	})
+}
@@ -0,0 +1,139 @@
+// Package whoistest provides a local fake WHOIS server, which can act as an IANA-style server or a registry WHOIS server in tests.
+package whoistest
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Server is a fake WHOIS server listening on a local port.
+// It answers every query with the response registered for it, and with NotFoundResponse for the unknown queries.
+type Server struct {
+	// NotFoundResponse is the response of the queries without a registered response.
+	NotFoundResponse string
+
+	listener  net.Listener
+	responses map[string]string
+	queries   []string
+	mux       sync.RWMutex
+	wg        sync.WaitGroup
+}
+
+// NewServer starts a fake WHOIS server on a random local port.
+func NewServer() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on a local port: %w", err)
+	}
+
+	server := &Server{
+		NotFoundResponse: "% No match\n",
+		listener:         listener,
+		responses:        make(map[string]string),
+	}
+
+	server.wg.Add(1)
+	go server.serve()
+
+	return server, nil
+}
+
+// NewIanaServer starts a fake IANA WHOIS server, which refers every TLD of the referrals to its WHOIS server.
+func NewIanaServer(referrals map[string]string) (*Server, error) {
+	server, err := NewServer()
+	if err != nil {
+		return nil, err
+	}
+
+	server.NotFoundResponse = "% This query returned 0 objects.\n"
+	for tld, whoisServer := range referrals {
+		server.Handle(tld, IanaResponse(tld, whoisServer))
+	}
+
+	return server, nil
+}
+
+// IanaResponse returns the response of an IANA WHOIS server for the TLD referring to the WHOIS server.
+func IanaResponse(tld string, whoisServer string) string {
+	return fmt.Sprintf(`%% IANA WHOIS server
+%% for more information on IANA, visit http://www.iana.org
+
+refer:        %s
+
+domain:       %s
+
+organisation: Fake Registry
+
+whois:        %s
+
+status:       ACTIVE
+`, whoisServer, strings.ToUpper(tld), whoisServer)
+}
+
+// Addr returns the address of the server, which can be used as a WHOIS server address with the port.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Handle registers the response of the query, the query is matched without the trailing CRLF and case insensitively.
+func (s *Server) Handle(query string, response string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.responses[strings.ToLower(query)] = response
+}
+
+// Queries returns the queries received by the server in order.
+func (s *Server) Queries() []string {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	queries := make([]string, len(s.queries))
+	copy(queries, s.queries)
+	return queries
+}
+
+// Close stops the server and waits for the pending connections to finish.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.wg.Add(1)
+		go s.handleConn(conn)
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer conn.Close()
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && line == "" {
+		return
+	}
+	query := strings.TrimSpace(line)
+
+	s.mux.Lock()
+	s.queries = append(s.queries, query)
+	response, ok := s.responses[strings.ToLower(query)]
+	s.mux.Unlock()
+
+	if !ok {
+		response = s.NotFoundResponse
+	}
+
+	conn.Write([]byte(response))
+}
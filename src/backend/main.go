@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
@@ -8,6 +9,9 @@ import (
 	"typonamer/api"
 	"typonamer/config"
 	"typonamer/log"
+	"typonamer/lookup/dnslib"
+	"typonamer/lookup/rdaplib"
+	"typonamer/lookup/whoislib"
 
 	"github.com/dromara/carbon/v2"
 	"github.com/gofiber/contrib/socketio"
@@ -38,6 +42,11 @@ func init() {
 		Locale:       "zh-CN",
 	})
 
+	// exit if the config file can not be read
+	if config.LoadError() != nil {
+		os.Exit(1)
+	}
+
 	// init logger
 	// init log level
 	cfg := config.GetConfig()
@@ -74,6 +83,14 @@ func main() {
 	// Setup Fiber API Router
 	app.Route("/api", api.ApiRoute, "api.")
 
+	// ---------- Start Background Tasks ----------
+	// 监视Whois解析规则文件的变化
+	go whoislib.WatchWhoisRules(context.Background())
+	// 加载并刷新顶级域名的NS缓存和根服务器地址
+	go dnslib.RunTldNsCacheRefresher(context.Background())
+	// 首次启动时下载RDAP引导文件
+	go rdaplib.DownloadMissingRdapBootstrap(context.Background())
+
 	// ---------- Start Server ----------
 	if err := app.Listen(listenPort); err != nil {
 		panic(err)
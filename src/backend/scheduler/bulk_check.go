@@ -64,20 +64,21 @@ type BulkCheckDomain struct {
 }
 
 type BulkCheckStatusInfo struct {
-	Status        string
-	QueryType     string
-	TotalDomains  int64
-	RemainDomains int64
-	TakenDomains  int64
-	FreeDomains   int64
-	ErrorDomains  int64
+	Status          string
+	QueryType       string
+	TotalDomains    int64
+	RemainDomains   int64
+	TakenDomains    int64
+	FreeDomains     int64
+	ReservedDomains int64
+	ErrorDomains    int64
 }
 
 // init is the entry point of the batch task package.
 // It is responsible for initializing the package by connecting to Redis and initializing the batch task status.
 func init() {
 	// Get a Redis client
-	client, err := database.GetRedis()
+	client, err := database.GetSharedRedis()
 	if err != nil {
 		log.Error("Failed to connect to Redis: ", err)
 		// If there is an error connecting to Redis, exit the program.
@@ -196,6 +197,31 @@ func GetBulkCheckQueryType() string {
 	return queryType
 }
 
+// SetBulkCheckBypassCache sets whether the bulk check task bypasses the lookup cache.
+// The flag is saved to redis, so it is kept when the task is resumed.
+func SetBulkCheckBypassCache(bypassCache bool) error {
+	ctx := context.Background()
+
+	err := rdb.Set(ctx, constant.BulkCheckBypassCacheRedisKey, bypassCache, 0).Err()
+	if err != nil {
+		return errors.New("failed to set bypass cache flag")
+	}
+
+	log.Infof("Set bulk check bypass cache to: %t", bypassCache)
+
+	return nil
+}
+
+// GetBulkCheckBypassCache gets whether the bulk check task bypasses the lookup cache
+func GetBulkCheckBypassCache() bool {
+	ctx := context.Background()
+	bypassCache, _ := rdb.Get(ctx, constant.BulkCheckBypassCacheRedisKey).Bool()
+
+	log.Debugf("Got bulk check bypass cache: %t", bypassCache)
+
+	return bypassCache
+}
+
 // CreateBulkCheck creates a bulk check task by cleaning the bulk check task data,
 // unique raw domains and start the bulk check task.
 func CreateBulkCheckTask() {
@@ -463,18 +489,22 @@ func getBulkCheckInfo() (BulkCheckStatusInfo, error) {
 	// Get the free domains from redis
 	freeDomains := rdb.LLen(ctx, constant.BulkCheckFreeResultRedisKey).Val()
 
+	// Get the reserved, premium and blocked domains from redis
+	reservedDomains := rdb.LLen(ctx, constant.BulkCheckReservedResultRedisKey).Val()
+
 	// Get the error domains from redis
 	errorDomains := rdb.LLen(ctx, constant.BulkCheckErrorResultRedisKey).Val()
 
 	// Return the bulk check task info
 	return BulkCheckStatusInfo{
-		Status:        taskStatus,
-		QueryType:     queryType,
-		TotalDomains:  totalDomains,
-		RemainDomains: remainDomains,
-		TakenDomains:  takenDomains,
-		FreeDomains:   freeDomains,
-		ErrorDomains:  errorDomains,
+		Status:          taskStatus,
+		QueryType:       queryType,
+		TotalDomains:    totalDomains,
+		RemainDomains:   remainDomains,
+		TakenDomains:    takenDomains,
+		FreeDomains:     freeDomains,
+		ReservedDomains: reservedDomains,
+		ErrorDomains:    errorDomains,
 	}, nil
 }
 
@@ -584,6 +614,22 @@ func clearBulkCheckData() error {
 
 	log.Debug("Clean free result from redis: ", constant.BulkCheckFreeResultRedisKey)
 
+	// Clean the reserved result from redis
+	err = clearBulkCheckReservedResult()
+	if err != nil {
+		// If failed to clean the reserved result from redis,
+		// set the bulk check task status to error and send the error message to the websocket connections.
+		responseError := map[string]interface{}{
+			"event": constant.WebsocketResponseBulkCheckErrorEvent,
+			"data":  "服务端出现错误, 请检查服务端日志",
+		}
+		setBulkCheckStatus(constant.BulkCheckStatusError)
+		bulkCheckSendWsMessage([]byte(convertor.ToString(responseError)))
+		return err
+	}
+
+	log.Debug("Clean reserved result from redis: ", constant.BulkCheckReservedResultRedisKey)
+
 	// Clean the error result from redis
 	err = clearBulkCheckErrorResult()
 	if err != nil {
@@ -658,6 +704,17 @@ func clearBulkCheckFreeResult() error {
 	return nil
 }
 
+// clearBulkCheckReservedResult clears the reserved, premium and blocked result from redis.
+func clearBulkCheckReservedResult() error {
+	ctx := context.Background()
+	err := rdb.Del(ctx, constant.BulkCheckReservedResultRedisKey).Err()
+	if err != nil {
+		log.Errorf("Failed to clean reserved result from redis: %s", err)
+		return err
+	}
+	return nil
+}
+
 // clearBulkCheckErrorResult clears the error result from redis.
 func clearBulkCheckErrorResult() error {
 	ctx := context.Background()
@@ -813,6 +870,8 @@ func runBulkCheckTask() {
 		return
 	}
 
+	bypassCache := GetBulkCheckBypassCache()
+
 	cfg := config.GetConfig()
 
 	var concurrencyLimit int
@@ -858,12 +917,12 @@ func runBulkCheckTask() {
 
 	for i := 0; i < concurrencyLimit; i++ {
 		wg.Add(1)
-		go bulkCheckQueryHandler(i, ch, &wg, queryType)
+		go bulkCheckQueryHandler(ctx, i, ch, &wg, queryType, bypassCache)
 	}
 
 	for _, domainStr := range uniqueDomains {
 		select {
-		case <-bulkCheckVar.Ctx.Done():
+		case <-ctx.Done():
 			log.Infof("Force stop bulk check task")
 			close(ch)
 			return
@@ -890,14 +949,14 @@ func runBulkCheckTask() {
 
 // bulkCheckQueryHandler is a goroutine function that queries the domains in the given channel and sends the result to redis.
 // It also handles the cancellation of the context and the finish of the goroutine.
-func bulkCheckQueryHandler(i int, ch chan BulkCheckDomain, wg *sync.WaitGroup, queryType string) {
+func bulkCheckQueryHandler(ctx context.Context, i int, ch chan BulkCheckDomain, wg *sync.WaitGroup, queryType string, bypassCache bool) {
 	defer wg.Done()
 	handerSeq := i + 1
 	log.Debugf("Start bulk check handler %d", handerSeq)
 
 	for {
 		select {
-		case <-bulkCheckVar.Ctx.Done():
+		case <-ctx.Done():
 			log.Infof("Force stop bulk check handler %d", handerSeq)
 			return
 		default:
@@ -909,7 +968,12 @@ func bulkCheckQueryHandler(i int, ch chan BulkCheckDomain, wg *sync.WaitGroup, q
 
 			log.Debugf("Bulk check handler %d query domain %s", handerSeq, domainInfo.Domain)
 
-			lookupResult, err := lookuper.Lookup(domainInfo.Domain, queryType)
+			lookupResult, err := lookuper.CachedLookup(ctx, domainInfo.Domain, queryType, bypassCache)
+			if errors.Is(err, lookuperror.ErrorLookupCanceled) {
+				// Keep the domain in the unique domain list, so it will be queried again when the task is resumed
+				log.Infof("Bulk check handler %d canceled the query of domain %s", handerSeq, domainInfo.Domain)
+				return
+			}
 			bulkCheckLookupResultHandler(handerSeq, domainInfo, lookupResult, err)
 
 			// Delete the domain from the unique domain list
@@ -919,6 +983,8 @@ func bulkCheckQueryHandler(i int, ch chan BulkCheckDomain, wg *sync.WaitGroup, q
 }
 
 func bulkCheckLookupResultHandler(handerSeq int, domainInfo BulkCheckDomain, lookupResult lookupinfo.DomainInfo, lookupErr error) error {
+	registerStatus := utils.GetLookupRegisterStatus(lookupResult, lookupErr)
+
 	switch lookupResult.LookupType {
 	case constant.LookupTypeWhois, constant.LookupTypeRDAP:
 		if lookupErr == nil {
@@ -927,21 +993,26 @@ func bulkCheckLookupResultHandler(handerSeq int, domainInfo BulkCheckDomain, loo
 				Order:           domainInfo.Order,
 				Domain:          domainInfo.Domain,
 				LookupType:      lookupResult.LookupType,
+				FromCache:       lookupResult.FromCache,
+				CachedAt:        lookupResult.CachedAt,
+				FallbackFrom:    lookupResult.FallbackFrom,
+				Trace:           lookupResult.Trace,
 				ViaProxy:        lookupResult.ViaProxy,
-				RegisterStatus:  constant.DomainRegisterStatusTaken,
+				RegisterStatus:  registerStatus,
 				CreatedDate:     lookupResult.CreationDate,
 				ExpiryDate:      lookupResult.ExpiryDate,
 				NameServer:      slice.Map(lookupResult.NameServer, utils.LowerString),
 				DnsLite:         utils.GetDnsLite(lookupResult.NameServer),
 				RawDomainStatus: lookupResult.DomainStatus,
 				DomainStatus:    utils.GetDomainHumanStatus(lookupResult.DomainStatus),
+				Details:         utils.GetRegistrationDetails(lookupResult),
 			}
 
 			log.Debugf("Bulk check whois query of domain %s result: %+v", domainInfo.Domain, queryResult)
 
-			err := rdb.RPush(context.Background(), constant.BulkCheckTakenResultRedisKey, convertor.ToString(queryResult)).Err()
+			err := rdb.RPush(context.Background(), bulkCheckResultRedisKey(queryResult.RegisterStatus), convertor.ToString(queryResult)).Err()
 			if err != nil {
-				log.Warnf("Bulk check handler %d failed to save the whois taken result of domain %s to redis: %s", handerSeq, domainInfo.Domain, err)
+				log.Warnf("Bulk check handler %d failed to save the whois %s result of domain %s to redis: %s", handerSeq, queryResult.RegisterStatus, domainInfo.Domain, err)
 			}
 			return err
 		} else if errors.Is(lookupErr, lookuperror.ErrorWhoisNotFound) {
@@ -950,8 +1021,12 @@ func bulkCheckLookupResultHandler(handerSeq int, domainInfo BulkCheckDomain, loo
 				Order:          domainInfo.Order,
 				Domain:         domainInfo.Domain,
 				LookupType:     lookupResult.LookupType,
+				FromCache:      lookupResult.FromCache,
+				CachedAt:       lookupResult.CachedAt,
+				FallbackFrom:   lookupResult.FallbackFrom,
+				Trace:          lookupResult.Trace,
 				ViaProxy:       lookupResult.ViaProxy,
-				RegisterStatus: constant.DomainRegisterStatusFree,
+				RegisterStatus: registerStatus,
 			}
 
 			log.Debugf("Bulk check whois query of domain %s result is free", domainInfo.Domain)
@@ -967,8 +1042,12 @@ func bulkCheckLookupResultHandler(handerSeq int, domainInfo BulkCheckDomain, loo
 				Order:          domainInfo.Order,
 				Domain:         domainInfo.Domain,
 				LookupType:     lookupResult.LookupType,
+				FromCache:      lookupResult.FromCache,
+				CachedAt:       lookupResult.CachedAt,
+				FallbackFrom:   lookupResult.FallbackFrom,
+				Trace:          lookupResult.Trace,
 				ViaProxy:       lookupResult.ViaProxy,
-				RegisterStatus: constant.DomainRegisterStatusError,
+				RegisterStatus: registerStatus,
 				QueryError:     utils.GetDomainHumanError(lookupErr),
 			}
 			err := rdb.RPush(context.Background(), constant.BulkCheckErrorResultRedisKey, convertor.ToString(errorResult)).Err()
@@ -977,6 +1056,41 @@ func bulkCheckLookupResultHandler(handerSeq int, domainInfo BulkCheckDomain, loo
 			}
 			return err
 		}
+	case constant.LookupTypeVerify:
+		verifyResult := lookupinfo.QueryResult{
+			Order:          domainInfo.Order,
+			Domain:         domainInfo.Domain,
+			LookupType:     lookupResult.LookupType,
+			ViaProxy:       lookupResult.ViaProxy,
+			FromCache:      lookupResult.FromCache,
+			CachedAt:       lookupResult.CachedAt,
+			FallbackFrom:   lookupResult.FallbackFrom,
+			Trace:          lookupResult.Trace,
+			Conflict:       lookupResult.Conflict,
+			Sources:        lookupResult.Sources,
+			RegisterStatus: registerStatus,
+		}
+
+		if lookupErr == nil {
+			verifyResult.CreatedDate = lookupResult.CreationDate
+			verifyResult.ExpiryDate = lookupResult.ExpiryDate
+			verifyResult.NameServer = slice.Map(lookupResult.NameServer, utils.LowerString)
+			verifyResult.DnsLite = utils.GetDnsLite(lookupResult.NameServer)
+			verifyResult.RawDomainStatus = lookupResult.DomainStatus
+			verifyResult.DomainStatus = utils.GetDomainHumanStatus(lookupResult.DomainStatus)
+			verifyResult.Details = utils.GetRegistrationDetails(lookupResult)
+			verifyResult.DnsSignals = lookupResult.DnsSignals
+		} else {
+			verifyResult.QueryError = utils.GetDomainHumanError(lookupErr)
+		}
+
+		log.Debugf("Bulk check verify query of domain %s result: %+v", domainInfo.Domain, verifyResult)
+
+		err := rdb.RPush(context.Background(), bulkCheckResultRedisKey(verifyResult.RegisterStatus), convertor.ToString(verifyResult)).Err()
+		if err != nil {
+			log.Warnf("Bulk check handler %d failed to save the verify result of domain %s to redis: %s", handerSeq, domainInfo.Domain, err)
+		}
+		return err
 	case constant.LookupTypeDNS:
 		if lookupErr == nil {
 			if len(lookupResult.NameServer) > 0 {
@@ -984,9 +1098,14 @@ func bulkCheckLookupResultHandler(handerSeq int, domainInfo BulkCheckDomain, loo
 					Order:          domainInfo.Order,
 					Domain:         domainInfo.Domain,
 					LookupType:     lookupResult.LookupType,
-					RegisterStatus: constant.DomainRegisterStatusTaken,
+					FromCache:      lookupResult.FromCache,
+					CachedAt:       lookupResult.CachedAt,
+					FallbackFrom:   lookupResult.FallbackFrom,
+					Trace:          lookupResult.Trace,
+					RegisterStatus: registerStatus,
 					NameServer:     slice.Map(lookupResult.NameServer, utils.LowerString),
 					DnsLite:        utils.GetDnsLite(lookupResult.NameServer),
+					DnsSignals:     lookupResult.DnsSignals,
 				}
 
 				log.Debugf("DNS query of domain %s taken result: %+v", domainInfo.Domain, takenResult)
@@ -1001,7 +1120,11 @@ func bulkCheckLookupResultHandler(handerSeq int, domainInfo BulkCheckDomain, loo
 					Order:          domainInfo.Order,
 					Domain:         domainInfo.Domain,
 					LookupType:     lookupResult.LookupType,
-					RegisterStatus: constant.DomainRegisterStatusFree,
+					FromCache:      lookupResult.FromCache,
+					CachedAt:       lookupResult.CachedAt,
+					FallbackFrom:   lookupResult.FallbackFrom,
+					Trace:          lookupResult.Trace,
+					RegisterStatus: registerStatus,
 				}
 
 				log.Debugf("DNS query of domain %s free result: %+v", domainInfo.Domain, freeResult)
@@ -1017,7 +1140,11 @@ func bulkCheckLookupResultHandler(handerSeq int, domainInfo BulkCheckDomain, loo
 				Order:          domainInfo.Order,
 				Domain:         domainInfo.Domain,
 				LookupType:     lookupResult.LookupType,
-				RegisterStatus: constant.DomainRegisterStatusFree,
+				FromCache:      lookupResult.FromCache,
+				CachedAt:       lookupResult.CachedAt,
+				FallbackFrom:   lookupResult.FallbackFrom,
+				Trace:          lookupResult.Trace,
+				RegisterStatus: registerStatus,
 			}
 
 			log.Debugf("DNS query of domain %s free result: %+v", domainInfo.Domain, freeResult)
@@ -1033,7 +1160,11 @@ func bulkCheckLookupResultHandler(handerSeq int, domainInfo BulkCheckDomain, loo
 				Order:          domainInfo.Order,
 				Domain:         domainInfo.Domain,
 				LookupType:     lookupResult.LookupType,
-				RegisterStatus: constant.DomainRegisterStatusError,
+				FromCache:      lookupResult.FromCache,
+				CachedAt:       lookupResult.CachedAt,
+				FallbackFrom:   lookupResult.FallbackFrom,
+				Trace:          lookupResult.Trace,
+				RegisterStatus: registerStatus,
 				QueryError:     utils.GetDomainHumanError(lookupErr),
 			}
 
@@ -1051,7 +1182,11 @@ func bulkCheckLookupResultHandler(handerSeq int, domainInfo BulkCheckDomain, loo
 				Order:          domainInfo.Order,
 				Domain:         domainInfo.Domain,
 				LookupType:     lookupResult.LookupType,
-				RegisterStatus: constant.DomainRegisterStatusError,
+				FromCache:      lookupResult.FromCache,
+				CachedAt:       lookupResult.CachedAt,
+				FallbackFrom:   lookupResult.FallbackFrom,
+				Trace:          lookupResult.Trace,
+				RegisterStatus: registerStatus,
 				QueryError:     utils.GetDomainHumanError(lookupErr),
 			}
 
@@ -1061,13 +1196,17 @@ func bulkCheckLookupResultHandler(handerSeq int, domainInfo BulkCheckDomain, loo
 			}
 			return err
 		} else {
-			switch lookupResult.CustomizedResult {
+			switch registerStatus {
 			case constant.DomainRegisterStatusTaken:
 				takenResult := lookupinfo.QueryResult{
 					Order:          domainInfo.Order,
 					Domain:         domainInfo.Domain,
 					LookupType:     lookupResult.LookupType,
-					RegisterStatus: constant.DomainRegisterStatusTaken,
+					FromCache:      lookupResult.FromCache,
+					CachedAt:       lookupResult.CachedAt,
+					FallbackFrom:   lookupResult.FallbackFrom,
+					Trace:          lookupResult.Trace,
+					RegisterStatus: registerStatus,
 				}
 
 				log.Debugf("Customize api whois query of domain %s taken result: %+v", domainInfo.Domain, takenResult)
@@ -1082,7 +1221,11 @@ func bulkCheckLookupResultHandler(handerSeq int, domainInfo BulkCheckDomain, loo
 					Order:          domainInfo.Order,
 					Domain:         domainInfo.Domain,
 					LookupType:     lookupResult.LookupType,
-					RegisterStatus: constant.DomainRegisterStatusFree,
+					FromCache:      lookupResult.FromCache,
+					CachedAt:       lookupResult.CachedAt,
+					FallbackFrom:   lookupResult.FallbackFrom,
+					Trace:          lookupResult.Trace,
+					RegisterStatus: registerStatus,
 				}
 
 				log.Debugf("Customize api whois query of domain %s free result: %+v", domainInfo.Domain, freeResult)
@@ -1099,6 +1242,21 @@ func bulkCheckLookupResultHandler(handerSeq int, domainInfo BulkCheckDomain, loo
 	}
 }
 
+// bulkCheckResultRedisKey returns the redis key of the result list for the register status.
+// The reserved, premium and blocked results share a list, as none of them can be registered as usual.
+func bulkCheckResultRedisKey(registerStatus string) string {
+	switch registerStatus {
+	case constant.DomainRegisterStatusTaken:
+		return constant.BulkCheckTakenResultRedisKey
+	case constant.DomainRegisterStatusFree:
+		return constant.BulkCheckFreeResultRedisKey
+	case constant.DomainRegisterStatusReserved, constant.DomainRegisterStatusPremium, constant.DomainRegisterStatusBlocked:
+		return constant.BulkCheckReservedResultRedisKey
+	default:
+		return constant.BulkCheckErrorResultRedisKey
+	}
+}
+
 // deleteDomainFromUniqueDomainList deletes a domain from the unique domain list in redis.
 // It returns an error if it fails to delete the domain from redis.
 func deleteDomainFromUniqueDomainList(domain string) error {
@@ -1124,6 +1282,11 @@ func GetBulkCheckFreeDomains() []string {
 	return freeDomains
 }
 
+func GetBulkCheckReservedDomains() []string {
+	reservedDomains := rdb.LRange(context.Background(), constant.BulkCheckReservedResultRedisKey, 0, -1).Val()
+	return reservedDomains
+}
+
 func GetBulkCheckErrorDomains() []string {
 	errorDomains := rdb.LRange(context.Background(), constant.BulkCheckErrorResultRedisKey, 0, -1).Val()
 	return errorDomains
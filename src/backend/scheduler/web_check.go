@@ -44,9 +44,10 @@ func (t *WebCheck) SetDomains(domains []string) {
 }
 
 // Run runs the web check task for the given user and query type.
+// If bypassCache is true, the domains are looked up again instead of using the cached results.
 // It trims and gets the main domain of the raw domains, and sends the result to the user through the websocket.
 // It also starts the web query workers to query the domains.
-func (t *WebCheck) Run(queryType string) {
+func (t *WebCheck) Run(queryType string, bypassCache bool) {
 	if len(t.Domains) == 0 {
 		log.Error("Empty domains, do nothing")
 		responseError := map[string]interface{}{
@@ -87,7 +88,7 @@ func (t *WebCheck) Run(queryType string) {
 	// Start the web query workers
 	for i := 0; i < concurrencyLimit; i++ {
 		wg.Add(1)
-		go t.webCheckHandler(i, ch, &wg, queryType)
+		go t.webCheckHandler(i, ch, &wg, queryType, bypassCache)
 	}
 
 	// Send the domains to the web query workers
@@ -128,7 +129,7 @@ func (t *WebCheck) Stop() {
 
 // webCheckHandler is a goroutine function that queries the domains in the given channel and sends the result to the user through the websocket.
 // It also handles the cancellation of the context and the finish of the goroutine.
-func (t *WebCheck) webCheckHandler(i int, ch chan string, wg *sync.WaitGroup, queryType string) {
+func (t *WebCheck) webCheckHandler(i int, ch chan string, wg *sync.WaitGroup, queryType string, bypassCache bool) {
 	defer wg.Done()
 
 	handerSeq := i + 1
@@ -151,7 +152,12 @@ func (t *WebCheck) webCheckHandler(i int, ch chan string, wg *sync.WaitGroup, qu
 
 			log.Debugf("Web check task handler %d for user %s, query domain %s", handerSeq, t.UserID, domain)
 
-			lookupResult, err := lookuper.Lookup(domain, queryType)
+			lookupResult, err := lookuper.CachedLookup(t.Ctx, domain, queryType, bypassCache)
+			if errors.Is(err, lookuperror.ErrorLookupCanceled) {
+				// If the lookup is canceled by stopping the task, do not send the result
+				log.Infof("Web check task handler %d for user %s canceled the query of domain %s", handerSeq, t.UserID, domain)
+				return
+			}
 			t.webLookupResultHandler(domain, lookupResult, err)
 		}
 	}
@@ -159,56 +165,54 @@ func (t *WebCheck) webCheckHandler(i int, ch chan string, wg *sync.WaitGroup, qu
 
 func (t *WebCheck) webLookupResultHandler(domain string, lookupResult lookupinfo.DomainInfo, lookupErr error) {
 	queryResult := lookupinfo.QueryResult{
-		Domain:      domain,
-		LookupType:  lookupResult.LookupType,
-		ViaProxy:    lookupResult.ViaProxy,
-		RawResponse: lookupResult.RawResponse,
+		Domain:       domain,
+		LookupType:   lookupResult.LookupType,
+		ViaProxy:     lookupResult.ViaProxy,
+		RawResponse:  lookupResult.RawResponse,
+		FromCache:    lookupResult.FromCache,
+		CachedAt:     lookupResult.CachedAt,
+		FallbackFrom: lookupResult.FallbackFrom,
+		Trace:        lookupResult.Trace,
+	}
+
+	queryResult.RegisterStatus = utils.GetLookupRegisterStatus(lookupResult, lookupErr)
+	if queryResult.RegisterStatus == constant.DomainRegisterStatusError && lookupErr != nil {
+		// Convert the lookup error to human readable error message and save it to the QueryError field
+		queryResult.QueryError = utils.GetDomainHumanError(lookupErr)
 	}
 
 	switch lookupResult.LookupType {
 	case constant.LookupTypeWhois, constant.LookupTypeRDAP:
 		if lookupErr == nil {
 			// If the whois query is successful, parse the result and send it to the user through the websocket
-			queryResult.RegisterStatus = constant.DomainRegisterStatusTaken
 			queryResult.CreatedDate = lookupResult.CreationDate
 			queryResult.ExpiryDate = lookupResult.ExpiryDate
 			queryResult.NameServer = slice.Map(lookupResult.NameServer, utils.LowerString)
 			queryResult.DnsLite = utils.GetDnsLite(lookupResult.NameServer)
 			queryResult.RawDomainStatus = lookupResult.DomainStatus
 			queryResult.DomainStatus = utils.GetDomainHumanStatus(lookupResult.DomainStatus)
-		} else if errors.Is(lookupErr, lookuperror.ErrorWhoisNotFound) {
-			// If the whois query is not successful because the domain is not found, set the register status to Free
-			queryResult.RegisterStatus = constant.DomainRegisterStatusFree
-		} else {
-			// If the whois query is not successful because of other errors, set the register status to Error and the query error to the error message
-			queryResult.RegisterStatus = constant.DomainRegisterStatusError
-			queryResult.QueryError = utils.GetDomainHumanError(lookupErr)
+			queryResult.Details = utils.GetRegistrationDetails(lookupResult)
 		}
-	case constant.LookupTypeDNS:
+	case constant.LookupTypeVerify:
+		queryResult.Conflict = lookupResult.Conflict
+		queryResult.Sources = lookupResult.Sources
 		if lookupErr == nil {
-			if len(lookupResult.NameServer) > 0 {
-				queryResult.RegisterStatus = constant.DomainRegisterStatusTaken
-				// Convert the name server list to lowercase and save it to the NameServer field
-				queryResult.NameServer = slice.Map(lookupResult.NameServer, utils.LowerString)
-				// Calculate the DNS lite of the name server list and save it to the DnsLite field
-				queryResult.DnsLite = utils.GetDnsLite(lookupResult.NameServer)
-			} else {
-				queryResult.RegisterStatus = constant.DomainRegisterStatusFree
-			}
-		} else if errors.Is(lookupErr, lookuperror.ErrorNsNotFound) {
-			queryResult.RegisterStatus = constant.DomainRegisterStatusFree
-		} else {
-			queryResult.RegisterStatus = constant.DomainRegisterStatusError
-			// Convert the DNS error to human readable error message and save it to the QueryError field
-			queryResult.QueryError = utils.GetDomainHumanError(lookupErr)
+			queryResult.CreatedDate = lookupResult.CreationDate
+			queryResult.ExpiryDate = lookupResult.ExpiryDate
+			queryResult.NameServer = slice.Map(lookupResult.NameServer, utils.LowerString)
+			queryResult.DnsLite = utils.GetDnsLite(lookupResult.NameServer)
+			queryResult.RawDomainStatus = lookupResult.DomainStatus
+			queryResult.DomainStatus = utils.GetDomainHumanStatus(lookupResult.DomainStatus)
+			queryResult.Details = utils.GetRegistrationDetails(lookupResult)
+			queryResult.DnsSignals = lookupResult.DnsSignals
 		}
-	default:
-		// Customize api whois result
-		if lookupErr != nil {
-			queryResult.RegisterStatus = constant.DomainRegisterStatusError
-			queryResult.QueryError = utils.GetDomainHumanError(lookupErr)
-		} else {
-			queryResult.RegisterStatus = lookupResult.CustomizedResult
+	case constant.LookupTypeDNS:
+		if lookupErr == nil && len(lookupResult.NameServer) > 0 {
+			// Convert the name server list to lowercase and save it to the NameServer field
+			queryResult.NameServer = slice.Map(lookupResult.NameServer, utils.LowerString)
+			// Calculate the DNS lite of the name server list and save it to the DnsLite field
+			queryResult.DnsLite = utils.GetDnsLite(lookupResult.NameServer)
+			queryResult.DnsSignals = lookupResult.DnsSignals
 		}
 	}
 
@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"strings"
+
+	"typonamer/constant"
+	"typonamer/lookup/lookupinfo"
+
+	"github.com/duke-git/lancet/v2/slice"
+	"github.com/duke-git/lancet/v2/strutil"
+)
+
+// eppStatusNames maps the domain statuses in lowercase without whitespace to their EPP names,
+// so the RDAP statuses such as "client transfer prohibited" and the WHOIS statuses such as "clientTransferProhibited" are treated alike.
+var eppStatusNames = map[string]string{
+	"ok":                       "ok",
+	"active":                   "ok",
+	"inactive":                 "inactive",
+	"clientdeleteprohibited":   "clientDeleteProhibited",
+	"serverdeleteprohibited":   "serverDeleteProhibited",
+	"clientrenewprohibited":    "clientRenewProhibited",
+	"serverrenewprohibited":    "serverRenewProhibited",
+	"clienttransferprohibited": "clientTransferProhibited",
+	"servertransferprohibited": "serverTransferProhibited",
+	"clientupdateprohibited":   "clientUpdateProhibited",
+	"serverupdateprohibited":   "serverUpdateProhibited",
+	"clienthold":               "clientHold",
+	"serverhold":               "serverHold",
+	"pendingcreate":            "pendingCreate",
+	"pendingdelete":            "pendingDelete",
+	"pendingrenew":             "pendingRenew",
+	"pendingrestore":           "pendingRestore",
+	"pendingtransfer":          "pendingTransfer",
+	"pendingupdate":            "pendingUpdate",
+	"addperiod":                "addPeriod",
+	"autorenewperiod":          "autoRenewPeriod",
+	"renewperiod":              "renewPeriod",
+	"transferperiod":           "transferPeriod",
+	"redemptionperiod":         "redemptionPeriod",
+}
+
+// lifecyclePhases are the EPP statuses which put a domain in a lifecycle phase, in order of precedence,
+// as a domain in redemption or pending deletion may still carry the status of an earlier grace period.
+var lifecyclePhases = []struct {
+	status string
+	phase  string
+}{
+	{"pendingDelete", constant.DomainStatusPendingDelete},
+	{"pendingRestore", constant.DomainStatusPendingRestore},
+	{"redemptionPeriod", constant.DomainStatusRedemptionPeriod},
+	{"autoRenewPeriod", constant.DomainStatusAutoRenewGracePeriod},
+	{"renewPeriod", constant.DomainStatusRenewGracePeriod},
+	{"transferPeriod", constant.DomainStatusTransferGracePeriod},
+	{"addPeriod", constant.DomainStatusAddGracePeriod},
+}
+
+// GetDomainLifecycle maps the statuses and the events of the domain to its lifecycle phase,
+// the holds, the locks and the pending operations.
+// The phase is Unknown if the domain has no status, and Active if none of its statuses is a lifecycle phase.
+func GetDomainLifecycle(domainInfo lookupinfo.DomainInfo) lookupinfo.DomainLifecycle {
+	lifecycle := lookupinfo.DomainLifecycle{
+		Phase: constant.DomainStatusUnknown,
+	}
+	if len(domainInfo.DomainStatus) == 0 {
+		return lifecycle
+	}
+
+	statuses := slice.Unique(slice.Map(domainInfo.DomainStatus, func(_ int, status string) string {
+		return eppStatusName(status)
+	}))
+
+	lifecycle.Phase = constant.DomainStatusActive
+	for _, lifecyclePhase := range lifecyclePhases {
+		if slice.Contain(statuses, lifecyclePhase.status) {
+			lifecycle.Phase = lifecyclePhase.phase
+			break
+		}
+	}
+
+	for _, status := range statuses {
+		switch {
+		case status == "clientHold" || status == "serverHold":
+			lifecycle.Hold = true
+		case strings.HasSuffix(status, "Prohibited"):
+			lifecycle.Locks = append(lifecycle.Locks, status)
+		case status == "pendingCreate" || status == "pendingRenew" || status == "pendingTransfer" || status == "pendingUpdate":
+			lifecycle.Pending = append(lifecycle.Pending, status)
+		}
+	}
+
+	lifecycle.Since = getLifecycleSince(lifecycle.Phase, domainInfo)
+
+	// The registries announcing the deletion give its date as the "deletion" event
+	switch lifecycle.Phase {
+	case constant.DomainStatusRedemptionPeriod, constant.DomainStatusPendingRestore, constant.DomainStatusPendingDelete:
+		lifecycle.Until = getLatestEventDate(domainInfo.Events, "deletion")
+	}
+
+	return lifecycle
+}
+
+// getLifecycleSince returns when the domain entered the lifecycle phase.
+// The grace periods after the registration and the transfer start at the date of their events,
+// the other phases at the last change of the domain, which is the change of its status.
+func getLifecycleSince(phase string, domainInfo lookupinfo.DomainInfo) string {
+	var since string
+	switch phase {
+	case constant.DomainStatusUnknown:
+		return ""
+	case constant.DomainStatusActive, constant.DomainStatusAddGracePeriod:
+		since = getLatestEventDate(domainInfo.Events, "registration", "reregistration", "reinstantiation")
+		if since == "" {
+			since = domainInfo.CreationDate
+		}
+		return since
+	case constant.DomainStatusTransferGracePeriod:
+		since = getLatestEventDate(domainInfo.Events, "transfer")
+	}
+
+	if since == "" {
+		since = getLatestEventDate(domainInfo.Events, "last changed")
+	}
+	if since == "" {
+		since = domainInfo.UpdatedDate
+	}
+	return since
+}
+
+// getLatestEventDate returns the latest date of the events with any of the actions, empty if there is none.
+func getLatestEventDate(events []lookupinfo.DomainEvent, actions ...string) string {
+	var latest string
+	for _, event := range events {
+		// The dates are in the same format, so they are ordered as strings
+		if slice.Contain(actions, event.Action) && event.Date > latest {
+			latest = event.Date
+		}
+	}
+	return latest
+}
+
+// eppStatusName returns the EPP name of the domain status, or the status in lowercase without whitespace if it is not an EPP status.
+// The WHOIS statuses are often followed by the URL of their explanation, such as "clientHold https://icann.org/epp#clientHold".
+func eppStatusName(status string) string {
+	if i := strings.Index(status, "http"); i > 0 {
+		status = status[:i]
+	}
+
+	key := strings.ToLower(strutil.RemoveWhiteSpace(status, true))
+	if name, ok := eppStatusNames[key]; ok {
+		return name
+	}
+	return key
+}
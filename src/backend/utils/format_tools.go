@@ -1,7 +1,12 @@
 package utils
 
 import (
+	"bytes"
+	"encoding/csv"
 	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
 	"typonamer/constant"
 	"typonamer/log"
@@ -14,6 +19,14 @@ import (
 	"github.com/jszwec/csvutil"
 )
 
+// rePrivacyService matches the names used by the privacy and proxy services and the redacted registrant names.
+var rePrivacyService = regexp.MustCompile(`(?i)(privacy|proxy|redacted|withheld|whoisguard|whois ?protect|domains by proxy|data protected|not disclosed|identity protect|gdpr)`)
+
+// IsPrivacyService reports whether the registrant name is a privacy or proxy service or a redacted name.
+func IsPrivacyService(registrant string) bool {
+	return registrant != "" && rePrivacyService.MatchString(registrant)
+}
+
 func GetDnsLite(nameServers []string) string {
 	var dnsLite string
 	if len(nameServers) > 0 {
@@ -56,7 +69,19 @@ func GetDomainHumanStatus(domainStatus []string) string {
 
 // GetDomainHumanError takes an error and returns the human-readable error message.
 // The returned string is in Chinese.
+// If the error is a LookupError which failed after retries, the number of attempts is appended.
 func GetDomainHumanError(err error) string {
+	message := getHumanErrorMessage(err)
+
+	if lookupErr, ok := lookuperror.AsLookupError(err); ok && lookupErr.Attempts > 1 {
+		message = fmt.Sprintf("%s(已尝试%d次)", message, lookupErr.Attempts)
+	}
+
+	return message
+}
+
+// getHumanErrorMessage returns the human-readable message of the sentinel error wrapped by err.
+func getHumanErrorMessage(err error) string {
 	if err != nil {
 		switch {
 		case errors.Is(err, lookuperror.ErrorInvalidDomainName):
@@ -67,6 +92,10 @@ func GetDomainHumanError(err error) string {
 			return "后缀不支持"
 		case errors.Is(err, lookuperror.ErrorWhoisServerFailed):
 			return "Whois查询失败"
+		case errors.Is(err, lookuperror.ErrorWhoisRateLimited):
+			return "Whois查询频率受限"
+		case errors.Is(err, lookuperror.ErrorRdapRateLimited):
+			return "RDAP查询频率受限"
 		case errors.Is(err, lookuperror.ErrorConnectToProxy):
 			return "代理连接失败"
 		case errors.Is(err, lookuperror.ErrorNoContentInWhoisResponse):
@@ -89,6 +118,10 @@ func GetDomainHumanError(err error) string {
 			return "自定义Whois API服务器返回异常"
 		case errors.Is(err, lookuperror.ErrorCustomizeApiWhoisResult):
 			return "自定义Whois API结果解析错误"
+		case errors.Is(err, lookuperror.ErrorLookupCanceled):
+			return "查询已取消"
+		case errors.Is(err, lookuperror.ErrorAllSourcesFailed):
+			return "所有查询来源均失败"
 		default:
 			return "其它错误"
 		}
@@ -123,13 +156,57 @@ func ConvertQueryResultToCSV(queryResults []lookupinfo.QueryResult) ([]byte, err
 	var csvResults []lookupinfo.QueryCsvResult
 	for _, queryResult := range queryResults {
 		viaProxy := ""
-		if (queryResult.LookupType == constant.LookupTypeWhois) || (queryResult.LookupType == constant.LookupTypeRDAP) {
+		if (queryResult.LookupType == constant.LookupTypeWhois) || (queryResult.LookupType == constant.LookupTypeRDAP) || (queryResult.LookupType == constant.LookupTypeVerify) {
 			if queryResult.ViaProxy {
 				viaProxy = "Yes"
 			} else {
 				viaProxy = "No"
 			}
 		}
+		attempts := ""
+		latencies := make([]string, 0, len(queryResult.Trace.Attempts))
+		attemptErrors := make([]string, 0)
+		if len(queryResult.Trace.Attempts) > 0 {
+			attempts = strconv.Itoa(len(queryResult.Trace.Attempts))
+		}
+		for _, attempt := range queryResult.Trace.Attempts {
+			latencies = append(latencies, strconv.FormatInt(attempt.LatencyMs, 10))
+			if attempt.Error != "" {
+				attemptErrors = append(attemptErrors, fmt.Sprintf("%s@%s: %s", attempt.Source, attempt.Server, attempt.Error))
+			}
+		}
+		conflict := ""
+		if queryResult.LookupType == constant.LookupTypeVerify {
+			if queryResult.Conflict {
+				conflict = "Yes"
+			} else {
+				conflict = "No"
+			}
+		}
+		privacy := ""
+		if queryResult.Details.Privacy {
+			privacy = "Yes"
+		}
+		hold := ""
+		if queryResult.Details.Lifecycle.Hold {
+			hold = "Yes"
+		}
+		events := make([]string, 0, len(queryResult.Details.Events))
+		for _, event := range queryResult.Details.Events {
+			events = append(events, fmt.Sprintf("%s: %s", event.Action, event.Date))
+		}
+		// The lame domains have no record, only the domains whose name servers answered have the DNS signals columns
+		soaSerial, hasDs, hasDnskey := "", "", ""
+		if queryResult.DnsSignals.State != "" && !queryResult.DnsSignals.Lame {
+			soaSerial = strconv.FormatUint(uint64(queryResult.DnsSignals.SoaSerial), 10)
+			hasDs, hasDnskey = "No", "No"
+			if queryResult.DnsSignals.HasDs {
+				hasDs = "Yes"
+			}
+			if queryResult.DnsSignals.HasDnskey {
+				hasDnskey = "Yes"
+			}
+		}
 		csvResults = append(csvResults, lookupinfo.QueryCsvResult{
 			Domain:          queryResult.Domain,
 			LookupType:      queryResult.LookupType,
@@ -142,10 +219,176 @@ func ConvertQueryResultToCSV(queryResults []lookupinfo.QueryResult) ([]byte, err
 			DnsLite:         queryResult.DnsLite,
 			RawDomainStatus: slice.Join(queryResult.RawDomainStatus, ","),
 			DomainStatus:    queryResult.DomainStatus,
+			CachedAt:        queryResult.CachedAt,
+			Conflict:        conflict,
+			Sources:         GetSourcesSummary(queryResult.Sources),
+			FallbackFrom:    slice.Join(queryResult.FallbackFrom, ","),
+			Server:          queryResult.Trace.Server,
+			Proxy:           queryResult.Trace.Proxy,
+			Attempts:        attempts,
+			LatencyMs:       strings.Join(latencies, ","),
+			AttemptErrors:   strings.Join(attemptErrors, " | "),
+
+			Registrar:              queryResult.Details.Registrar,
+			UpdatedDate:            queryResult.Details.UpdatedDate,
+			RegistrarIanaId:        queryResult.Details.RegistrarIanaId,
+			RegistrarAbuseEmail:    queryResult.Details.RegistrarAbuseEmail,
+			RegistrarAbusePhone:    queryResult.Details.RegistrarAbusePhone,
+			RegistrantOrganization: queryResult.Details.RegistrantOrganization,
+			RegistrantCountry:      queryResult.Details.RegistrantCountry,
+			Dnssec:                 queryResult.Details.Dnssec,
+			DsData:                 strings.Join(queryResult.Details.DsData, ","),
+			Privacy:                privacy,
+			RedactedFields:         strings.Join(queryResult.Details.RedactedFields, ","),
+			LifecyclePhase:         queryResult.Details.Lifecycle.Phase,
+			LifecycleSince:         queryResult.Details.Lifecycle.Since,
+			LifecycleUntil:         queryResult.Details.Lifecycle.Until,
+			Hold:                   hold,
+			Locks:                  strings.Join(queryResult.Details.Lifecycle.Locks, ","),
+			Pending:                strings.Join(queryResult.Details.Lifecycle.Pending, ","),
+			Events:                 strings.Join(events, ","),
+			DnsState:               queryResult.DnsSignals.State,
+			SoaSerial:              soaSerial,
+			SoaContact:             queryResult.DnsSignals.SoaContact,
+			Addresses:              strings.Join(queryResult.DnsSignals.Addresses, ","),
+			Mx:                     strings.Join(queryResult.DnsSignals.Mx, ","),
+			HasDs:                  hasDs,
+			HasDnskey:              hasDnskey,
 		})
 	}
 
-	return csvutil.Marshal(csvResults)
+	return marshalQueryCsvResults(csvResults)
+}
+
+// extendedCsvColumns are the columns of the registration details and the DNS signals, which are only output if any result has a value for them.
+var extendedCsvColumns = map[string]func(lookupinfo.QueryCsvResult) string{
+	"Registrar":               func(r lookupinfo.QueryCsvResult) string { return r.Registrar },
+	"Updated Date":            func(r lookupinfo.QueryCsvResult) string { return r.UpdatedDate },
+	"Registrar IANA ID":       func(r lookupinfo.QueryCsvResult) string { return r.RegistrarIanaId },
+	"Registrar Abuse Email":   func(r lookupinfo.QueryCsvResult) string { return r.RegistrarAbuseEmail },
+	"Registrar Abuse Phone":   func(r lookupinfo.QueryCsvResult) string { return r.RegistrarAbusePhone },
+	"Registrant Organization": func(r lookupinfo.QueryCsvResult) string { return r.RegistrantOrganization },
+	"Registrant Country":      func(r lookupinfo.QueryCsvResult) string { return r.RegistrantCountry },
+	"DNSSEC":                  func(r lookupinfo.QueryCsvResult) string { return r.Dnssec },
+	"DS Data":                 func(r lookupinfo.QueryCsvResult) string { return r.DsData },
+	"Privacy":                 func(r lookupinfo.QueryCsvResult) string { return r.Privacy },
+	"Redacted Fields":         func(r lookupinfo.QueryCsvResult) string { return r.RedactedFields },
+	"Lifecycle Phase":         func(r lookupinfo.QueryCsvResult) string { return r.LifecyclePhase },
+	"Lifecycle Since":         func(r lookupinfo.QueryCsvResult) string { return r.LifecycleSince },
+	"Lifecycle Until":         func(r lookupinfo.QueryCsvResult) string { return r.LifecycleUntil },
+	"Hold":                    func(r lookupinfo.QueryCsvResult) string { return r.Hold },
+	"Locks":                   func(r lookupinfo.QueryCsvResult) string { return r.Locks },
+	"Pending":                 func(r lookupinfo.QueryCsvResult) string { return r.Pending },
+	"Events":                  func(r lookupinfo.QueryCsvResult) string { return r.Events },
+	"DNS State":               func(r lookupinfo.QueryCsvResult) string { return r.DnsState },
+	"SOA Serial":              func(r lookupinfo.QueryCsvResult) string { return r.SoaSerial },
+	"SOA Contact":             func(r lookupinfo.QueryCsvResult) string { return r.SoaContact },
+	"Addresses":               func(r lookupinfo.QueryCsvResult) string { return r.Addresses },
+	"MX":                      func(r lookupinfo.QueryCsvResult) string { return r.Mx },
+	"Has DS":                  func(r lookupinfo.QueryCsvResult) string { return r.HasDs },
+	"Has DNSKEY":              func(r lookupinfo.QueryCsvResult) string { return r.HasDnskey },
+}
+
+// marshalQueryCsvResults marshals the results to CSV, leaving out the extended columns without any value.
+func marshalQueryCsvResults(csvResults []lookupinfo.QueryCsvResult) ([]byte, error) {
+	allColumns, err := csvutil.Header(lookupinfo.QueryCsvResult{}, "csv")
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]string, 0, len(allColumns))
+	for _, column := range allColumns {
+		value, extended := extendedCsvColumns[column]
+		if !extended || slice.Some(csvResults, func(_ int, r lookupinfo.QueryCsvResult) bool { return value(r) != "" }) {
+			header = append(header, column)
+		}
+	}
+
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	encoder := csvutil.NewEncoder(writer)
+	encoder.SetHeader(header)
+
+	// Encode the header even if there is no result, as csvutil.Marshal does
+	err = encoder.EncodeHeader(lookupinfo.QueryCsvResult{})
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Encode(csvResults)
+	if err != nil {
+		return nil, err
+	}
+
+	writer.Flush()
+	return buffer.Bytes(), writer.Error()
+}
+
+// GetRegisterStatus returns the register status of a successful WHOIS or RDAP lookup,
+// the status given by the registry if it holds the domain back, or Taken otherwise.
+func GetRegisterStatus(domainInfo lookupinfo.DomainInfo) string {
+	if domainInfo.RegistryStatus != "" {
+		return domainInfo.RegistryStatus
+	}
+	return constant.DomainRegisterStatusTaken
+}
+
+// GetLookupRegisterStatus returns the register status of the lookup result and its error, as reported by the check tasks
+// and used to choose the TTL of the lookup cache.
+func GetLookupRegisterStatus(domainInfo lookupinfo.DomainInfo, lookupErr error) string {
+	switch domainInfo.LookupType {
+	case constant.LookupTypeWhois, constant.LookupTypeRDAP:
+		if lookupErr == nil {
+			return GetRegisterStatus(domainInfo)
+		}
+		if errors.Is(lookupErr, lookuperror.ErrorWhoisNotFound) {
+			return constant.DomainRegisterStatusFree
+		}
+	case constant.LookupTypeVerify:
+		if lookupErr == nil {
+			return domainInfo.ConsensusStatus
+		}
+	case constant.LookupTypeDNS:
+		if lookupErr == nil && len(domainInfo.NameServer) > 0 {
+			return constant.DomainRegisterStatusTaken
+		}
+		if lookupErr == nil || errors.Is(lookupErr, lookuperror.ErrorNsNotFound) {
+			return constant.DomainRegisterStatusFree
+		}
+	default:
+		// Customize api whois result
+		if lookupErr == nil {
+			return domainInfo.CustomizedResult
+		}
+	}
+	return constant.DomainRegisterStatusError
+}
+
+// GetRegistrationDetails returns the registration details of the domain info for the query result.
+func GetRegistrationDetails(domainInfo lookupinfo.DomainInfo) lookupinfo.RegistrationDetails {
+	return lookupinfo.RegistrationDetails{
+		Registrar:              domainInfo.Registrar,
+		UpdatedDate:            domainInfo.UpdatedDate,
+		RegistrarIanaId:        domainInfo.RegistrarIanaId,
+		RegistrarAbuseEmail:    domainInfo.RegistrarAbuseEmail,
+		RegistrarAbusePhone:    domainInfo.RegistrarAbusePhone,
+		RegistrantOrganization: domainInfo.RegistrantOrganization,
+		RegistrantCountry:      domainInfo.RegistrantCountry,
+		Dnssec:                 domainInfo.Dnssec,
+		DsData:                 domainInfo.DsData,
+		Privacy:                domainInfo.Privacy,
+		RedactedFields:         domainInfo.RedactedFields,
+		Events:                 domainInfo.Events,
+		Lifecycle:              GetDomainLifecycle(domainInfo),
+	}
+}
+
+// GetSourcesSummary joins the register status of every source of a verify lookup, such as "rdap:Taken,dns:Taken".
+func GetSourcesSummary(sources []lookupinfo.SourceResult) string {
+	summaries := make([]string, 0, len(sources))
+	for _, source := range sources {
+		summaries = append(summaries, source.LookupType+":"+source.RegisterStatus)
+	}
+	return strings.Join(summaries, ",")
 }
 
 func LowerString(_ int, v string) string {
@@ -37,6 +37,13 @@ export const useBulkStore = defineStore("bulk", {
                                 icon: "fa-solid fa-ban",
                                 color: "secondary"
                             },
+                            {
+                                label: "保留/溢价/屏蔽",
+                                id: "reserved",
+                                value: 0,
+                                icon: "fa-solid fa-lock",
+                                color: "info"
+                            },
                             {
                                 label: "错误",
                                 id: "error",
@@ -84,7 +91,7 @@ export const useBulkStore = defineStore("bulk", {
         },
 
         updateBulkCheckInfo(info) {
-            let doneDomains = info.TakenDomains + info.FreeDomains + info.ErrorDomains;
+            let doneDomains = info.TakenDomains + info.FreeDomains + info.ReservedDomains + info.ErrorDomains;
             if (info.TotalDomains > 0) {
                 this.runingProgress = parseFloat((doneDomains / info.TotalDomains).toFixed(4));
                 this.runingProgressPercent = (this.runingProgress * 100).toFixed(2) + " %";
@@ -97,7 +104,8 @@ export const useBulkStore = defineStore("bulk", {
             this.bulkCheckInfo[0].children[0].value = doneDomains;
             this.bulkCheckInfo[0].children[0].children[0].value = info.TakenDomains;
             this.bulkCheckInfo[0].children[0].children[1].value = info.FreeDomains;
-            this.bulkCheckInfo[0].children[0].children[2].value = info.ErrorDomains;
+            this.bulkCheckInfo[0].children[0].children[2].value = info.ReservedDomains;
+            this.bulkCheckInfo[0].children[0].children[3].value = info.ErrorDomains;
             this.bulkCheckInfo[0].children[1].value = info.RemainDomains;
         },
 